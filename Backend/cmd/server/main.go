@@ -1,14 +1,35 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"net/http"
+	"strings"
+	"time"
+	// Embedded zone database, so CALENDAR_TIMEZONE resolves in minimal images too.
+	_ "time/tzdata"
 
 	"github.com/alesio/gestion-actividades-deportivas/config"
 	"github.com/alesio/gestion-actividades-deportivas/database"
+	"github.com/alesio/gestion-actividades-deportivas/events"
 	"github.com/alesio/gestion-actividades-deportivas/handlers"
 	"github.com/alesio/gestion-actividades-deportivas/middlewares"
+	"github.com/alesio/gestion-actividades-deportivas/notifications"
+	"github.com/alesio/gestion-actividades-deportivas/payments"
+	"github.com/alesio/gestion-actividades-deportivas/payments/paymentfake"
+	"github.com/alesio/gestion-actividades-deportivas/realtime"
+	"github.com/alesio/gestion-actividades-deportivas/search"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/security/oidcfake"
 	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/alesio/gestion-actividades-deportivas/storage"
+	"github.com/alesio/gestion-actividades-deportivas/storage/s3fake"
+	"github.com/alesio/gestion-actividades-deportivas/webhooks"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -19,40 +40,302 @@ func main() {
 		log.Fatalf("database initialization failed: %v", err)
 	}
 
-	router := gin.Default()
-	router.Use(middlewares.CORSMiddleware())
+	var signingKeys *security.KeySet
+	if cfg.JWTAlgorithm != "HS256" {
+		signingKeys, err = security.LoadKeySet(cfg.JWTKeysDir, cfg.JWTAlgorithm, cfg.JWTActiveKeyID)
+		if err != nil {
+			log.Fatalf("signing keys initialization failed: %v", err)
+		}
+	}
+
+	router := gin.Default()
+	router.Use(middlewares.CORSMiddleware())
+
+	if cfg.OIDCFakeProvider && strings.EqualFold(cfg.AppEnv, "dev") {
+		if err := mountFakeOIDCProvider(router, cfg); err != nil {
+			log.Fatalf("fake oidc provider initialization failed: %v", err)
+		}
+	}
+
+	paymentGateway, err := newPaymentGateway(router, cfg)
+	if err != nil {
+		log.Fatalf("payment gateway initialization failed: %v", err)
+	}
+
+	// Class times are expressed in the gym's time zone.
+	calendarLocation, err := time.LoadLocation(cfg.CalendarTimezone)
+	if err != nil {
+		log.Fatalf("calendar time zone %q: %v", cfg.CalendarTimezone, err)
+	}
+
+	// Domain events are recorded in the outbox and delivered in the background.
+	channels, err := newNotificationChannels(cfg)
+	if err != nil {
+		log.Fatalf("notification channels initialization failed: %v", err)
+	}
+	channelNames := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		channelNames = append(channelNames, channel.Name())
+	}
+	eventBus := events.NewBus()
+	eventBus.Subscribe(notifications.NewOutbox(channelNames, cfg.NotifyWebhookURL))
+	outboxWorker := notifications.NewWorker(db, channels, time.Duration(cfg.OutboxPollSeconds)*time.Second, cfg.OutboxMaxAttempts)
+	go outboxWorker.Run(context.Background())
+	reminderScheduler := notifications.NewReminderScheduler(db, eventBus, notifications.SystemClock{}, calendarLocation, time.Duration(cfg.ReminderLeadHours)*time.Hour, time.Minute)
+	go reminderScheduler.Run(context.Background())
+
+	// Admin-managed webhook subscriptions get their own signed deliveries.
+	eventBus.Subscribe(webhooks.NewDispatcher())
+	webhookWorker := webhooks.NewWorker(db, time.Duration(cfg.OutboxPollSeconds)*time.Second, cfg.WebhookMaxAttempts)
+	go webhookWorker.Run(context.Background())
+
+	// Committed seat changes are pushed to the availability streams.
+	availabilityBroker := realtime.NewMemoryBroker()
+
+	// Activity search; the index follows committed activity changes.
+	searchIndex, err := newSearchIndex(db, cfg)
+	if err != nil {
+		log.Fatalf("search index initialization failed: %v", err)
+	}
+	eventBus.AfterCommit(search.NewIndexer(searchIndex, db))
+
+	// Uploaded images, served back under /api/images.
+	blobStore, err := newBlobStore(router, cfg)
+	if err != nil {
+		log.Fatalf("blob storage initialization failed: %v", err)
+	}
 
 	// Initialize services.
-	authService := services.NewAuthService(db, cfg)
-	userService := services.NewUserService(db)
-	activityService := services.NewActivityService(db)
-	enrollmentService := services.NewEnrollmentService(db)
+	authService := services.NewAuthService(db, cfg, signingKeys)
+	userService := services.NewUserService(db, eventBus)
+	membershipService := services.NewMembershipService(db, cfg.RequireMembership)
+	paymentHold := time.Duration(cfg.PaymentHoldMinutes) * time.Minute
+	waitlist := services.NewWaitlist(db, membershipService, eventBus, calendarLocation, paymentHold)
+	activityService := services.NewActivityService(db, eventBus, searchIndex, waitlist, calendarLocation)
+	categoryService := services.NewCategoryService(db)
+	imageService := services.NewImageService(db, eventBus, blobStore, "/api/images", int64(cfg.ImageMaxBytes))
+	invoiceService := services.NewInvoiceService(db, cfg.InvoiceBranch, cfg.InvoiceIssuerName, calendarLocation)
+	paymentService := services.NewPaymentService(db, paymentGateway, invoiceService, membershipService, waitlist, eventBus, cfg.PaymentsCurrency, paymentHold)
+	enrollmentService := services.NewEnrollmentService(db, membershipService, paymentService, waitlist, eventBus, calendarLocation)
+	oidcService := services.NewOIDCService(db, cfg, eventBus)
+	apiKeyService := services.NewAPIKeyService(db)
+	rbacService := services.NewRBACService(db)
+	preferenceService := services.NewNotificationPreferenceService(db, cfg.ReminderLeadHours)
+	eventBus.AfterCommit(realtime.NewAvailabilityFeed(availabilityBroker, activityService.GetActivityByID))
+	webhookService := services.NewWebhookService(db)
+	calendarService := services.NewCalendarService(db, enrollmentService, calendarLocation)
+	auditService := services.NewAuditService(db)
+	seasonService := services.NewSeasonService(db, eventBus, enrollmentService, calendarLocation)
+	// Enrollments of a season end with it; the closer lets them go once the season is over.
+	go seasonService.RunCloser(context.Background(), time.Hour)
+	// Seats whose payment hold lapsed go back to the waitlist.
+	go waitlist.RunHoldExpiry(context.Background(), time.Minute)
 
 	// Initialize handlers.
 	healthHandler := handlers.NewHealthHandler()
+	jwksHandler := handlers.NewJWKSHandler(authService)
 	authHandler := handlers.NewAuthHandler(authService, userService)
-	activitiesHandler := handlers.NewActivitiesHandler(activityService)
+	oidcHandler := handlers.NewOIDCHandler(authService, oidcService)
+	activitiesHandler := handlers.NewActivitiesHandler(activityService, availabilityBroker)
 	enrollmentsHandler := handlers.NewEnrollmentsHandler(enrollmentService)
-	adminActivitiesHandler := handlers.NewAdminActivitiesHandler(activityService)
+	adminActivitiesHandler := handlers.NewAdminActivitiesHandler(activityService, imageService)
+	adminEnrollmentsHandler := handlers.NewAdminEnrollmentsHandler(enrollmentService)
+	adminAPIKeysHandler := handlers.NewAdminAPIKeysHandler(apiKeyService)
+	adminRolesHandler := handlers.NewAdminRolesHandler(rbacService)
+	adminUsersHandler := handlers.NewAdminUsersHandler(userService)
+	adminWebhooksHandler := handlers.NewAdminWebhooksHandler(webhookService)
+	membershipsHandler := handlers.NewMembershipsHandler(membershipService)
+	paymentsHandler := handlers.NewPaymentsHandler(paymentService)
+	invoicesHandler := handlers.NewInvoicesHandler(invoiceService)
+	preferencesHandler := handlers.NewNotificationPreferencesHandler(preferenceService)
+	calendarHandler := handlers.NewCalendarHandler(calendarService)
+	scheduleHandler := handlers.NewScheduleHandler(activityService)
+	categoriesHandler := handlers.NewCategoriesHandler(categoryService)
+	imagesHandler := handlers.NewImagesHandler(imageService)
+	auditHandler := handlers.NewAuditHandler(auditService, activityService)
+	seasonsHandler := handlers.NewSeasonsHandler(seasonService)
 
-	// Register health route.
+	// Register health and key discovery routes.
 	healthHandler.RegisterRoutes(router)
+	jwksHandler.RegisterRoutes(router)
 
 	apiGroup := router.Group("/api")
 	authHandler.RegisterRoutes(apiGroup)
-	activitiesHandler.RegisterRoutes(apiGroup)
+	oidcHandler.RegisterRoutes(apiGroup)
+	categoriesHandler.RegisterRoutes(apiGroup)
+	seasonsHandler.RegisterRoutes(apiGroup)
+	imagesHandler.RegisterRoutes(apiGroup)
+	paymentsHandler.RegisterWebhookRoutes(apiGroup)
+	calendarHandler.RegisterFeedRoutes(apiGroup)
+
+	authMiddleware := middlewares.NewAuthMiddleware(authService, apiKeyService)
 
-	authMiddleware := middlewares.NewAuthMiddleware(authService)
+	// Public routes that personalize their response for a logged-in member.
+	personalized := apiGroup.Group("")
+	personalized.Use(authMiddleware.Optional())
+	activitiesHandler.RegisterRoutes(personalized)
+	scheduleHandler.RegisterRoutes(personalized)
 
 	protected := apiGroup.Group("")
 	protected.Use(authMiddleware.Handle())
 	enrollmentsHandler.RegisterRoutes(protected)
+	membershipsHandler.RegisterRoutes(protected)
+	paymentsHandler.RegisterRoutes(protected)
+	invoicesHandler.RegisterRoutes(protected)
+	preferencesHandler.RegisterRoutes(protected)
+	calendarHandler.RegisterRoutes(protected)
+	seasonsHandler.RegisterMemberRoutes(protected)
 
+	// Admin routes declare the permission they need; API keys are checked against their scopes.
+	permissionMiddleware := middlewares.NewPermissionMiddleware(rbacService)
 	adminGroup := apiGroup.Group("")
-	adminGroup.Use(authMiddleware.Handle(), middlewares.AdminMiddleware())
-	adminActivitiesHandler.RegisterRoutes(adminGroup)
+	adminGroup.Use(authMiddleware.Handle())
+	adminActivitiesHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	adminEnrollmentsHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	adminAPIKeysHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	adminRolesHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	adminUsersHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	adminWebhooksHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	membershipsHandler.RegisterAdminRoutes(adminGroup, permissionMiddleware.Require)
+	categoriesHandler.RegisterAdminRoutes(adminGroup, permissionMiddleware.Require)
+	imagesHandler.RegisterAdminRoutes(adminGroup, permissionMiddleware.Require)
+	auditHandler.RegisterRoutes(adminGroup, permissionMiddleware.Require)
+	seasonsHandler.RegisterAdminRoutes(adminGroup, permissionMiddleware.Require)
 
 	if err := router.Run(":" + cfg.ServerPort); err != nil {
 		log.Fatalf("server failed to start: %v", err)
 	}
 }
+
+// mountFakeOIDCProvider serves an in-process identity provider under /dev/oidc and points the
+// OIDC client at it, so the external login flow can be exercised locally.
+func mountFakeOIDCProvider(router *gin.Engine, cfg *config.Config) error {
+	baseURL := "http://localhost:" + cfg.ServerPort
+	if cfg.OIDCIssuer == "" {
+		cfg.OIDCIssuer = baseURL + "/dev/oidc"
+	}
+	if cfg.OIDCClientID == "" {
+		cfg.OIDCClientID = "gad-dev"
+	}
+	if cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = baseURL + "/api/auth/oidc/callback"
+	}
+
+	provider, err := oidcfake.New(cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret)
+	if err != nil {
+		return err
+	}
+	provider.AddUser(oidcfake.User{Subject: "fake-socia", Email: "socia@example.com", EmailVerified: true, Name: "Socia Demo"})
+	provider.AddUser(oidcfake.User{Subject: "fake-admin", Email: "admin@example.com", EmailVerified: true, Name: "Admin"})
+
+	router.Any("/dev/oidc/*path", gin.WrapH(http.StripPrefix("/dev/oidc", provider)))
+	log.Printf("fake oidc provider mounted at %s", cfg.OIDCIssuer)
+	return nil
+}
+
+// newPaymentGateway builds the configured payment provider. The fake provider is mounted under
+// /dev/payments and only allowed in dev; its checkouts are completed with
+// POST /dev/payments/{ref}/complete?status=approved|rejected.
+func newPaymentGateway(router *gin.Engine, cfg *config.Config) (payments.PaymentGateway, error) {
+	switch cfg.PaymentsProvider {
+	case "":
+		return nil, nil
+	case paymentfake.Name:
+		if !strings.EqualFold(cfg.AppEnv, "dev") {
+			return nil, fmt.Errorf("the %s payment provider is only available with APP_ENV=dev", paymentfake.Name)
+		}
+		secret := cfg.PaymentsWebhookSecret
+		if secret == "" {
+			buf := make([]byte, 32)
+			if _, err := rand.Read(buf); err != nil {
+				return nil, err
+			}
+			secret = hex.EncodeToString(buf)
+		}
+
+		baseURL := "http://localhost:" + cfg.ServerPort
+		gateway := paymentfake.New(baseURL+"/dev/payments", baseURL+"/api/payments/webhook/"+paymentfake.Name, secret)
+		router.Any("/dev/payments/*path", gin.WrapH(http.StripPrefix("/dev/payments", gateway)))
+		log.Printf("fake payment provider mounted at %s", gateway.BaseURL)
+		return gateway, nil
+	default:
+		return nil, fmt.Errorf("unsupported payment provider %q", cfg.PaymentsProvider)
+	}
+}
+
+// newSearchIndex builds the index selected by SEARCH_BACKEND. The memory index is filled from the
+// database before the server starts.
+func newSearchIndex(db *gorm.DB, cfg *config.Config) (search.Index, error) {
+	switch cfg.SearchBackend {
+	case "memory":
+		index := search.NewMemoryIndex()
+		if err := search.Populate(index, db); err != nil {
+			return nil, err
+		}
+		return index, nil
+	case "sql":
+		return search.NewSQLIndex(db), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", cfg.SearchBackend)
+	}
+}
+
+// newBlobStore builds the storage selected by STORAGE_BACKEND. In dev, the s3 backend without
+// S3_ENDPOINT uses an in-memory S3 stand-in mounted under /dev/s3, so the S3 path can be exercised
+// locally; its objects are lost on restart.
+func newBlobStore(router *gin.Engine, cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return storage.NewLocalStore(cfg.StorageLocalDir)
+	case "s3":
+		s3Config := storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		}
+		if s3Config.Endpoint == "" && strings.EqualFold(cfg.AppEnv, "dev") {
+			if s3Config.Bucket == "" {
+				s3Config.Bucket = "gad-dev"
+			}
+			if s3Config.AccessKeyID == "" || s3Config.SecretAccessKey == "" {
+				s3Config.AccessKeyID, s3Config.SecretAccessKey = "gad-dev", "gad-dev-secret"
+			}
+			s3Config.Endpoint = "http://localhost:" + cfg.ServerPort + "/dev/s3"
+			s3Config.PathStyle = true
+			server := s3fake.New("/dev/s3", s3Config.Region, s3Config.AccessKeyID, s3Config.SecretAccessKey)
+			router.Any("/dev/s3/*path", gin.WrapH(server))
+			log.Printf("fake s3 service mounted at %s", s3Config.Endpoint)
+		}
+		return storage.NewS3Store(s3Config)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+// newNotificationChannels builds the delivery channels listed in NOTIFICATION_CHANNELS.
+func newNotificationChannels(cfg *config.Config) ([]notifications.Channel, error) {
+	var channels []notifications.Channel
+	for _, name := range strings.Split(cfg.NotificationChannels, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case notifications.ChannelLog:
+			channels = append(channels, notifications.NewLogChannel())
+		case notifications.ChannelSMTP:
+			if cfg.SMTPHost == "" {
+				return nil, fmt.Errorf("SMTP_HOST is required for the %s channel", notifications.ChannelSMTP)
+			}
+			channels = append(channels, notifications.NewSMTPChannel(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom))
+		case notifications.ChannelWebhook:
+			if cfg.NotifyWebhookURL == "" {
+				return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL is required for the %s channel", notifications.ChannelWebhook)
+			}
+			channels = append(channels, notifications.NewWebhookChannel(cfg.NotifyWebhookSecret))
+		default:
+			return nil, fmt.Errorf("unknown notification channel %q", name)
+		}
+	}
+	return channels, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 )
 
 // Config holds application configuration derived from environment variables.
@@ -15,6 +16,74 @@ type Config struct {
 	DBName     string
 	AppEnv     string
 	JWTSecret  string
+	// JWTAlgorithm selects how new tokens are signed: RS256, EdDSA or HS256 (legacy).
+	JWTAlgorithm   string
+	JWTKeysDir     string
+	JWTActiveKeyID string
+	// JWTAcceptHS256 keeps validating tokens signed with JWTSecret during the migration.
+	JWTAcceptHS256 bool
+
+	// OpenID Connect login. Disabled unless OIDCIssuer is set.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       string
+	// OIDCFakeProvider mounts an in-process provider under /dev/oidc (dev only).
+	OIDCFakeProvider bool
+
+	// RequireMembership blocks enrollments of users without an active membership.
+	RequireMembership bool
+
+	// PaymentsProvider selects the payment gateway; empty disables paid checkouts.
+	PaymentsProvider      string
+	PaymentsWebhookSecret string
+	PaymentsCurrency      string
+	// PaymentHoldMinutes is how long a paid enrollment keeps its seat while waiting for payment.
+	PaymentHoldMinutes int
+
+	// InvoiceBranch prefixes invoice numbers; each branch has its own gap-free sequence.
+	InvoiceBranch     string
+	InvoiceIssuerName string
+
+	// NotificationChannels is a comma separated list of smtp, log and webhook.
+	NotificationChannels string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUser             string
+	SMTPPassword         string
+	SMTPFrom             string
+	NotifyWebhookURL     string
+	NotifyWebhookSecret  string
+	// OutboxPollSeconds and OutboxMaxAttempts tune the delivery worker.
+	OutboxPollSeconds int
+	OutboxMaxAttempts int
+
+	// WebhookMaxAttempts bounds the retries of an outgoing webhook delivery; it is polled like the outbox.
+	WebhookMaxAttempts int
+
+	// ReminderLeadHours is how long before a class its reminder is sent, unless the user overrides it.
+	ReminderLeadHours int
+
+	// SearchBackend selects the activity search index: memory (in-process BM25) or sql (MySQL FULLTEXT).
+	SearchBackend string
+
+	// CalendarTimezone is the IANA zone the class times are expressed in, used by the iCalendar
+	// feeds and the class reminders.
+	CalendarTimezone string
+
+	// StorageBackend selects where uploaded images are kept: local (StorageLocalDir) or s3.
+	StorageBackend  string
+	StorageLocalDir string
+	// S3 settings for the s3 backend; without S3Endpoint in dev an in-process fake is mounted at /dev/s3.
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool
+	// ImageMaxBytes bounds the size of an uploaded image file.
+	ImageMaxBytes int
 }
 
 // Load reads environment variables and builds a Config struct. Panic on missing vars.
@@ -28,6 +97,57 @@ func Load() *Config {
 		DBName:     mustGetEnv("DB_NAME"),
 		AppEnv:     getEnv("APP_ENV", "prod"),
 		JWTSecret:  mustGetEnv("JWT_SECRET"),
+
+		JWTAlgorithm:   getEnv("JWT_ALGORITHM", "RS256"),
+		JWTKeysDir:     getEnv("JWT_KEYS_DIR", "./keys"),
+		JWTActiveKeyID: getEnv("JWT_ACTIVE_KID", ""),
+		JWTAcceptHS256: getEnvBool("JWT_ACCEPT_HS256", true),
+
+		OIDCIssuer:       getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:       getEnv("OIDC_SCOPES", "openid email profile"),
+		OIDCFakeProvider: getEnvBool("OIDC_FAKE_PROVIDER", false),
+
+		RequireMembership: getEnvBool("REQUIRE_MEMBERSHIP", false),
+
+		PaymentsProvider:      getEnv("PAYMENTS_PROVIDER", ""),
+		PaymentsWebhookSecret: getEnv("PAYMENTS_WEBHOOK_SECRET", ""),
+		PaymentsCurrency:      getEnv("PAYMENTS_CURRENCY", "ARS"),
+		PaymentHoldMinutes:    getEnvInt("PAYMENT_HOLD_MINUTES", 15),
+
+		InvoiceBranch:     getEnv("BRANCH_CODE", "0001"),
+		InvoiceIssuerName: getEnv("INVOICE_ISSUER_NAME", "Gestión de Actividades Deportivas"),
+
+		NotificationChannels: getEnv("NOTIFICATION_CHANNELS", "log"),
+		SMTPHost:             getEnv("SMTP_HOST", ""),
+		SMTPPort:             getEnv("SMTP_PORT", "587"),
+		SMTPUser:             getEnv("SMTP_USER", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:             getEnv("SMTP_FROM", "no-reply@example.com"),
+		NotifyWebhookURL:     getEnv("NOTIFY_WEBHOOK_URL", ""),
+		NotifyWebhookSecret:  getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+		OutboxPollSeconds:    getEnvInt("OUTBOX_POLL_SECONDS", 5),
+		OutboxMaxAttempts:    getEnvInt("OUTBOX_MAX_ATTEMPTS", 8),
+
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+
+		ReminderLeadHours: getEnvInt("REMINDER_LEAD_HOURS", 24),
+
+		SearchBackend: getEnv("SEARCH_BACKEND", "memory"),
+
+		CalendarTimezone: getEnv("CALENDAR_TIMEZONE", "America/Argentina/Buenos_Aires"),
+
+		StorageBackend:    getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalDir:   getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PathStyle:       getEnvBool("S3_PATH_STYLE", false),
+		ImageMaxBytes:     getEnvInt("IMAGE_MAX_BYTES", 5<<20),
 	}
 	return cfg
 }
@@ -46,3 +166,27 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		panic(fmt.Sprintf("environment variable %s must be an integer", key))
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		panic(fmt.Sprintf("environment variable %s must be a boolean", key))
+	}
+	return parsed
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"log"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// BackfillActivityStatus gives a status to the activities created before the lifecycle existed:
+// the status column starts as published, so the ones that had been deactivated become archived.
+// Listed activities are never inactive afterwards, so running it again changes nothing.
+func BackfillActivityStatus(db *gorm.DB) error {
+	result := db.Model(&models.Activity{}).
+		Where("is_active = ? AND status IN ?", false, []string{models.ActivityPublished, models.ActivityPaused}).
+		Update("status", models.ActivityArchived)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("activities: archived %d deactivated activities", result.RowsAffected)
+	}
+	return nil
+}
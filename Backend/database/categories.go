@@ -0,0 +1,49 @@
+package database
+
+import (
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/search"
+	"gorm.io/gorm"
+)
+
+// BackfillCategories files the activities created before the category catalogue existed: each
+// distinct free-text category becomes a catalogue entry (variants like "Fuerza" and "fuerza"
+// share one) and its activities point to it.
+func BackfillCategories(db *gorm.DB) error {
+	var names []string
+	if err := db.Model(&models.Activity{}).Where("category_id IS NULL").Distinct().Pluck("category", &names).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		slug := search.Slug(name)
+		display := capitalize(strings.TrimSpace(name))
+		if slug == "" {
+			slug, display = "sin-categoria", "Sin categoría"
+		}
+
+		var category models.Category
+		if err := db.Where(models.Category{Slug: slug}).Attrs(models.Category{Name: display}).FirstOrCreate(&category).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&models.Activity{}).
+			Where("category_id IS NULL AND category = ?", name).
+			Updates(map[string]interface{}{"category_id": category.ID, "category": slug}).Error; err != nil {
+			return err
+		}
+		log.Printf("categories: filed the activities of %q under %s", name, slug)
+	}
+	return nil
+}
+
+func capitalize(text string) string {
+	first, size := utf8.DecodeRuneInString(text)
+	if first == utf8.RuneError {
+		return text
+	}
+	return string(unicode.ToUpper(first)) + text[size:]
+}
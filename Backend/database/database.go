@@ -26,10 +26,48 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&models.User{}, &models.Activity{}, &models.Enrollment{}); err != nil {
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Category{},
+		&models.Season{},
+		&models.Activity{},
+		&models.EligibilityRule{},
+		&models.Enrollment{},
+		&models.UserIdentity{},
+		&models.APIKey{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.MembershipPlan{},
+		&models.PlanCategoryQuota{},
+		&models.Membership{},
+		&models.Payment{},
+		&models.InvoiceSequence{},
+		&models.Invoice{},
+		&models.InvoiceLine{},
+		&models.OutboxMessage{},
+		&models.NotificationPreference{},
+		&models.ReminderLog{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.CalendarToken{},
+		&models.ActivityCancellation{},
+		&models.AuditLog{},
+	); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := EnsureDefaultRoles(db); err != nil {
+		return nil, fmt.Errorf("failed to create default roles: %w", err)
+	}
+
+	if err := BackfillCategories(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill activity categories: %w", err)
+	}
+
+	if err := BackfillActivityStatus(db); err != nil {
+		return nil, fmt.Errorf("failed to backfill activity statuses: %w", err)
+	}
+
 	if strings.EqualFold(cfg.AppEnv, "dev") {
 		if err := Seed(db); err != nil {
 			return nil, fmt.Errorf("failed to seed database: %w", err)
@@ -0,0 +1,45 @@
+// Package dbtest opens throwaway SQLite databases for tests that need GORM but not MySQL.
+package dbtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a database in the test's temporary directory with the given models migrated.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dialector := dialector{sqlite.Open(filepath.Join(t.TempDir(), "test.db"))}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// dialector is SQLite without the MySQL FULLTEXT index used by search.
+type dialector struct {
+	gorm.Dialector
+}
+
+func (d dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator{d.Dialector.Migrator(db)}
+}
+
+type migrator struct {
+	gorm.Migrator
+}
+
+func (m migrator) CreateIndex(value interface{}, name string) error {
+	if name == "idx_activities_search" {
+		return nil
+	}
+	return m.Migrator.CreateIndex(value, name)
+}
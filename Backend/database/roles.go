@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"log"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"gorm.io/gorm"
+)
+
+var defaultRoleDescriptions = map[string]string{
+	security.RoleAdmin:     "Acceso total al sistema",
+	security.RoleSocio:     "Socio del gimnasio",
+	security.RoleRecepcion: "Recepción: gestiona listas de inscriptos y asistencias",
+}
+
+// EnsureDefaultRoles creates the built-in roles when missing. Existing roles are left untouched
+// so permission changes made through the admin API survive restarts.
+func EnsureDefaultRoles(db *gorm.DB) error {
+	for name, perms := range security.DefaultRolePermissions() {
+		var role models.Role
+		err := db.Where("name = ?", name).First(&role).Error
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		role = models.Role{
+			Name:        name,
+			Description: defaultRoleDescriptions[name],
+			IsSystem:    true,
+		}
+		for _, perm := range perms {
+			role.Permissions = append(role.Permissions, models.RolePermission{Permission: perm})
+		}
+		if err := db.Create(&role).Error; err != nil {
+			return err
+		}
+		log.Printf("roles: created default role %s", name)
+	}
+	return nil
+}
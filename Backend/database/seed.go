@@ -22,6 +22,7 @@ func Seed(db *gorm.DB) error {
 		users := []models.User{
 			{Name: "Admin", Email: "admin@example.com", PasswordHash: hashedPassword, Role: "admin"},
 			{Name: "Socia Demo", Email: "socia@example.com", PasswordHash: hashedPassword, Role: "socio"},
+			{Name: "Recepcion Demo", Email: "recepcion@example.com", PasswordHash: hashedPassword, Role: "recepcion"},
 		}
 		if err := db.Create(&users).Error; err != nil {
 			return err
@@ -29,6 +30,22 @@ func Seed(db *gorm.DB) error {
 		log.Println("seed: created default users")
 	}
 
+	var categoryCount int64
+	if err := db.Model(&models.Category{}).Count(&categoryCount).Error; err != nil {
+		return err
+	}
+	if categoryCount == 0 {
+		categories := []models.Category{
+			{Slug: "yoga", Name: "Yoga", Color: "#8E6CC2", Icon: "lotus"},
+			{Slug: "fuerza", Name: "Fuerza", Color: "#D9534F", Icon: "dumbbell"},
+			{Slug: "cardio", Name: "Cardio", Color: "#F0AD4E", Icon: "heart-pulse"},
+		}
+		if err := db.Create(&categories).Error; err != nil {
+			return err
+		}
+		log.Println("seed: created activity categories")
+	}
+
 	var activityCount int64
 	if err := db.Model(&models.Activity{}).Count(&activityCount).Error; err != nil {
 		return err
@@ -69,11 +86,34 @@ func Seed(db *gorm.DB) error {
 				ImageURL:    "",
 			},
 		}
+		for i := range activities {
+			var category models.Category
+			if err := db.Where("slug = ?", activities[i].Category).First(&category).Error; err != nil {
+				return err
+			}
+			activities[i].CategoryID = &category.ID
+			activities[i].SetStatus(models.ActivityPublished)
+		}
 		if err := db.Create(&activities).Error; err != nil {
 			return err
 		}
 		log.Println("seed: created sample activities")
 	}
 
+	var planCount int64
+	if err := db.Model(&models.MembershipPlan{}).Count(&planCount).Error; err != nil {
+		return err
+	}
+	if planCount == 0 {
+		plans := []models.MembershipPlan{
+			{Name: "2 clases por semana", Description: "Hasta dos clases semanales.", WeeklyQuota: 2, DurationDays: 30, IsActive: true},
+			{Name: "Ilimitado", Description: "Clases sin limite semanal.", WeeklyQuota: 0, DurationDays: 30, IsActive: true},
+		}
+		if err := db.Create(&plans).Error; err != nil {
+			return err
+		}
+		log.Println("seed: created membership plans")
+	}
+
 	return nil
 }
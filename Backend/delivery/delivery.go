@@ -0,0 +1,96 @@
+// Package delivery is the polling loop shared by the background senders (notification outbox and
+// webhook deliveries): due rows are claimed under a lease, sent one by one and retried with
+// exponential backoff until they are given up.
+package delivery
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// claimLease keeps a claimed row away from other workers while it is being sent.
+	claimLease  = 2 * time.Minute
+	baseBackoff = 30 * time.Second
+)
+
+// Run calls deliver every interval until ctx is cancelled, logging its errors under name.
+func Run(ctx context.Context, name string, interval time.Duration, deliver func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := deliver(ctx); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Claim returns up to limit rows of T that are "pendiente" and due, oldest first, and pushes
+// their next attempt past the lease. Several workers can claim at once: rows are locked with
+// SKIP LOCKED, so each one is handed to a single worker.
+func Claim[T any](db *gorm.DB, limit int) ([]T, error) {
+	var rows []T
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var ids []uint
+		if err := tx.Model(new(T)).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: clause.LockingOptionsSkipLocked}).
+			Where("status = ? AND next_attempt_at <= ?", "pendiente", now).
+			Order("id ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(new(T)).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimLease)).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Order("id ASC").Find(&rows).Error
+	})
+	return rows, err
+}
+
+// Outcome returns the columns to save after the attempt-th try to send a row: "enviado" with
+// sentColumn stamped when sendErr is nil, "fallido" once maxAttempts is reached or giveUp is set,
+// otherwise a retry after Backoff.
+func Outcome(attempts, maxAttempts int, maxBackoff time.Duration, sendErr error, giveUp bool, sentColumn string) map[string]interface{} {
+	updates := map[string]interface{}{"attempts": attempts}
+	switch {
+	case sendErr == nil:
+		updates["status"] = "enviado"
+		updates[sentColumn] = time.Now()
+		updates["last_error"] = ""
+	case giveUp || attempts >= maxAttempts:
+		updates["status"] = "fallido"
+		updates["last_error"] = sendErr.Error()
+	default:
+		updates["next_attempt_at"] = time.Now().Add(Backoff(attempts, maxBackoff))
+		updates["last_error"] = sendErr.Error()
+	}
+	return updates
+}
+
+// Backoff doubles the wait after each failed attempt: 30s, 1m, 2m... capped at maxBackoff.
+func Backoff(attempts int, maxBackoff time.Duration) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
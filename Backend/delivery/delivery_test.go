@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+func TestClaimLeasesDueRows(t *testing.T) {
+	db := dbtest.Open(t, &models.OutboxMessage{})
+	now := time.Now()
+	messages := []models.OutboxMessage{
+		{EventType: "a", Channel: "log", Recipient: "1", Status: "pendiente", NextAttemptAt: now.Add(-time.Minute)},
+		{EventType: "b", Channel: "log", Recipient: "2", Status: "pendiente", NextAttemptAt: now.Add(-time.Second)},
+		{EventType: "c", Channel: "log", Recipient: "3", Status: "pendiente", NextAttemptAt: now.Add(time.Hour)},
+		{EventType: "d", Channel: "log", Recipient: "4", Status: "enviado", NextAttemptAt: now.Add(-time.Hour)},
+	}
+	if err := db.Create(&messages).Error; err != nil {
+		t.Fatalf("seed messages: %v", err)
+	}
+
+	claimed, err := Claim[models.OutboxMessage](db, 1)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != messages[0].ID {
+		t.Fatalf("first claim = %+v, want only message %d", claimed, messages[0].ID)
+	}
+
+	claimed, err = Claim[models.OutboxMessage](db, 10)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != messages[1].ID {
+		t.Fatalf("second claim = %+v, want only message %d", claimed, messages[1].ID)
+	}
+
+	var leased models.OutboxMessage
+	if err := db.First(&leased, messages[0].ID).Error; err != nil {
+		t.Fatalf("reload message: %v", err)
+	}
+	if !leased.NextAttemptAt.After(now.Add(claimLease - time.Minute)) {
+		t.Fatalf("next_attempt_at = %v, want it pushed past the lease", leased.NextAttemptAt)
+	}
+
+	if claimed, err = Claim[models.OutboxMessage](db, 10); err != nil || len(claimed) != 0 {
+		t.Fatalf("claim with everything leased = %d rows, %v; want none", len(claimed), err)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	sendErr := errors.New("boom")
+
+	sent := Outcome(1, 3, time.Hour, nil, false, "sent_at")
+	if sent["status"] != "enviado" || sent["sent_at"] == nil || sent["last_error"] != "" {
+		t.Fatalf("success = %v", sent)
+	}
+	retry := Outcome(2, 3, time.Hour, sendErr, false, "sent_at")
+	if _, ok := retry["status"]; ok || retry["next_attempt_at"] == nil || retry["last_error"] != "boom" {
+		t.Fatalf("retry = %v", retry)
+	}
+	if failed := Outcome(3, 3, time.Hour, sendErr, false, "sent_at"); failed["status"] != "fallido" {
+		t.Fatalf("last attempt = %v, want fallido", failed)
+	}
+	if failed := Outcome(1, 3, time.Hour, sendErr, true, "sent_at"); failed["status"] != "fallido" {
+		t.Fatalf("give up = %v, want fallido", failed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{9, 2 * time.Hour},
+		{20, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, 2*time.Hour); got != tt.want {
+			t.Fatalf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
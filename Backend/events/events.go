@@ -0,0 +1,142 @@
+// Package events carries domain events from services to the subsystems that react to them.
+// Events are recorded on the same database transaction as the change that caused them, so a
+// rolled back change never produces side effects and a committed one never loses them.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event types.
+const (
+	EnrollmentConfirmed  = "enrollment.confirmed"
+	EnrollmentCancelled  = "enrollment.cancelled"
+	EnrollmentWaitlisted = "enrollment.waitlisted"
+	EnrollmentPromoted   = "enrollment.promoted"
+	// EnrollmentHeld and EnrollmentReleased track seats held while a payment is pending.
+	EnrollmentHeld      = "enrollment.held"
+	EnrollmentReleased  = "enrollment.released"
+	ActivityCreated     = "activity.created"
+	ActivityUpdated     = "activity.updated"
+	ActivityDeactivated = "activity.deactivated"
+	ActivityRescheduled = "activity.rescheduled"
+	ClassReminder       = "class.reminder"
+	UserRegistered      = "user.registered"
+	// ActivityPaused, ActivityPublished and ActivityPurged follow the lifecycle; archiving an
+	// activity is ActivityDeactivated.
+	ActivityPaused    = "activity.paused"
+	ActivityPublished = "activity.published"
+	ActivityPurged    = "activity.purged"
+	// EnrollmentFinished closes an enrollment, or a seat still waiting for one, when the season of
+	// its activity ends.
+	EnrollmentFinished = "enrollment.finished"
+	// ActivitySessionCancelled and ActivitySessionRestored call off, or bring back, a single
+	// session of an activity; Data carries its "date" and the cancellation "reason".
+	ActivitySessionCancelled = "activity.session_cancelled"
+	ActivitySessionRestored  = "activity.session_restored"
+)
+
+// Event describes something that happened in the domain. IDs that do not apply are zero.
+type Event struct {
+	Type         string                 `json:"type"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+	UserID       uint                   `json:"user_id,omitempty"`
+	ActivityID   uint                   `json:"activity_id,omitempty"`
+	EnrollmentID uint                   `json:"enrollment_id,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+// New builds an event stamped with the current time.
+func New(eventType string) Event {
+	return Event{Type: eventType, OccurredAt: time.Now()}
+}
+
+// Recorder persists what an event implies (outbox rows, audit entries...) using tx.
+type Recorder interface {
+	Record(tx *gorm.DB, evt Event) error
+}
+
+// Listener reacts to an event after the transaction that recorded it committed. It runs on the
+// committing goroutine, so slow work should be handed off.
+type Listener func(evt Event)
+
+// Bus fans an event out to every subscribed recorder and, once committed, to its listeners.
+type Bus struct {
+	recorders []Recorder
+	listeners []Listener
+
+	mu sync.Mutex
+	// pending holds the events recorded by each open Bus.Transaction, keyed by its connection.
+	pending map[gorm.ConnPool][]Event
+}
+
+func NewBus() *Bus {
+	return &Bus{pending: map[gorm.ConnPool][]Event{}}
+}
+
+// Subscribe adds a recorder. It must be called during startup, before events are recorded.
+func (b *Bus) Subscribe(recorder Recorder) {
+	b.recorders = append(b.recorders, recorder)
+}
+
+// AfterCommit adds a listener. It must be called during startup, before events are recorded.
+// Only events recorded inside Bus.Transaction reach listeners.
+func (b *Bus) AfterCommit(listener Listener) {
+	b.listeners = append(b.listeners, listener)
+}
+
+// Record passes evt to each recorder on tx and stops at the first error, which should make the
+// caller roll back.
+func (b *Bus) Record(tx *gorm.DB, evt Event) error {
+	for _, recorder := range b.recorders {
+		if err := recorder.Record(tx, evt); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	if events, ok := b.pending[tx.Statement.ConnPool]; ok {
+		b.pending[tx.Statement.ConnPool] = append(events, evt)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Transaction runs fn like gorm's Transaction and, if it commits, hands the events recorded on
+// it to the listeners. Rolled back events are discarded.
+// A Transaction nested in another one leaves the events to the outer one.
+func (b *Bus) Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	b.mu.Lock()
+	_, nested := b.pending[db.Statement.ConnPool]
+	b.mu.Unlock()
+	if nested {
+		return db.Transaction(fn)
+	}
+
+	var conn gorm.ConnPool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		conn = tx.Statement.ConnPool
+		b.mu.Lock()
+		b.pending[conn] = []Event{}
+		b.mu.Unlock()
+		return fn(tx)
+	})
+
+	b.mu.Lock()
+	committed := b.pending[conn]
+	delete(b.pending, conn)
+	b.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	for _, evt := range committed {
+		for _, listener := range b.listeners {
+			listener(evt)
+		}
+	}
+	return nil
+}
@@ -3,43 +3,57 @@ package handlers
 import (
     "net/http"
     "strconv"
+    "strings"
+    "time"
 
+    "github.com/alesio/gestion-actividades-deportivas/realtime"
     "github.com/alesio/gestion-actividades-deportivas/services"
+    "github.com/gin-contrib/sse"
     "github.com/gin-gonic/gin"
 )
 
+// streamHeartbeat keeps idle SSE connections open through proxies that close silent ones.
+const streamHeartbeat = 25 * time.Second
+
+// maxStreamIDs bounds how many activities a single stream can watch.
+const maxStreamIDs = 100
+
 // ActivitiesHandler serves public activities endpoints.
 type ActivitiesHandler struct {
     activityService *services.ActivityService
+    broker          realtime.Broker
 }
 
-func NewActivitiesHandler(activityService *services.ActivityService) *ActivitiesHandler {
-    return &ActivitiesHandler{activityService: activityService}
+func NewActivitiesHandler(activityService *services.ActivityService, broker realtime.Broker) *ActivitiesHandler {
+    return &ActivitiesHandler{activityService: activityService, broker: broker}
 }
 
+// RegisterRoutes mounts the activities on a group using AuthMiddleware.Optional: they are public,
+// and the listing says whether the caller is eligible for each activity when a valid token is sent.
 func (h *ActivitiesHandler) RegisterRoutes(router *gin.RouterGroup) {
     router.GET("/activities", h.ListActivities)
+    router.GET("/activities/stream", h.StreamAvailability)
     router.GET("/activities/:id", h.GetActivity)
 }
 
 func (h *ActivitiesHandler) ListActivities(c *gin.Context) {
-    var filter services.ActivityFilter
-    filter.Query = c.Query("q")
-    filter.Category = c.Query("category")
+    filter, ok := parseActivityFilter(c)
+    if !ok {
+        return
+    }
 
-    if dayStr := c.Query("day"); dayStr != "" {
-        if dayInt, err := strconv.Atoi(dayStr); err == nil {
-            filter.Day = &dayInt
-        }
+    page, ok := parsePageRequest(c, services.ActivitySortFields)
+    if !ok {
+        return
     }
 
-    activities, err := h.activityService.ListActivities(filter)
+    activities, info, err := h.activityService.ListActivities(filter, page, optionalUserID(c))
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list activities"})
+        respondPageError(c, err, "No se pudieron listar las actividades")
         return
     }
 
-    c.JSON(http.StatusOK, activities)
+    respondPage(c, activities, info)
 }
 
 func (h *ActivitiesHandler) GetActivity(c *gin.Context) {
@@ -58,3 +72,71 @@ func (h *ActivitiesHandler) GetActivity(c *gin.Context) {
 
     c.JSON(http.StatusOK, activity)
 }
+
+// StreamAvailability pushes seat availability as Server-Sent Events. ?ids=1,2,3 limits the stream
+// to those activities (all active ones otherwise). The stream opens with one "availability" event
+// per activity and then sends one on every change; "ping" events keep the connection alive.
+func (h *ActivitiesHandler) StreamAvailability(c *gin.Context) {
+    ids, ok := parseStreamIDs(c.Query("ids"))
+    if !ok {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "ids must be a comma separated list of up to 100 activity ids"})
+        return
+    }
+
+    // Subscribe before reading the snapshot so no change falls in between.
+    updates, cancel := h.broker.Subscribe(ids)
+    defer cancel()
+
+    activities, err := h.activityService.GetAvailability(ids)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load availability"})
+        return
+    }
+
+    c.Header("Content-Type", "text/event-stream")
+    c.Header("Cache-Control", "no-cache")
+    c.Header("Connection", "keep-alive")
+    c.Header("X-Accel-Buffering", "no")
+    c.Status(http.StatusOK)
+    for i := range activities {
+        c.Render(-1, sse.Event{Event: "availability", Data: realtime.Snapshot(&activities[i])})
+    }
+    c.Writer.Flush()
+
+    heartbeat := time.NewTicker(streamHeartbeat)
+    defer heartbeat.Stop()
+    for {
+        select {
+        case <-c.Request.Context().Done():
+            return
+        case update := <-updates:
+            c.Render(-1, sse.Event{
+                Id:    strconv.FormatUint(update.Seq, 10),
+                Event: "availability",
+                Data:  update,
+            })
+        case now := <-heartbeat.C:
+            c.Render(-1, sse.Event{Event: "ping", Data: now.Unix()})
+        }
+        c.Writer.Flush()
+    }
+}
+
+func parseStreamIDs(value string) ([]uint, bool) {
+    if value == "" {
+        return nil, true
+    }
+    parts := strings.Split(value, ",")
+    if len(parts) > maxStreamIDs {
+        return nil, false
+    }
+    ids := make([]uint, 0, len(parts))
+    for _, part := range parts {
+        id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+        if err != nil || id == 0 {
+            return nil, false
+        }
+        ids = append(ids, uint(id))
+    }
+    return ids, true
+}
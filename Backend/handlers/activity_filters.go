@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// parseActivityFilter reads the activity listing filters from the query string:
+//
+//	q=<text>                free-text search
+//	category=yoga,pilates   one or more categories (category=yoga&category=pilates works too)
+//	day=1,3                 one or more days, from 0 (Sunday) to 6 (Saturday)
+//	instructor=<text>       part of the instructor's name
+//	start_after=18:00       starting at that time or later
+//	start_before=12:00      starting before that time
+//	min_duration=45         lasting at least that many minutes
+//	max_duration=90         lasting at most that many minutes
+//	available=true          only activities with free seats
+//	season=3                activities of that season instead of the one in progress
+//
+// Every invalid value is reported in a single VALIDATION_ERROR response.
+func parseActivityFilter(c *gin.Context) (services.ActivityFilter, bool) {
+	filter := services.ActivityFilter{
+		Query:      strings.TrimSpace(c.Query("q")),
+		Categories: queryList(c, "category"),
+		Instructor: strings.TrimSpace(c.Query("instructor")),
+	}
+	var problems []string
+
+	for _, value := range queryList(c, "day") {
+		day, err := strconv.Atoi(value)
+		if err != nil || day < 0 || day > 6 {
+			problems = append(problems, "day debe estar entre 0 (domingo) y 6 (sábado): "+strconv.Quote(value))
+			continue
+		}
+		filter.Days = append(filter.Days, day)
+	}
+
+	var startAfter, startBefore time.Time
+	if value := c.Query("start_after"); value != "" {
+		parsed, err := time.Parse("15:04", value)
+		if err != nil {
+			problems = append(problems, "start_after debe tener formato HH:MM")
+		} else {
+			startAfter = parsed
+			filter.StartAfter = parsed.Format("15:04")
+		}
+	}
+	if value := c.Query("start_before"); value != "" {
+		parsed, err := time.Parse("15:04", value)
+		if err != nil {
+			problems = append(problems, "start_before debe tener formato HH:MM")
+		} else {
+			startBefore = parsed
+			filter.StartBefore = parsed.Format("15:04")
+		}
+	}
+	if filter.StartAfter != "" && filter.StartBefore != "" && !startBefore.After(startAfter) {
+		problems = append(problems, "start_before debe ser mayor a start_after")
+	}
+
+	filter.MinDuration = queryMinutes(c, "min_duration", &problems)
+	filter.MaxDuration = queryMinutes(c, "max_duration", &problems)
+	if filter.MinDuration != nil && filter.MaxDuration != nil && *filter.MinDuration > *filter.MaxDuration {
+		problems = append(problems, "min_duration no puede ser mayor a max_duration")
+	}
+
+	if value := c.Query("available"); value != "" {
+		available, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, "available debe ser booleano")
+		}
+		filter.OnlyAvailable = available
+	}
+
+	if value := c.Query("season"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			problems = append(problems, "season debe ser un ID de temporada")
+		} else {
+			seasonID := uint(id)
+			filter.SeasonID = &seasonID
+		}
+	}
+
+	if len(problems) > 0 {
+		respondError(c, http.StatusBadRequest, "Filtros inválidos", "VALIDATION_ERROR", strings.Join(problems, "; "))
+		return filter, false
+	}
+	return filter, true
+}
+
+// queryList collects a repeated and/or comma separated query parameter, skipping empty values.
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(key) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// queryMinutes reads a positive number of minutes, recording a problem when it is invalid.
+func queryMinutes(c *gin.Context, key string, problems *[]string) *int {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes <= 0 {
+		*problems = append(*problems, key+" debe ser un entero positivo de minutos")
+		return nil
+	}
+	return &minutes
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// cloneRequest overrides fields of a copy; absent fields keep the value of the source.
+type cloneRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	CategoryID  *uint   `json:"category_id"`
+	DayOfWeek   *int    `json:"day_of_week"`
+	StartTime   *string `json:"start_time"`
+	EndTime     *string `json:"end_time"`
+	Capacity    *int    `json:"capacity"`
+	Instructor  *string `json:"instructor"`
+	Location    *string `json:"location"`
+	PriceCents  *int    `json:"price_cents"`
+	// SeasonID moves the copy to another season, typically the next one: its members then have
+	// priority to enroll in the copy.
+	SeasonID *uint `json:"season_id"`
+	// Status is borrador (the default), so the copy can be reviewed before publishing it, or
+	// publicada.
+	Status string `json:"status"`
+}
+
+// CloneActivity creates a new activity from an existing one, with the overrides of the body
+// (which may be empty). The copy has no enrollments, the same eligibility rules and its own copy
+// of an uploaded image.
+func (h *AdminActivitiesHandler) CloneActivity(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req cloneRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	source, err := h.activityService.GetActivityByID(uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrActivityNotFound) {
+			respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener la actividad", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	merged := activityRequest{
+		Title:       source.Title,
+		Description: source.Description,
+		CategoryID:  source.CategoryID,
+		Category:    source.Category,
+		DayOfWeek:   source.DayOfWeek,
+		StartTime:   source.StartTime,
+		EndTime:     source.EndTime,
+		Capacity:    source.Capacity,
+		Instructor:  source.Instructor,
+		Location:    source.Location,
+		PriceCents:  source.PriceCents,
+		Status:      models.ActivityDraft,
+		SeasonID:    source.SeasonID,
+	}
+	if req.Title != nil {
+		merged.Title = *req.Title
+	}
+	if req.Description != nil {
+		merged.Description = *req.Description
+	}
+	if req.CategoryID != nil {
+		merged.CategoryID = req.CategoryID
+	}
+	if req.DayOfWeek != nil {
+		merged.DayOfWeek = *req.DayOfWeek
+	}
+	if req.StartTime != nil {
+		merged.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		merged.EndTime = *req.EndTime
+	}
+	if req.Capacity != nil {
+		merged.Capacity = *req.Capacity
+	}
+	if req.Instructor != nil {
+		merged.Instructor = *req.Instructor
+	}
+	if req.Location != nil {
+		merged.Location = *req.Location
+	}
+	if req.PriceCents != nil {
+		merged.PriceCents = *req.PriceCents
+	}
+	if req.SeasonID != nil {
+		merged.SeasonID = req.SeasonID
+	}
+	if req.Status != "" {
+		merged.Status = req.Status
+	}
+
+	if strings.TrimSpace(merged.Title) == "" || strings.TrimSpace(merged.Instructor) == "" {
+		respondError(c, http.StatusBadRequest, "title e instructor no pueden quedar vacíos", "VALIDATION_ERROR", "")
+		return
+	}
+	if err := validateActivityRequest(merged); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error(), "VALIDATION_ERROR", "")
+		return
+	}
+
+	activity := models.Activity{
+		Title:            merged.Title,
+		Description:      merged.Description,
+		Category:         merged.Category,
+		CategoryID:       merged.CategoryID,
+		DayOfWeek:        merged.DayOfWeek,
+		StartTime:        merged.StartTime,
+		EndTime:          merged.EndTime,
+		Capacity:         merged.Capacity,
+		Instructor:       merged.Instructor,
+		Location:         merged.Location,
+		Status:           merged.Status,
+		PriceCents:       merged.PriceCents,
+		SourceActivityID: &source.ID,
+		SeasonID:         merged.SeasonID,
+		EligibilityRules: source.EligibilityRules,
+	}
+	// An image URL set by hand is shared; an uploaded image is copied once the activity exists.
+	if source.ImageKey == "" {
+		activity.ImageURL = source.ImageURL
+	}
+
+	actor := actorFromContext(c)
+	if err := h.activityService.CreateActivity(&activity, actor); err != nil {
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			respondError(c, http.StatusBadRequest, "La categoría no existe en el catálogo", "VALIDATION_ERROR", "")
+			return
+		}
+		if errors.Is(err, services.ErrSeasonNotFound) {
+			respondError(c, http.StatusBadRequest, "La temporada no existe", "VALIDATION_ERROR", "")
+			return
+		}
+		if errors.Is(err, services.ErrSeasonEnded) {
+			respondError(c, http.StatusConflict, "La temporada ya terminó", "SEASON_ENDED", err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStatus) {
+			respondError(c, http.StatusBadRequest, "status debe ser borrador o publicada", "VALIDATION_ERROR", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo duplicar la actividad", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	message := "Actividad duplicada"
+	if source.ImageKey != "" {
+		if err := h.imageService.CopyActivityImage(c.Request.Context(), source.ImageKey, activity.ID, actor); err != nil {
+			message = "Actividad duplicada sin imagen: no se pudo copiar la de la original"
+		} else if copied, err := h.activityService.GetActivityByID(activity.ID); err == nil {
+			activity = *copied
+		}
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    activity,
+	})
+}
+
+// bulkRequest is the change a bulk update makes to each activity; absent fields are left as they
+// are.
+type bulkRequest struct {
+	// ShiftMinutes moves the class later, or earlier when negative, keeping its length.
+	ShiftMinutes *int    `json:"shift_minutes"`
+	DayOfWeek    *int    `json:"day_of_week"`
+	CategoryID   *uint   `json:"category_id"`
+	Instructor   *string `json:"instructor"`
+	Location     *string `json:"location"`
+	Capacity     *int    `json:"capacity"`
+	PriceCents   *int    `json:"price_cents"`
+	SeasonID     *uint   `json:"season_id"`
+}
+
+// BulkUpdateActivities changes fields of every activity matching the filters of the query string.
+func (h *AdminActivitiesHandler) BulkUpdateActivities(c *gin.Context) {
+	var req bulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	var problems []string
+	if req.ShiftMinutes != nil && (*req.ShiftMinutes == 0 || *req.ShiftMinutes <= -24*60 || *req.ShiftMinutes >= 24*60) {
+		problems = append(problems, "shift_minutes debe ser distinto de 0 y menor a un día")
+	}
+	if req.DayOfWeek != nil && (*req.DayOfWeek < 0 || *req.DayOfWeek > 6) {
+		problems = append(problems, "day_of_week debe estar entre 0 y 6")
+	}
+	if req.Instructor != nil {
+		instructor := strings.TrimSpace(*req.Instructor)
+		if instructor == "" {
+			problems = append(problems, "instructor no puede quedar vacío")
+		}
+		req.Instructor = &instructor
+	}
+	if req.Capacity != nil && *req.Capacity <= 0 {
+		problems = append(problems, "capacity debe ser mayor a 0")
+	}
+	if req.PriceCents != nil && *req.PriceCents < 0 {
+		problems = append(problems, "price_cents no puede ser negativo")
+	}
+	if len(problems) > 0 {
+		respondError(c, http.StatusBadRequest, "Cambios inválidos", "VALIDATION_ERROR", strings.Join(problems, "; "))
+		return
+	}
+
+	h.bulkUpdate(c, services.BulkChanges{
+		ShiftMinutes: req.ShiftMinutes,
+		DayOfWeek:    req.DayOfWeek,
+		CategoryID:   req.CategoryID,
+		Instructor:   req.Instructor,
+		Location:     req.Location,
+		Capacity:     req.Capacity,
+		PriceCents:   req.PriceCents,
+		SeasonID:     req.SeasonID,
+	})
+}
+
+func (h *AdminActivitiesHandler) BulkPublishActivities(c *gin.Context) {
+	h.bulkUpdate(c, services.BulkChanges{Status: models.ActivityPublished})
+}
+
+func (h *AdminActivitiesHandler) BulkPauseActivities(c *gin.Context) {
+	h.bulkUpdate(c, services.BulkChanges{Status: models.ActivityPaused})
+}
+
+func (h *AdminActivitiesHandler) BulkArchiveActivities(c *gin.Context) {
+	h.bulkUpdate(c, services.BulkChanges{Status: models.ActivityArchived})
+}
+
+func (h *AdminActivitiesHandler) BulkDraftActivities(c *gin.Context) {
+	h.bulkUpdate(c, services.BulkChanges{Status: models.ActivityDraft})
+}
+
+// bulkUpdate applies changes to the activities matching the filters of the query string, the same
+// as GET /admin/activities, of which at least one is required. Like a single update it takes
+// ?strategy= and ?dry_run=true, and archiving takes ?cancel_enrollments=true. Nothing is applied
+// unless every activity can take the change.
+func (h *AdminActivitiesHandler) bulkUpdate(c *gin.Context, changes services.BulkChanges) {
+	filter, ok := parseAdminActivityFilter(c)
+	if !ok {
+		return
+	}
+	if filter.Empty() {
+		respondError(c, http.StatusBadRequest, "Indicá al menos un filtro: un cambio masivo no se aplica a todas las actividades", "VALIDATION_ERROR", "")
+		return
+	}
+
+	strategy := c.Query("strategy")
+	if !services.ValidImpactStrategy(strategy) {
+		respondError(c, http.StatusBadRequest, "strategy debe ser reject, keep_overbooking o waitlist", "VALIDATION_ERROR", "")
+		return
+	}
+	var err error
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "dry_run debe ser booleano", "VALIDATION_ERROR", "")
+			return
+		}
+	}
+	if value := c.Query("cancel_enrollments"); value != "" {
+		if changes.Status != models.ActivityArchived {
+			respondError(c, http.StatusBadRequest, "cancel_enrollments solo se usa al archivar", "VALIDATION_ERROR", "")
+			return
+		}
+		changes.CancelEnrollments, err = strconv.ParseBool(value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "cancel_enrollments debe ser booleano", "VALIDATION_ERROR", "")
+			return
+		}
+	}
+
+	result, err := h.activityService.BulkUpdateActivities(filter, changes, strategy, dryRun, actorFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBulkRejected):
+			c.JSON(http.StatusConflict, APIError{
+				Success: false,
+				Error:   fmt.Sprintf("%d de %d actividades no admiten el cambio: no se aplicó a ninguna", result.Rejected, result.Matched),
+				Code:    "BULK_REJECTED",
+				Data:    result,
+			})
+		case errors.Is(err, services.ErrBulkTooLarge):
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("El filtro abarca más de %d actividades: acotalo", services.MaxBulkActivities), "BULK_TOO_LARGE", "")
+		case errors.Is(err, services.ErrNoBulkChanges):
+			respondError(c, http.StatusBadRequest, "Indicá al menos un cambio", "VALIDATION_ERROR", "")
+		case errors.Is(err, services.ErrCategoryNotFound):
+			respondError(c, http.StatusBadRequest, "La categoría no existe en el catálogo", "VALIDATION_ERROR", "")
+		case errors.Is(err, services.ErrSeasonNotFound):
+			respondError(c, http.StatusBadRequest, "La temporada no existe", "VALIDATION_ERROR", "")
+		case errors.Is(err, services.ErrSeasonEnded):
+			respondError(c, http.StatusConflict, "La temporada ya terminó", "SEASON_ENDED", err.Error())
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudo aplicar el cambio masivo", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	message := fmt.Sprintf("Cambio aplicado a %d actividades", result.Matched)
+	switch {
+	case dryRun && result.Rejected > 0:
+		message = fmt.Sprintf("Vista previa: %d de %d actividades no admiten el cambio", result.Rejected, result.Matched)
+	case dryRun:
+		message = fmt.Sprintf("Vista previa del cambio en %d actividades", result.Matched)
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
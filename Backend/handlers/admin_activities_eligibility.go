@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+type eligibilityRuleRequest struct {
+	Type  string `json:"type" binding:"required"`
+	Value string `json:"value" binding:"required"`
+}
+
+// eligibilityRequest replaces every rule of an activity; an empty list removes them.
+type eligibilityRequest struct {
+	Rules []eligibilityRuleRequest `json:"rules" binding:"dive"`
+}
+
+// SetEligibilityRules replaces the eligibility rules of an activity. Members already holding a
+// seat keep it.
+func (h *AdminActivitiesHandler) SetEligibilityRules(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req eligibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+	rules := make([]models.EligibilityRule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		rules = append(rules, models.EligibilityRule{Type: rule.Type, Value: rule.Value})
+	}
+
+	saved, err := h.activityService.SetEligibilityRules(uint(id), rules, actorFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrActivityNotFound):
+			respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+		case errors.Is(err, services.ErrInvalidEligibilityRule):
+			respondError(c, http.StatusBadRequest, "Requisito inválido", "VALIDATION_ERROR", err.Error())
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudieron guardar los requisitos", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Requisitos actualizados",
+		Data:    saved,
+	})
+}
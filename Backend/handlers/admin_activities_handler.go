@@ -2,11 +2,14 @@ package handlers
 
 import (
     "errors"
+    "fmt"
     "net/http"
     "strconv"
+    "strings"
     "time"
 
     "github.com/alesio/gestion-actividades-deportivas/models"
+    "github.com/alesio/gestion-actividades-deportivas/security"
     "github.com/alesio/gestion-actividades-deportivas/services"
     "github.com/gin-gonic/gin"
 )
@@ -14,73 +17,112 @@ import (
 // AdminActivitiesHandler exposes admin-only endpoints for managing activities.
 type AdminActivitiesHandler struct {
     activityService *services.ActivityService
+    imageService    *services.ImageService
 }
 
-func NewAdminActivitiesHandler(activityService *services.ActivityService) *AdminActivitiesHandler {
-    return &AdminActivitiesHandler{activityService: activityService}
+func NewAdminActivitiesHandler(activityService *services.ActivityService, imageService *services.ImageService) *AdminActivitiesHandler {
+    return &AdminActivitiesHandler{activityService: activityService, imageService: imageService}
 }
 
-func (h *AdminActivitiesHandler) RegisterRoutes(router *gin.RouterGroup) {
-    router.GET("/admin/activities", h.ListActivities)
-    router.POST("/admin/activities", h.CreateActivity)
-    router.PUT("/admin/activities/:id", h.UpdateActivity)
-    router.DELETE("/admin/activities/:id", h.DeleteActivity)
+// RegisterRoutes mounts the admin endpoints; require declares the permission each route needs.
+func (h *AdminActivitiesHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+    router.GET("/admin/activities", require(security.PermActivitiesRead), h.ListActivities)
+    router.POST("/admin/activities", require(security.PermActivitiesWrite), h.CreateActivity)
+    router.PUT("/admin/activities/:id", require(security.PermActivitiesWrite), h.UpdateActivity)
+    router.POST("/admin/activities/:id/clone", require(security.PermActivitiesWrite), h.CloneActivity)
+    router.PUT("/admin/activities/:id/eligibility", require(security.PermActivitiesWrite), h.SetEligibilityRules)
+    router.POST("/admin/activities/bulk", require(security.PermActivitiesWrite), h.BulkUpdateActivities)
+    router.POST("/admin/activities/bulk/publish", require(security.PermActivitiesWrite), h.BulkPublishActivities)
+    router.POST("/admin/activities/bulk/pause", require(security.PermActivitiesWrite), h.BulkPauseActivities)
+    router.POST("/admin/activities/bulk/draft", require(security.PermActivitiesWrite), h.BulkDraftActivities)
+    router.POST("/admin/activities/bulk/archive", require(security.PermActivitiesDelete), h.BulkArchiveActivities)
+    router.DELETE("/admin/activities/:id", require(security.PermActivitiesDelete), h.DeleteActivity)
+    router.POST("/admin/activities/:id/publish", require(security.PermActivitiesWrite), h.PublishActivity)
+    router.POST("/admin/activities/:id/pause", require(security.PermActivitiesWrite), h.PauseActivity)
+    router.POST("/admin/activities/:id/draft", require(security.PermActivitiesWrite), h.DraftActivity)
+    router.POST("/admin/activities/:id/archive", require(security.PermActivitiesDelete), h.ArchiveActivity)
+    router.DELETE("/admin/activities/:id/purge", require(security.PermActivitiesPurge), h.PurgeActivity)
+    router.GET("/admin/activities/:id/cancellations", require(security.PermActivitiesRead), h.ListCancellations)
+    router.POST("/admin/activities/:id/cancellations", require(security.PermActivitiesWrite), h.CancelSession)
+    router.DELETE("/admin/activities/:id/cancellations/:date", require(security.PermActivitiesWrite), h.RestoreSession)
 }
 
 type activityRequest struct {
     Title       string `json:"title" binding:"required"`
     Description string `json:"description"`
-    Category    string `json:"category" binding:"required"`
+    // CategoryID picks the category; Category, its slug, is still accepted when CategoryID is absent.
+    CategoryID  *uint  `json:"category_id"`
+    Category    string `json:"category"`
     DayOfWeek   int    `json:"day_of_week" binding:"required"`
     StartTime   string `json:"start_time" binding:"required"`
     EndTime     string `json:"end_time" binding:"required"`
     Capacity    int    `json:"capacity" binding:"required"`
     Instructor  string `json:"instructor" binding:"required"`
+    Location    string `json:"location"`
     ImageURL    string `json:"image_url"`
-    IsActive    *bool  `json:"is_active"`
+    // Status is borrador or publicada (the default) on creation; afterwards it only changes
+    // through the lifecycle endpoints.
+    Status      string `json:"status"`
+    PriceCents  int    `json:"price_cents"`
+    // SeasonID is the season the activity is held in; without it, it is held all year round.
+    SeasonID    *uint  `json:"season_id"`
 }
 
 func (h *AdminActivitiesHandler) ListActivities(c *gin.Context) {
-    var filter services.ActivityFilter
-    filter.Query = c.Query("q")
-    filter.Category = c.Query("category")
+    filter, ok := parseAdminActivityFilter(c)
+    if !ok {
+        return
+    }
 
-    if dayStr := c.Query("day"); dayStr != "" {
-        day, err := strconv.Atoi(dayStr)
-        if err != nil {
-            respondError(c, http.StatusBadRequest, "day debe ser numerico", "VALIDATION_ERROR", "")
-            return
-        }
-        if day < 0 || day > 6 {
-            respondError(c, http.StatusBadRequest, "day debe estar entre 0 y 6", "VALIDATION_ERROR", "")
-            return
-        }
-        filter.Day = &day
+    page, ok := parsePageRequest(c, services.ActivitySortFields)
+    if !ok {
+        return
     }
 
-    var isActiveFilter *bool
+    activities, info, err := h.activityService.ListActivitiesAdmin(filter, page)
+    if err != nil {
+        respondPageError(c, err, "No se pudieron listar las actividades")
+        return
+    }
+
+    respondPage(c, activities, info)
+}
+
+// parseAdminActivityFilter reads the public filters plus is_active, status and ids, the latter two
+// lists like category.
+func parseAdminActivityFilter(c *gin.Context) (services.AdminActivityFilter, bool) {
+    base, ok := parseActivityFilter(c)
+    if !ok {
+        return services.AdminActivityFilter{}, false
+    }
+    filter := services.AdminActivityFilter{ActivityFilter: base}
+
     if isActiveStr := c.Query("is_active"); isActiveStr != "" {
         value, err := strconv.ParseBool(isActiveStr)
         if err != nil {
             respondError(c, http.StatusBadRequest, "is_active debe ser booleano", "VALIDATION_ERROR", "")
-            return
+            return filter, false
         }
-        isActiveFilter = &value
+        filter.IsActive = &value
     }
 
-    activities, err := h.activityService.ListActivitiesAdmin(services.AdminActivityFilter{
-        ActivityFilter: filter,
-        IsActive:       isActiveFilter,
-    })
-    if err != nil {
-        respondError(c, http.StatusInternalServerError, "No se pudieron listar las actividades", "INTERNAL_ERROR", err.Error())
-        return
+    filter.Statuses = queryList(c, "status")
+    for _, status := range filter.Statuses {
+        if !services.ValidActivityStatus(status) {
+            respondError(c, http.StatusBadRequest, "status debe ser borrador, publicada, pausada o archivada", "VALIDATION_ERROR", strconv.Quote(status))
+            return filter, false
+        }
     }
 
-    c.JSON(http.StatusOK, APIResponse{
-        Success: true,
-        Data:    activities,
-    })
+    for _, value := range queryList(c, "ids") {
+        id, err := strconv.ParseUint(value, 10, 64)
+        if err != nil || id == 0 {
+            respondError(c, http.StatusBadRequest, "ids debe ser una lista de enteros positivos", "VALIDATION_ERROR", strconv.Quote(value))
+            return filter, false
+        }
+        filter.IDs = append(filter.IDs, uint(id))
+    }
+    return filter, true
 }
 
 func (h *AdminActivitiesHandler) CreateActivity(c *gin.Context) {
@@ -99,19 +141,36 @@ func (h *AdminActivitiesHandler) CreateActivity(c *gin.Context) {
         Title:       req.Title,
         Description: req.Description,
         Category:    req.Category,
+        CategoryID:  req.CategoryID,
         DayOfWeek:   req.DayOfWeek,
         StartTime:   req.StartTime,
         EndTime:     req.EndTime,
         Capacity:    req.Capacity,
         Instructor:  req.Instructor,
+        Location:    req.Location,
         ImageURL:    req.ImageURL,
-        IsActive:    true,
-    }
-    if req.IsActive != nil {
-        activity.IsActive = *req.IsActive
+        Status:      req.Status,
+        PriceCents:  req.PriceCents,
+        SeasonID:    req.SeasonID,
     }
 
-    if err := h.activityService.CreateActivity(&activity); err != nil {
+    if err := h.activityService.CreateActivity(&activity, actorFromContext(c)); err != nil {
+        if errors.Is(err, services.ErrCategoryNotFound) {
+            respondError(c, http.StatusBadRequest, "La categoría no existe en el catálogo", "VALIDATION_ERROR", "")
+            return
+        }
+        if errors.Is(err, services.ErrSeasonNotFound) {
+            respondError(c, http.StatusBadRequest, "La temporada no existe", "VALIDATION_ERROR", "")
+            return
+        }
+        if errors.Is(err, services.ErrSeasonEnded) {
+            respondError(c, http.StatusConflict, "La temporada ya terminó", "SEASON_ENDED", err.Error())
+            return
+        }
+        if errors.Is(err, services.ErrInvalidStatus) {
+            respondError(c, http.StatusBadRequest, "status debe ser borrador o publicada", "VALIDATION_ERROR", "")
+            return
+        }
         respondError(c, http.StatusInternalServerError, "No se pudo crear la actividad", "INTERNAL_ERROR", err.Error())
         return
     }
@@ -131,6 +190,20 @@ func (h *AdminActivitiesHandler) UpdateActivity(c *gin.Context) {
         return
     }
 
+    strategy := c.Query("strategy")
+    if !services.ValidImpactStrategy(strategy) {
+        respondError(c, http.StatusBadRequest, "strategy debe ser reject, keep_overbooking o waitlist", "VALIDATION_ERROR", "")
+        return
+    }
+    dryRun := false
+    if value := c.Query("dry_run"); value != "" {
+        dryRun, err = strconv.ParseBool(value)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, "dry_run debe ser booleano", "VALIDATION_ERROR", "")
+            return
+        }
+    }
+
     var req activityRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
@@ -152,55 +225,299 @@ func (h *AdminActivitiesHandler) UpdateActivity(c *gin.Context) {
         return
     }
 
+    previousImageKey := activity.ImageKey
     activity.Title = req.Title
     activity.Description = req.Description
     activity.Category = req.Category
+    activity.CategoryID = req.CategoryID
     activity.DayOfWeek = req.DayOfWeek
     activity.StartTime = req.StartTime
     activity.EndTime = req.EndTime
     activity.Capacity = req.Capacity
     activity.Instructor = req.Instructor
+    activity.Location = req.Location
     activity.ImageURL = req.ImageURL
-    if req.IsActive != nil {
-        activity.IsActive = *req.IsActive
+    activity.PriceCents = req.PriceCents
+    activity.SeasonID = req.SeasonID
+    if req.Status != "" && req.Status != activity.Status {
+        respondError(c, http.StatusBadRequest, "El estado se cambia con publish, pause, archive o draft", "VALIDATION_ERROR", "")
+        return
     }
 
-    if err := h.activityService.UpdateActivity(activity); err != nil {
+    if dryRun {
+        impact, err := h.activityService.PreviewUpdate(activity)
+        if err != nil {
+            respondError(c, http.StatusInternalServerError, "No se pudo calcular el impacto del cambio", "INTERNAL_ERROR", err.Error())
+            return
+        }
+        c.JSON(http.StatusOK, APIResponse{
+            Success: true,
+            Message: "Vista previa del cambio",
+            Data:    gin.H{"impact": impact},
+        })
+        return
+    }
+
+    impact, err := h.activityService.UpdateActivity(activity, strategy, actorFromContext(c))
+    if err != nil {
+        if errors.Is(err, services.ErrUpdateImpact) {
+            c.JSON(http.StatusConflict, APIError{
+                Success: false,
+                Error:   "El cambio afecta a inscriptos: confirmalo con una estrategia (reject, keep_overbooking o waitlist)",
+                Code:    "UPDATE_IMPACT",
+                Data:    gin.H{"impact": impact},
+            })
+            return
+        }
+        if errors.Is(err, services.ErrActivityNotFound) {
+            respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+            return
+        }
+        if errors.Is(err, services.ErrCategoryNotFound) {
+            respondError(c, http.StatusBadRequest, "La categoría no existe en el catálogo", "VALIDATION_ERROR", "")
+            return
+        }
+        if errors.Is(err, services.ErrSeasonNotFound) {
+            respondError(c, http.StatusBadRequest, "La temporada no existe", "VALIDATION_ERROR", "")
+            return
+        }
+        if errors.Is(err, services.ErrSeasonEnded) {
+            respondError(c, http.StatusConflict, "La temporada ya terminó", "SEASON_ENDED", err.Error())
+            return
+        }
+        if errors.Is(err, services.ErrInvalidEligibilityRule) {
+            respondError(c, http.StatusConflict, "La actividad es requisito de otra y debe seguir en una temporada", "PREREQUISITE_IN_USE", err.Error())
+            return
+        }
         respondError(c, http.StatusInternalServerError, "No se pudo actualizar la actividad", "INTERNAL_ERROR", err.Error())
         return
     }
 
+    // Setting image_url by hand drops the uploaded image.
+    if previousImageKey != activity.ImageKey {
+        h.imageService.DiscardImage(c.Request.Context(), previousImageKey)
+    }
+
+    message := "Actividad actualizada"
+    switch impact.Strategy {
+    case services.ImpactKeepOverbooking:
+        message = "Actividad actualizada: la clase queda con sobrecupo"
+    case services.ImpactWaitlist:
+        message = fmt.Sprintf("Actividad actualizada: %d inscripciones pasaron a lista de espera (%d ya pagas conservan el pago) y %d reservas pendientes de pago se liberaron", impact.Waitlisted, impact.PaidExcess, impact.ReleasedHolds)
+    }
+
     c.JSON(http.StatusOK, APIResponse{
         Success: true,
-        Message: "Actividad actualizada",
+        Message: message,
         Data:    activity,
     })
 }
 
+// DeleteActivity archives the activity, as POST /archive does; kept for existing clients.
 func (h *AdminActivitiesHandler) DeleteActivity(c *gin.Context) {
-    idParam := c.Param("id")
-    id, err := strconv.ParseUint(idParam, 10, 64)
+    h.changeStatus(c, models.ActivityArchived)
+}
+
+func (h *AdminActivitiesHandler) PublishActivity(c *gin.Context) {
+    h.changeStatus(c, models.ActivityPublished)
+}
+
+func (h *AdminActivitiesHandler) PauseActivity(c *gin.Context) {
+    h.changeStatus(c, models.ActivityPaused)
+}
+
+func (h *AdminActivitiesHandler) ArchiveActivity(c *gin.Context) {
+    h.changeStatus(c, models.ActivityArchived)
+}
+
+func (h *AdminActivitiesHandler) DraftActivity(c *gin.Context) {
+    h.changeStatus(c, models.ActivityDraft)
+}
+
+// changeStatus moves the activity to status. Archiving takes ?cancel_enrollments=true to let go
+// of the members who hold or wait for a seat.
+func (h *AdminActivitiesHandler) changeStatus(c *gin.Context, status string) {
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
     if err != nil {
         respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
         return
     }
 
-    if err := h.activityService.DeleteActivity(uint(id)); err != nil {
-        if errors.Is(err, services.ErrActivityNotFound) {
-            respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+    cancelEnrollments := false
+    if value := c.Query("cancel_enrollments"); value != "" {
+        if status != models.ActivityArchived {
+            respondError(c, http.StatusBadRequest, "cancel_enrollments solo se usa al archivar", "VALIDATION_ERROR", "")
             return
         }
-        respondError(c, http.StatusInternalServerError, "No se pudo desactivar la actividad", "INTERNAL_ERROR", err.Error())
+        cancelEnrollments, err = strconv.ParseBool(value)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, "cancel_enrollments debe ser booleano", "VALIDATION_ERROR", "")
+            return
+        }
+    }
+
+    activity, cancelled, err := h.activityService.ChangeActivityStatus(uint(id), status, cancelEnrollments, actorFromContext(c))
+    if err != nil {
+        respondLifecycleError(c, err, "No se pudo cambiar el estado de la actividad")
+        return
+    }
+
+    message := map[string]string{
+        models.ActivityPublished: "Actividad publicada",
+        models.ActivityPaused:    "Actividad en pausa",
+        models.ActivityArchived:  "Actividad archivada",
+        models.ActivityDraft:     "Actividad pasada a borrador",
+    }[status]
+    c.JSON(http.StatusOK, APIResponse{
+        Success: true,
+        Message: message,
+        Data:    gin.H{"activity": activity, "cancelled_enrollments": cancelled},
+    })
+}
+
+// PurgeActivity deletes a draft or archived activity for good. Its enrollments, if any, are only
+// deleted with ?enrollments=delete; the default, reject, refuses to purge them.
+func (h *AdminActivitiesHandler) PurgeActivity(c *gin.Context) {
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+        return
+    }
+
+    deleteEnrollments := false
+    switch c.DefaultQuery("enrollments", "reject") {
+    case "reject":
+    case "delete":
+        deleteEnrollments = true
+    default:
+        respondError(c, http.StatusBadRequest, "enrollments debe ser reject o delete", "VALIDATION_ERROR", "")
+        return
+    }
+
+    activity, deleted, err := h.activityService.PurgeActivity(uint(id), deleteEnrollments, actorFromContext(c))
+    if err != nil {
+        respondLifecycleError(c, err, "No se pudo eliminar la actividad")
+        return
+    }
+    h.imageService.DiscardImage(c.Request.Context(), activity.ImageKey)
+
+    c.JSON(http.StatusOK, APIResponse{
+        Success: true,
+        Message: "Actividad eliminada definitivamente",
+        Data:    gin.H{"deleted_enrollments": deleted},
+    })
+}
+
+// respondLifecycleError maps the lifecycle rule violations to their API error codes.
+func respondLifecycleError(c *gin.Context, err error, message string) {
+    switch {
+    case errors.Is(err, services.ErrActivityNotFound):
+        respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+    case errors.Is(err, services.ErrInvalidTransition):
+        respondError(c, http.StatusConflict, "La actividad no puede pasar a ese estado desde el actual", "INVALID_TRANSITION", err.Error())
+    case errors.Is(err, services.ErrActivityHasEnrollments):
+        respondError(c, http.StatusConflict, "La actividad tiene inscripciones: cancelalas al archivar (cancel_enrollments=true) o eliminalas al purgar (enrollments=delete)", "ACTIVITY_HAS_ENROLLMENTS", err.Error())
+    case errors.Is(err, services.ErrActivityHasPayments):
+        respondError(c, http.StatusConflict, "La actividad tiene inscripciones con pagos y no se puede eliminar: dejala archivada", "ACTIVITY_HAS_PAYMENTS", err.Error())
+    default:
+        respondError(c, http.StatusInternalServerError, message, "INTERNAL_ERROR", err.Error())
+    }
+}
+
+type cancellationRequest struct {
+    Date   string `json:"date" binding:"required"`
+    Reason string `json:"reason"`
+}
+
+func (h *AdminActivitiesHandler) ListCancellations(c *gin.Context) {
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+        return
+    }
+
+    cancellations, err := h.activityService.ListCancellations(uint(id))
+    if err != nil {
+        respondCancellationError(c, err)
         return
     }
 
     c.JSON(http.StatusOK, APIResponse{
         Success: true,
-        Message: "Actividad desactivada",
+        Data:    cancellations,
     })
 }
 
+// CancelSession marks one date of a weekly activity as cancelled.
+func (h *AdminActivitiesHandler) CancelSession(c *gin.Context) {
+    userID, ok := getUserIDFromContext(c)
+    if !ok {
+        return
+    }
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+        return
+    }
+
+    var req cancellationRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+        return
+    }
+
+    cancellation, err := h.activityService.CancelSession(uint(id), req.Date, req.Reason, userID)
+    if err != nil {
+        respondCancellationError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusCreated, APIResponse{
+        Success: true,
+        Message: "Clase cancelada",
+        Data:    cancellation,
+    })
+}
+
+func (h *AdminActivitiesHandler) RestoreSession(c *gin.Context) {
+    id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+    if err != nil {
+        respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+        return
+    }
+
+    if err := h.activityService.RestoreSession(uint(id), c.Param("date"), actorFromContext(c)); err != nil {
+        respondCancellationError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, APIResponse{
+        Success: true,
+        Message: "Cancelación eliminada",
+    })
+}
+
+// respondCancellationError maps session cancellation errors to their API error codes.
+func respondCancellationError(c *gin.Context, err error) {
+    switch {
+    case errors.Is(err, services.ErrActivityNotFound):
+        respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+    case errors.Is(err, services.ErrCancellationNotFound):
+        respondError(c, http.StatusNotFound, "La clase no estaba cancelada", "NOT_FOUND", "")
+    case errors.Is(err, services.ErrInvalidSessionDate):
+        respondError(c, http.StatusBadRequest, "date debe tener formato YYYY-MM-DD y caer el día de la actividad", "VALIDATION_ERROR", "")
+    case errors.Is(err, services.ErrSessionAlreadyCancelled):
+        respondError(c, http.StatusConflict, "La clase ya estaba cancelada", "ALREADY_CANCELLED", "")
+    default:
+        respondError(c, http.StatusInternalServerError, "No se pudo procesar la cancelación", "INTERNAL_ERROR", err.Error())
+    }
+}
+
 func validateActivityRequest(req activityRequest) error {
+    if req.CategoryID == nil && strings.TrimSpace(req.Category) == "" {
+        return errors.New("category_id es obligatorio")
+    }
+
     if req.DayOfWeek < 0 || req.DayOfWeek > 6 {
         return errors.New("day_of_week debe estar entre 0 y 6")
     }
@@ -209,6 +526,10 @@ func validateActivityRequest(req activityRequest) error {
         return errors.New("capacity debe ser mayor a 0")
     }
 
+    if req.PriceCents < 0 {
+        return errors.New("price_cents no puede ser negativo")
+    }
+
     start, err := time.Parse("15:04", req.StartTime)
     if err != nil {
         return errors.New("start_time debe tener formato HH:MM")
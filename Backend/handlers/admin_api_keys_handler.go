@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAPIKeysHandler lets admins issue and revoke keys for integrations.
+type AdminAPIKeysHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+type apiKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func NewAdminAPIKeysHandler(apiKeyService *services.APIKeyService) *AdminAPIKeysHandler {
+	return &AdminAPIKeysHandler{apiKeyService: apiKeyService}
+}
+
+func (h *AdminAPIKeysHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.GET("/admin/api-keys", require(security.PermAPIKeysManage), h.ListKeys)
+	router.POST("/admin/api-keys", require(security.PermAPIKeysManage), h.CreateKey)
+	router.DELETE("/admin/api-keys/:id", require(security.PermAPIKeysManage), h.RevokeKey)
+}
+
+func (h *AdminAPIKeysHandler) ListKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListKeys()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar las API keys", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data: gin.H{
+			"keys":   keys,
+			"scopes": services.ValidScopes(),
+		},
+	})
+}
+
+func (h *AdminAPIKeysHandler) CreateKey(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		respondError(c, http.StatusBadRequest, "expires_at debe ser una fecha futura", "VALIDATION_ERROR", "")
+		return
+	}
+
+	key, plain, err := h.apiKeyService.CreateKey(req.Name, req.Scopes, req.ExpiresAt, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			respondError(c, http.StatusBadRequest, "Scope inválido", "VALIDATION_ERROR", err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo crear la API key", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "API key creada. Guardala ahora: no se vuelve a mostrar",
+		Data: gin.H{
+			"key":     plain,
+			"api_key": key,
+		},
+	})
+}
+
+func (h *AdminAPIKeysHandler) RevokeKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de API key invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(uint(id)); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			respondError(c, http.StatusNotFound, "API key no encontrada", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo revocar la API key", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "API key revocada",
+	})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminEnrollmentsHandler lets staff and integrations read and manage activity rosters.
+type AdminEnrollmentsHandler struct {
+	enrollmentService services.EnrollmentService
+}
+
+type rosterEntryDTO struct {
+	EnrollmentID     uint      `json:"enrollment_id"`
+	UserID           uint      `json:"user_id"`
+	UserName         string    `json:"user_name"`
+	UserEmail        string    `json:"user_email"`
+	Status           string    `json:"status"`
+	ScheduleConflict bool      `json:"schedule_conflict"`
+	EnrolledAt       time.Time `json:"enrolled_at"`
+}
+
+type rosterRequest struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+func NewAdminEnrollmentsHandler(enrollmentService services.EnrollmentService) *AdminEnrollmentsHandler {
+	return &AdminEnrollmentsHandler{enrollmentService: enrollmentService}
+}
+
+func (h *AdminEnrollmentsHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.GET("/admin/activities/:id/enrollments", require(security.PermEnrollmentsRead), h.ListRoster)
+	router.POST("/admin/activities/:id/enrollments", require(security.PermRostersManage), h.AddToRoster)
+	router.DELETE("/admin/activities/:id/enrollments/:userId", require(security.PermRostersManage), h.RemoveFromRoster)
+}
+
+func (h *AdminEnrollmentsHandler) ListRoster(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	enrollments, err := h.enrollmentService.ListActivityEnrollments(uint(activityID))
+	if err != nil {
+		if errors.Is(err, services.ErrActivityNotFound) {
+			respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener la lista de inscriptos", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	roster := make([]rosterEntryDTO, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		roster = append(roster, rosterEntryDTO{
+			EnrollmentID:     enrollment.ID,
+			UserID:           enrollment.UserID,
+			UserName:         enrollment.User.Name,
+			UserEmail:        enrollment.User.Email,
+			Status:           enrollment.Status,
+			ScheduleConflict: enrollment.ScheduleConflict,
+			EnrolledAt:       enrollment.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    roster,
+	})
+}
+
+// AddToRoster enrolls a member on their behalf, applying the same rules as self-enrollment.
+func (h *AdminEnrollmentsHandler) AddToRoster(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req rosterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	enrollment, err := h.enrollmentService.EnrollUserInActivity(req.UserID, uint(activityID), actorFromContext(c))
+	if err != nil {
+		respondEnrollmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Socio inscripto",
+		Data:    enrollment,
+	})
+}
+
+func (h *AdminEnrollmentsHandler) RemoveFromRoster(c *gin.Context) {
+	activityID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de usuario invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	if err := h.enrollmentService.UnenrollUserFromActivity(uint(userID), uint(activityID), actorFromContext(c)); err != nil {
+		if errors.Is(err, services.ErrEnrollmentNotFound) {
+			respondError(c, http.StatusNotFound, "El socio no está inscripto en esta actividad", "ENROLLMENT_NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo dar de baja al socio", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Socio dado de baja de la actividad",
+	})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRolesHandler manages roles, their permissions and user role assignments.
+type AdminRolesHandler struct {
+	rbacService *services.RBACService
+}
+
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type assignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func NewAdminRolesHandler(rbacService *services.RBACService) *AdminRolesHandler {
+	return &AdminRolesHandler{rbacService: rbacService}
+}
+
+func (h *AdminRolesHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.GET("/admin/permissions", require(security.PermRolesManage), h.ListPermissions)
+	router.GET("/admin/roles", require(security.PermRolesManage), h.ListRoles)
+	router.POST("/admin/roles", require(security.PermRolesManage), h.CreateRole)
+	router.PUT("/admin/roles/:name", require(security.PermRolesManage), h.UpdateRole)
+	router.DELETE("/admin/roles/:name", require(security.PermRolesManage), h.DeleteRole)
+	router.PUT("/admin/users/:id/role", require(security.PermUsersManage), h.AssignUserRole)
+}
+
+func (h *AdminRolesHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    security.AllPermissions(),
+	})
+}
+
+func (h *AdminRolesHandler) ListRoles(c *gin.Context) {
+	roles, err := h.rbacService.ListRoles()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar los roles", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    roles,
+	})
+}
+
+func (h *AdminRolesHandler) CreateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	role, err := h.rbacService.CreateRole(req.Name, req.Description, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Rol creado",
+		Data:    role,
+	})
+}
+
+func (h *AdminRolesHandler) UpdateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	role, err := h.rbacService.UpdateRole(c.Param("name"), req.Description, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Rol actualizado",
+		Data:    role,
+	})
+}
+
+func (h *AdminRolesHandler) DeleteRole(c *gin.Context) {
+	if err := h.rbacService.DeleteRole(c.Param("name")); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Rol eliminado",
+	})
+}
+
+func (h *AdminRolesHandler) AssignUserRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de usuario invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req assignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	user, err := h.rbacService.AssignUserRole(uint(userID), req.Role, actorFromContext(c))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Rol asignado",
+		Data:    toUserResponse(user),
+	})
+}
+
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		respondError(c, http.StatusNotFound, "Rol no encontrado", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrUserNotFound):
+		respondError(c, http.StatusNotFound, "Usuario no encontrado", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrRoleExists):
+		respondError(c, http.StatusConflict, "El rol ya existe", "ROLE_EXISTS", "")
+	case errors.Is(err, services.ErrRoleInUse):
+		respondError(c, http.StatusConflict, "El rol está asignado a usuarios", "ROLE_IN_USE", "")
+	case errors.Is(err, services.ErrSystemRoleLocked):
+		respondError(c, http.StatusConflict, "El rol es del sistema y no puede modificarse", "SYSTEM_ROLE", "")
+	case errors.Is(err, services.ErrLastAdmin):
+		respondError(c, http.StatusConflict, "Debe quedar al menos un administrador", "LAST_ADMIN", "")
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidPerm):
+		respondError(c, http.StatusBadRequest, "Rol o permiso inválido", "VALIDATION_ERROR", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "No se pudo procesar el rol", "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUsersHandler lets the staff keep the member data eligibility rules check.
+type AdminUsersHandler struct {
+	userService *services.UserService
+}
+
+// profileRequest replaces the birth date and level of a member; empty values clear them.
+type profileRequest struct {
+	BirthDate string `json:"birth_date"`
+	Level     string `json:"level"`
+}
+
+func NewAdminUsersHandler(userService *services.UserService) *AdminUsersHandler {
+	return &AdminUsersHandler{userService: userService}
+}
+
+func (h *AdminUsersHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.PUT("/admin/users/:id/profile", require(security.PermUsersManage), h.UpdateProfile)
+}
+
+func (h *AdminUsersHandler) UpdateProfile(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de usuario invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req profileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(uint(userID), strings.TrimSpace(req.BirthDate), strings.ToLower(strings.TrimSpace(req.Level)), actorFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			respondError(c, http.StatusNotFound, "Usuario no encontrado", "NOT_FOUND", "")
+		case errors.Is(err, services.ErrInvalidProfile):
+			respondError(c, http.StatusBadRequest, "Datos de perfil inválidos", "VALIDATION_ERROR", err.Error())
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudo actualizar el perfil", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Perfil actualizado",
+		Data:    toUserResponse(user),
+	})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/alesio/gestion-actividades-deportivas/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminWebhooksHandler lets admins manage outgoing webhook subscriptions and their delivery log.
+type AdminWebhooksHandler struct {
+	webhookService *services.WebhookService
+}
+
+type webhookRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types" binding:"required"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+func NewAdminWebhooksHandler(webhookService *services.WebhookService) *AdminWebhooksHandler {
+	return &AdminWebhooksHandler{webhookService: webhookService}
+}
+
+func (h *AdminWebhooksHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.GET("/admin/webhooks", require(security.PermWebhooksManage), h.ListSubscriptions)
+	router.POST("/admin/webhooks", require(security.PermWebhooksManage), h.CreateSubscription)
+	router.PUT("/admin/webhooks/:id", require(security.PermWebhooksManage), h.UpdateSubscription)
+	router.DELETE("/admin/webhooks/:id", require(security.PermWebhooksManage), h.DeleteSubscription)
+	router.POST("/admin/webhooks/:id/rotate-secret", require(security.PermWebhooksManage), h.RotateSecret)
+	router.GET("/admin/webhooks/:id/deliveries", require(security.PermWebhooksManage), h.ListDeliveries)
+	router.POST("/admin/webhooks/:id/deliveries/:deliveryId/replay", require(security.PermWebhooksManage), h.ReplayDelivery)
+}
+
+func (h *AdminWebhooksHandler) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.webhookService.ListSubscriptions()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar los webhooks", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data: gin.H{
+			"webhooks":    subscriptions,
+			"event_types": webhooks.EventTypes(),
+		},
+	})
+}
+
+func (h *AdminWebhooksHandler) CreateSubscription(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	input, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	subscription, secret, err := h.webhookService.CreateSubscription(input, userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Webhook creado. Guardá el secreto ahora: no se vuelve a mostrar",
+		Data: gin.H{
+			"secret":  secret,
+			"webhook": subscription,
+		},
+	})
+}
+
+func (h *AdminWebhooksHandler) UpdateSubscription(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+	input, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := h.webhookService.UpdateSubscription(id, input)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Webhook actualizado",
+		Data:    subscription,
+	})
+}
+
+func (h *AdminWebhooksHandler) DeleteSubscription(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(id); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Webhook eliminado",
+	})
+}
+
+func (h *AdminWebhooksHandler) RotateSecret(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	secret, err := h.webhookService.RotateSecret(id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Secreto renovado. Guardalo ahora: no se vuelve a mostrar",
+		Data:    gin.H{"secret": secret},
+	})
+}
+
+func (h *AdminWebhooksHandler) ListDeliveries(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", "pendiente", "enviado", "fallido":
+	default:
+		respondError(c, http.StatusBadRequest, "status debe ser pendiente, enviado o fallido", "VALIDATION_ERROR", "")
+		return
+	}
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 200 {
+			respondError(c, http.StatusBadRequest, "limit debe estar entre 1 y 200", "VALIDATION_ERROR", "")
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(id, status, limit)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    deliveries,
+	})
+}
+
+func (h *AdminWebhooksHandler) ReplayDelivery(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseUint(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de entrega invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(id, uint(deliveryID))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, APIResponse{
+		Success: true,
+		Message: "Entrega reenviada a la cola",
+		Data:    delivery,
+	})
+}
+
+func bindWebhookRequest(c *gin.Context) (services.WebhookInput, bool) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return services.WebhookInput{}, false
+	}
+	input := services.WebhookInput{
+		Name:       req.Name,
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		IsActive:   true,
+	}
+	if req.IsActive != nil {
+		input.IsActive = *req.IsActive
+	}
+	return input, true
+}
+
+func parseWebhookID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de webhook invalido", "VALIDATION_ERROR", "")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondWebhookError maps webhook service errors to their API error codes.
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		respondError(c, http.StatusNotFound, "Webhook no encontrado", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		respondError(c, http.StatusNotFound, "Entrega no encontrada", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrInvalidWebhookURL):
+		respondError(c, http.StatusBadRequest, "url debe ser una URL http o https", "VALIDATION_ERROR", "")
+	case errors.Is(err, services.ErrInvalidEventType):
+		respondError(c, http.StatusBadRequest, "Tipo de evento inválido", "VALIDATION_ERROR", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "No se pudo procesar el webhook", "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler exposes the audit log and the version history of activities.
+type AuditHandler struct {
+	auditService    *services.AuditService
+	activityService *services.ActivityService
+}
+
+func NewAuditHandler(auditService *services.AuditService, activityService *services.ActivityService) *AuditHandler {
+	return &AuditHandler{auditService: auditService, activityService: activityService}
+}
+
+func (h *AuditHandler) RegisterRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.GET("/admin/audit", require(security.PermAuditRead), h.ListAudit)
+	router.GET("/admin/activities/:id/versions", require(security.PermActivitiesRead), h.ListActivityVersions)
+	router.POST("/admin/activities/:id/versions/:version/restore", require(security.PermActivitiesWrite), h.RestoreActivityVersion)
+}
+
+// ListAudit pages through the audit log, newest first. Filters: entity_type, entity_id, actor_id,
+// action, from and to (RFC 3339 or YYYY-MM-DD; a bare "to" date includes the whole day).
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	var filter services.AuditFilter
+	var problems []string
+
+	if value := c.Query("entity_type"); value != "" {
+		switch value {
+		case services.AuditActivity, services.AuditEnrollment, services.AuditUser, services.AuditSeason:
+			filter.EntityType = value
+		default:
+			problems = append(problems, "entity_type debe ser activity, enrollment, user o season")
+		}
+	}
+	if value := c.Query("entity_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			problems = append(problems, "entity_id debe ser un entero positivo")
+		} else {
+			entityID := uint(id)
+			filter.EntityID = &entityID
+		}
+	}
+	if value := c.Query("actor_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			problems = append(problems, "actor_id debe ser un entero positivo")
+		} else {
+			actorID := uint(id)
+			filter.ActorUserID = &actorID
+		}
+	}
+	filter.Action = strings.TrimSpace(c.Query("action"))
+	if value := c.Query("from"); value != "" {
+		from, _, err := parseAuditTime(value)
+		if err != nil {
+			problems = append(problems, "from debe ser una fecha YYYY-MM-DD o RFC 3339")
+		} else {
+			filter.From = &from
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		to, dateOnly, err := parseAuditTime(value)
+		if err != nil {
+			problems = append(problems, "to debe ser una fecha YYYY-MM-DD o RFC 3339")
+		} else {
+			if dateOnly {
+				to = to.AddDate(0, 0, 1)
+			}
+			filter.To = &to
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		problems = append(problems, "from debe ser anterior a to")
+	}
+	if len(problems) > 0 {
+		respondError(c, http.StatusBadRequest, "Filtros inválidos", "VALIDATION_ERROR", strings.Join(problems, "; "))
+		return
+	}
+
+	page, ok := parsePageRequest(c, nil)
+	if !ok {
+		return
+	}
+	entries, info, err := h.auditService.ListAudit(filter, page)
+	if err != nil {
+		respondPageError(c, err, "No se pudo obtener la auditoría")
+		return
+	}
+	respondPage(c, entries, info)
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date in local time, reporting
+// which one it got.
+func parseAuditTime(value string) (time.Time, bool, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, true, nil
+	}
+	moment, err := time.Parse(time.RFC3339, value)
+	return moment, false, err
+}
+
+func (h *AuditHandler) ListActivityVersions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	versions, err := h.auditService.ListActivityVersions(uint(id))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron obtener las versiones", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	if len(versions) == 0 {
+		respondError(c, http.StatusNotFound, "La actividad no tiene versiones registradas", "NOT_FOUND", "")
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    versions,
+	})
+}
+
+// RestoreActivityVersion puts back a previous version of an activity. Like an update, a restore
+// that affects enrolled members needs ?strategy=.
+func (h *AuditHandler) RestoreActivityVersion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		respondError(c, http.StatusBadRequest, "La versión debe ser un entero positivo", "VALIDATION_ERROR", "")
+		return
+	}
+	strategy := c.Query("strategy")
+	if !services.ValidImpactStrategy(strategy) {
+		respondError(c, http.StatusBadRequest, "strategy debe ser reject, keep_overbooking o waitlist", "VALIDATION_ERROR", "")
+		return
+	}
+
+	activity, impact, err := h.activityService.RestoreActivityVersion(uint(id), version, strategy, actorFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUpdateImpact):
+			c.JSON(http.StatusConflict, APIError{
+				Success: false,
+				Error:   "Restaurar la versión afecta a inscriptos: confirmalo con una estrategia (reject, keep_overbooking o waitlist)",
+				Code:    "UPDATE_IMPACT",
+				Data:    gin.H{"impact": impact},
+			})
+		case errors.Is(err, services.ErrActivityNotFound):
+			respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+		case errors.Is(err, services.ErrVersionNotFound):
+			respondError(c, http.StatusNotFound, "Versión no encontrada", "VERSION_NOT_FOUND", "")
+		case errors.Is(err, services.ErrCategoryNotFound):
+			respondError(c, http.StatusBadRequest, "La categoría de esa versión ya no existe en el catálogo", "VALIDATION_ERROR", "")
+		case errors.Is(err, services.ErrSeasonNotFound):
+			respondError(c, http.StatusBadRequest, "La temporada de esa versión ya no existe", "VALIDATION_ERROR", "")
+		case errors.Is(err, services.ErrSeasonEnded):
+			respondError(c, http.StatusConflict, "La temporada de esa versión ya terminó", "SEASON_ENDED", err.Error())
+		case errors.Is(err, services.ErrInvalidEligibilityRule):
+			respondError(c, http.StatusConflict, "La actividad es requisito de otra y debe seguir en una temporada", "PREREQUISITE_IN_USE", err.Error())
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudo restaurar la versión", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Versión %d restaurada", version),
+		Data:    activity,
+	})
+}
@@ -36,10 +36,12 @@ type registerRequest struct {
 }
 
 type userResponse struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	BirthDate string `json:"birth_date,omitempty"`
+	Level     string `json:"level,omitempty"`
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -104,10 +106,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 func toUserResponse(user *models.User) userResponse {
 	return userResponse{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
-		Role:  user.Role,
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      user.Role,
+		BirthDate: user.BirthDate,
+		Level:     user.Level,
 	}
 }
 
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+// CalendarHandler serves the iCalendar feeds and lets members manage their personal feed URL.
+type CalendarHandler struct {
+	calendarService *services.CalendarService
+}
+
+func NewCalendarHandler(calendarService *services.CalendarService) *CalendarHandler {
+	return &CalendarHandler{calendarService: calendarService}
+}
+
+// RegisterRoutes mounts the member endpoints (requires AuthMiddleware).
+func (h *CalendarHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/me/calendar", h.GetToken)
+	router.POST("/me/calendar/token", h.IssueToken)
+	router.DELETE("/me/calendar/token", h.RevokeToken)
+}
+
+// RegisterFeedRoutes mounts the feeds calendar apps subscribe to. They carry no session: the
+// personal feed is authenticated by the secret token in its URL.
+func (h *CalendarHandler) RegisterFeedRoutes(router *gin.RouterGroup) {
+	router.GET("/calendar/timetable.ics", h.PublicFeed)
+	router.GET("/calendar/:token", h.MemberFeed)
+}
+
+func (h *CalendarHandler) GetToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	info, err := h.calendarService.TokenInfo(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener el calendario", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    info,
+	})
+}
+
+// IssueToken creates a new personal feed URL, invalidating the previous one.
+func (h *CalendarHandler) IssueToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	token, err := h.calendarService.IssueToken(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo generar el enlace del calendario", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	path := "/api/calendar/" + token + ".ics"
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Enlace de calendario generado. El anterior deja de funcionar",
+		Data: gin.H{
+			"url":        requestBaseURL(c) + path,
+			"webcal_url": "webcal://" + c.Request.Host + path,
+		},
+	})
+}
+
+func (h *CalendarHandler) RevokeToken(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	if err := h.calendarService.RevokeToken(userID); err != nil {
+		if errors.Is(err, services.ErrCalendarTokenNotFound) {
+			respondError(c, http.StatusNotFound, "No hay un enlace de calendario activo", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo revocar el enlace del calendario", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Enlace de calendario revocado",
+	})
+}
+
+func (h *CalendarHandler) MemberFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	userID, err := h.calendarService.ResolveToken(token)
+	if err != nil {
+		if errors.Is(err, services.ErrCalendarTokenNotFound) {
+			respondError(c, http.StatusNotFound, "Calendario no encontrado", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo generar el calendario", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	feed, err := h.calendarService.MemberFeed(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo generar el calendario", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	// The URL is a credential: keep the feed out of shared caches.
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, calendarContentType, feed)
+}
+
+func (h *CalendarHandler) PublicFeed(c *gin.Context) {
+	feed, err := h.calendarService.PublicFeed()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo generar el calendario", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Content-Disposition", `inline; filename="timetable.ics"`)
+	c.Data(http.StatusOK, calendarContentType, feed)
+}
+
+// requestBaseURL rebuilds the scheme and host the client used, honouring a TLS-terminating proxy.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+		scheme = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + c.Request.Host
+}
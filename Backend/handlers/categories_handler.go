@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CategoriesHandler exposes the category catalogue publicly and lets admins manage it.
+type CategoriesHandler struct {
+	categoryService *services.CategoryService
+}
+
+type categoryRequest struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+	Icon  string `json:"icon"`
+}
+
+func NewCategoriesHandler(categoryService *services.CategoryService) *CategoriesHandler {
+	return &CategoriesHandler{categoryService: categoryService}
+}
+
+// RegisterRoutes mounts the public catalogue.
+func (h *CategoriesHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/categories", h.ListCategories)
+}
+
+// RegisterAdminRoutes mounts the catalogue management, guarded by the activity permissions.
+func (h *CategoriesHandler) RegisterAdminRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.POST("/admin/categories", require(security.PermActivitiesWrite), h.CreateCategory)
+	router.PUT("/admin/categories/:id", require(security.PermActivitiesWrite), h.UpdateCategory)
+	router.DELETE("/admin/categories/:id", require(security.PermActivitiesDelete), h.DeleteCategory)
+}
+
+func (h *CategoriesHandler) ListCategories(c *gin.Context) {
+	categories, err := h.categoryService.ListCategories()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar las categorías", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    categories,
+	})
+}
+
+func (h *CategoriesHandler) CreateCategory(c *gin.Context) {
+	input, ok := bindCategoryRequest(c)
+	if !ok {
+		return
+	}
+
+	category, err := h.categoryService.CreateCategory(input)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Categoría creada",
+		Data:    category,
+	})
+}
+
+func (h *CategoriesHandler) UpdateCategory(c *gin.Context) {
+	id, ok := parseCategoryID(c)
+	if !ok {
+		return
+	}
+	input, ok := bindCategoryRequest(c)
+	if !ok {
+		return
+	}
+
+	category, err := h.categoryService.UpdateCategory(id, input)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Categoría actualizada",
+		Data:    category,
+	})
+}
+
+func (h *CategoriesHandler) DeleteCategory(c *gin.Context) {
+	id, ok := parseCategoryID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.categoryService.DeleteCategory(id); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Categoría eliminada",
+	})
+}
+
+func bindCategoryRequest(c *gin.Context) (services.CategoryInput, bool) {
+	var req categoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return services.CategoryInput{}, false
+	}
+	return services.CategoryInput{
+		Slug:  req.Slug,
+		Name:  req.Name,
+		Color: req.Color,
+		Icon:  req.Icon,
+	}, true
+}
+
+func parseCategoryID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de categoría invalido", "VALIDATION_ERROR", "")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondCategoryError maps category service errors to their API error codes.
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound):
+		respondError(c, http.StatusNotFound, "Categoría no encontrada", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrCategoryExists):
+		respondError(c, http.StatusConflict, "Ya existe una categoría con ese slug", "CATEGORY_EXISTS", "")
+	case errors.Is(err, services.ErrCategoryInUse):
+		respondError(c, http.StatusConflict, "La categoría tiene actividades o cupos de planes asociados", "CATEGORY_IN_USE", err.Error())
+	case errors.Is(err, services.ErrInvalidCategory):
+		respondError(c, http.StatusBadRequest, "Datos de categoría inválidos", "VALIDATION_ERROR", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "No se pudo procesar la categoría", "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -22,6 +23,10 @@ type myActivityDTO struct {
 	StartTime   string `json:"start_time"`
 	EndTime     string `json:"end_time"`
 	Instructor  string `json:"instructor"`
+	// ScheduleConflict is set when a schedule change made this class overlap another one.
+	ScheduleConflict bool `json:"schedule_conflict"`
+	// EnrollmentStatus is "inscripto" or "lista_espera" when the member is waiting for a seat.
+	EnrollmentStatus string `json:"enrollment_status"`
 }
 
 func NewEnrollmentsHandler(enrollmentService services.EnrollmentService) *EnrollmentsHandler {
@@ -51,53 +56,20 @@ func (h *EnrollmentsHandler) EnrollInActivity(c *gin.Context) {
 		return
 	}
 
-	enrollment, err := h.enrollmentService.EnrollUserInActivity(userID, uint(activityID))
+	enrollment, err := h.enrollmentService.EnrollUserInActivity(userID, uint(activityID), services.UserActor(userID))
 	if err != nil {
-		switch err {
-		case services.ErrActivityNotFound:
-			c.JSON(http.StatusNotFound, APIError{
-				Success: false,
-				Error:   "Actividad no encontrada",
-				Code:    "ACTIVITY_NOT_FOUND",
-			})
-		case services.ErrActivityInactive:
-			c.JSON(http.StatusBadRequest, APIError{
-				Success: false,
-				Error:   "La actividad no esta activa",
-				Code:    "ACTIVITY_INACTIVE",
-			})
-		case services.ErrAlreadyEnrolled:
-			c.JSON(http.StatusConflict, APIError{
-				Success: false,
-				Error:   "Ya estas inscripto en esta actividad",
-				Code:    "ALREADY_ENROLLED",
-			})
-		case services.ErrNoCapacity:
-			c.JSON(http.StatusConflict, APIError{
-				Success: false,
-				Error:   "La actividad no tiene cupos disponibles",
-				Code:    "NO_CAPACITY",
-			})
-		case services.ErrScheduleConflict:
-			c.JSON(http.StatusConflict, APIError{
-				Success: false,
-				Error:   "La actividad se solapa en dia y horario con otra inscripcion activa",
-				Code:    "SCHEDULE_CONFLICT",
-			})
-		default:
-			c.JSON(http.StatusInternalServerError, APIError{
-				Success: false,
-				Error:   "No se pudo completar la inscripcion",
-				Code:    "INTERNAL_ERROR",
-				Details: err.Error(),
-			})
-		}
+		respondEnrollmentError(c, err)
 		return
 	}
 
+	message := "Inscripcion exitosa"
+	if enrollment.Status == "pendiente_pago" {
+		message = "Lugar reservado: completa el pago para confirmar la inscripcion"
+	}
+
 	c.JSON(http.StatusCreated, APIResponse{
 		Success: true,
-		Message: "Inscripcion exitosa",
+		Message: message,
 		Data:    enrollment,
 	})
 }
@@ -131,6 +103,9 @@ func (h *EnrollmentsHandler) ListMyActivities(c *gin.Context) {
 			StartTime:   activity.StartTime,
 			EndTime:     activity.EndTime,
 			Instructor:  activity.Instructor,
+
+			ScheduleConflict: enrollment.ScheduleConflict,
+			EnrollmentStatus: enrollment.Status,
 		})
 	}
 
@@ -157,7 +132,7 @@ func (h *EnrollmentsHandler) UnenrollFromActivity(c *gin.Context) {
 		return
 	}
 
-	if err := h.enrollmentService.UnenrollUserFromActivity(userID, uint(activityID)); err != nil {
+	if err := h.enrollmentService.UnenrollUserFromActivity(userID, uint(activityID), services.UserActor(userID)); err != nil {
 		switch err {
 		case services.ErrEnrollmentNotFound:
 			c.JSON(http.StatusNotFound, APIError{
@@ -182,6 +157,119 @@ func (h *EnrollmentsHandler) UnenrollFromActivity(c *gin.Context) {
 	})
 }
 
+// respondEnrollmentError maps enrollment rule violations to their API error codes.
+func respondEnrollmentError(c *gin.Context, err error) {
+	c.JSON(enrollmentError(err))
+}
+
+// enrollmentError is the status and body of the response to an enrollment error.
+func enrollmentError(err error) (int, APIError) {
+	var notEligible *services.NotEligibleError
+	if errors.As(err, &notEligible) {
+		return http.StatusForbidden, APIError{
+			Success: false,
+			Error:   "No cumplis los requisitos de la actividad",
+			Code:    "NOT_ELIGIBLE",
+			Details: notEligible.Error(),
+			Data:    gin.H{"reasons": notEligible.Reasons},
+		}
+	}
+
+	switch err {
+	case services.ErrActivityNotFound:
+		return http.StatusNotFound, APIError{
+			Success: false,
+			Error:   "Actividad no encontrada",
+			Code:    "ACTIVITY_NOT_FOUND",
+		}
+	case services.ErrUserNotFound:
+		return http.StatusNotFound, APIError{
+			Success: false,
+			Error:   "Usuario no encontrado",
+			Code:    "NOT_FOUND",
+		}
+	case services.ErrActivityInactive:
+		return http.StatusBadRequest, APIError{
+			Success: false,
+			Error:   "La actividad no esta activa",
+			Code:    "ACTIVITY_INACTIVE",
+		}
+	case services.ErrActivityPaused:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "La actividad esta en pausa y no admite inscripciones nuevas",
+			Code:    "ACTIVITY_PAUSED",
+		}
+	case services.ErrAlreadyEnrolled:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "Ya estas inscripto en esta actividad",
+			Code:    "ALREADY_ENROLLED",
+		}
+	case services.ErrWaitlisted:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "Ya estas en la lista de espera de esta actividad",
+			Code:    "WAITLISTED",
+		}
+	case services.ErrNoCapacity:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "La actividad no tiene cupos disponibles",
+			Code:    "NO_CAPACITY",
+		}
+	case services.ErrScheduleConflict:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "La actividad se solapa en dia y horario con otra inscripcion activa",
+			Code:    "SCHEDULE_CONFLICT",
+		}
+	case services.ErrQuotaExceeded:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "Alcanzaste el cupo semanal de tu plan",
+			Code:    "QUOTA_EXCEEDED",
+		}
+	case services.ErrMembershipRequired:
+		return http.StatusForbidden, APIError{
+			Success: false,
+			Error:   "Necesitas una membresia activa para inscribirte",
+			Code:    "MEMBERSHIP_REQUIRED",
+		}
+	case services.ErrPaymentPending:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "Ya tenes un pago pendiente para esta actividad",
+			Code:    "PAYMENT_PENDING",
+		}
+	case services.ErrSeasonEnded:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "La temporada de la actividad ya termino",
+			Code:    "SEASON_ENDED",
+		}
+	case services.ErrEnrollmentNotOpen:
+		return http.StatusConflict, APIError{
+			Success: false,
+			Error:   "La inscripcion a la temporada todavia no esta abierta",
+			Code:    "ENROLLMENT_NOT_OPEN",
+		}
+	case services.ErrPaymentsUnavailable:
+		return http.StatusServiceUnavailable, APIError{
+			Success: false,
+			Error:   "Los pagos no estan disponibles",
+			Code:    "PAYMENTS_UNAVAILABLE",
+		}
+	default:
+		return http.StatusInternalServerError, APIError{
+			Success: false,
+			Error:   "No se pudo completar la inscripcion",
+			Code:    "INTERNAL_ERROR",
+			Details: err.Error(),
+		}
+	}
+}
+
 func getUserIDFromContext(c *gin.Context) (uint, bool) {
 	userIDValue, exists := c.Get("userID")
 	if !exists {
@@ -205,3 +293,30 @@ func getUserIDFromContext(c *gin.Context) (uint, bool) {
 
 	return userID, true
 }
+
+// optionalUserID returns the member authenticated by AuthMiddleware.Optional, or nil for an
+// anonymous request.
+func optionalUserID(c *gin.Context) *uint {
+	if value, exists := c.Get("userID"); exists {
+		if id, ok := value.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// actorFromContext identifies the caller for the audit log: the authenticated user or, for
+// integrations, the API key.
+func actorFromContext(c *gin.Context) services.Actor {
+	if userID, ok := c.Get("userID"); ok {
+		if id, ok := userID.(uint); ok {
+			return services.UserActor(id)
+		}
+	}
+	if keyID, ok := c.Get("apiKeyID"); ok {
+		if id, ok := keyID.(uint); ok {
+			return services.APIKeyActor(id)
+		}
+	}
+	return services.Actor{}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/images"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is allowed on top of the image size for the multipart framing.
+const multipartOverhead = 64 << 10
+
+// ImagesHandler serves the stored activity images and lets admins upload them.
+type ImagesHandler struct {
+	imageService *services.ImageService
+}
+
+func NewImagesHandler(imageService *services.ImageService) *ImagesHandler {
+	return &ImagesHandler{imageService: imageService}
+}
+
+// RegisterRoutes mounts the public image downloads.
+func (h *ImagesHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/images/*key", h.GetImage)
+	router.HEAD("/images/*key", h.GetImage)
+}
+
+// RegisterAdminRoutes mounts the uploads, guarded by the activity permissions.
+func (h *ImagesHandler) RegisterAdminRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.POST("/admin/activities/:id/image", require(security.PermActivitiesWrite), h.UploadActivityImage)
+	router.DELETE("/admin/activities/:id/image", require(security.PermActivitiesWrite), h.DeleteActivityImage)
+}
+
+// GetImage streams a stored variant. Keys change with the content, so responses are cacheable
+// forever.
+func (h *ImagesHandler) GetImage(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	reader, info, err := h.imageService.OpenImage(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, services.ErrImageNotFound) {
+			respondError(c, http.StatusNotFound, "Imagen no encontrada", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener la imagen", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if info.ETag != "" {
+		c.Header("ETag", info.ETag)
+		if etagMatches(c.GetHeader("If-None-Match"), info.ETag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	if !info.ModTime.IsZero() {
+		c.Header("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, reader, nil)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing weakly as RFC 9110
+// asks for that header.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadActivityImage takes the "image" field of a multipart form, resizes it and makes it the
+// image of the activity.
+func (h *ImagesHandler) UploadActivityImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	maxBytes := h.imageService.MaxBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+	header, err := c.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondImageTooLarge(c, maxBytes, "")
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Falta el archivo image en el formulario multipart", "VALIDATION_ERROR", err.Error())
+		return
+	}
+	if header.Size > maxBytes {
+		respondImageTooLarge(c, maxBytes, "")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "No se pudo leer la imagen", "VALIDATION_ERROR", err.Error())
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "No se pudo leer la imagen", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	image, err := h.imageService.SetActivityImage(c.Request.Context(), uint(id), data, actorFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrActivityNotFound):
+			respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+		case errors.Is(err, services.ErrImageTooLarge):
+			respondImageTooLarge(c, maxBytes, err.Error())
+		case errors.Is(err, services.ErrUnsupportedImage):
+			respondError(c, http.StatusUnsupportedMediaType, "La imagen debe ser JPEG, PNG, GIF o WebP", "UNSUPPORTED_MEDIA_TYPE", err.Error())
+		case errors.Is(err, services.ErrInvalidImage):
+			respondError(c, http.StatusBadRequest, "La imagen está dañada o incompleta", "VALIDATION_ERROR", err.Error())
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudo guardar la imagen", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Imagen actualizada",
+		Data:    image,
+	})
+}
+
+func (h *ImagesHandler) DeleteActivityImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de actividad invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	if err := h.imageService.RemoveActivityImage(c.Request.Context(), uint(id), actorFromContext(c)); err != nil {
+		if errors.Is(err, services.ErrActivityNotFound) {
+			respondError(c, http.StatusNotFound, "Actividad no encontrada", "NOT_FOUND", "")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo quitar la imagen", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Imagen eliminada",
+	})
+}
+
+func respondImageTooLarge(c *gin.Context, maxBytes int64, details string) {
+	message := fmt.Sprintf("La imagen es demasiado grande: se aceptan hasta %d KB y %d px por lado", maxBytes>>10, images.MaxSide)
+	respondError(c, http.StatusRequestEntityTooLarge, message, "IMAGE_TOO_LARGE", details)
+}
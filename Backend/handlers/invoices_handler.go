@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// InvoicesHandler lets members list and download their own invoices.
+type InvoicesHandler struct {
+	invoiceService *services.InvoiceService
+}
+
+func NewInvoicesHandler(invoiceService *services.InvoiceService) *InvoicesHandler {
+	return &InvoicesHandler{invoiceService: invoiceService}
+}
+
+// RegisterRoutes mounts the member endpoints (requires AuthMiddleware).
+func (h *InvoicesHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/me/invoices", h.ListMyInvoices)
+	router.GET("/me/invoices/:id", h.GetMyInvoice)
+	router.GET("/me/invoices/:id/pdf", h.DownloadPDF)
+	router.GET("/me/invoices/:id/json", h.DownloadJSON)
+}
+
+func (h *InvoicesHandler) ListMyInvoices(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	invoices, err := h.invoiceService.ListUserInvoices(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron obtener los comprobantes", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    invoices,
+	})
+}
+
+func (h *InvoicesHandler) GetMyInvoice(c *gin.Context) {
+	invoice, ok := h.loadInvoice(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    invoice,
+	})
+}
+
+func (h *InvoicesHandler) DownloadPDF(c *gin.Context) {
+	invoice, ok := h.loadInvoice(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+invoice.Code+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", h.invoiceService.RenderPDF(invoice))
+}
+
+func (h *InvoicesHandler) DownloadJSON(c *gin.Context) {
+	invoice, ok := h.loadInvoice(c)
+	if !ok {
+		return
+	}
+
+	document, err := h.invoiceService.RenderJSON(invoice)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo generar el comprobante", "INTERNAL_ERROR", err.Error())
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+invoice.Code+`.json"`)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", document)
+}
+
+func (h *InvoicesHandler) loadInvoice(c *gin.Context) (*models.Invoice, bool) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de comprobante invalido", "VALIDATION_ERROR", "")
+		return nil, false
+	}
+
+	invoice, err := h.invoiceService.GetUserInvoice(userID, uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			respondError(c, http.StatusNotFound, "Comprobante no encontrado", "NOT_FOUND", "")
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener el comprobante", "INTERNAL_ERROR", err.Error())
+		return nil, false
+	}
+	return invoice, true
+}
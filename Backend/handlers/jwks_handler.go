@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys used to sign access tokens.
+type JWKSHandler struct {
+	authService *services.AuthService
+}
+
+func NewJWKSHandler(authService *services.AuthService) *JWKSHandler {
+	return &JWKSHandler{authService: authService}
+}
+
+func (h *JWKSHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", h.GetKeys)
+}
+
+func (h *JWKSHandler) GetKeys(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// MembershipsHandler exposes membership plans to admins and quota status to members.
+type MembershipsHandler struct {
+	membershipService *services.MembershipService
+}
+
+type categoryQuotaRequest struct {
+	Category    string `json:"category" binding:"required"`
+	WeeklyQuota int    `json:"weekly_quota" binding:"required"`
+}
+
+type planRequest struct {
+	Name           string                 `json:"name" binding:"required"`
+	Description    string                 `json:"description"`
+	WeeklyQuota    int                    `json:"weekly_quota"`
+	DurationDays   int                    `json:"duration_days" binding:"required"`
+	PriceCents     int                    `json:"price_cents"`
+	IsActive       *bool                  `json:"is_active"`
+	CategoryQuotas []categoryQuotaRequest `json:"category_quotas"`
+}
+
+type assignPlanRequest struct {
+	PlanID   uint       `json:"plan_id" binding:"required"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+func NewMembershipsHandler(membershipService *services.MembershipService) *MembershipsHandler {
+	return &MembershipsHandler{membershipService: membershipService}
+}
+
+// RegisterRoutes mounts the member endpoints (requires AuthMiddleware).
+func (h *MembershipsHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/plans", h.ListActivePlans)
+	router.GET("/me/membership", h.GetMyMembership)
+}
+
+func (h *MembershipsHandler) RegisterAdminRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.GET("/admin/plans", require(security.PermMembershipsManage), h.ListPlans)
+	router.POST("/admin/plans", require(security.PermMembershipsManage), h.CreatePlan)
+	router.PUT("/admin/plans/:id", require(security.PermMembershipsManage), h.UpdatePlan)
+	router.GET("/admin/users/:id/memberships", require(security.PermMembershipsManage), h.ListUserMemberships)
+	router.POST("/admin/users/:id/memberships", require(security.PermMembershipsManage), h.AssignPlan)
+}
+
+func (h *MembershipsHandler) GetMyMembership(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.membershipService.QuotaStatus(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener tu membresía", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// ListActivePlans lists the plans a member can buy.
+func (h *MembershipsHandler) ListActivePlans(c *gin.Context) {
+	plans, err := h.membershipService.ListPlans(false)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar los planes", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    plans,
+	})
+}
+
+func (h *MembershipsHandler) ListPlans(c *gin.Context) {
+	plans, err := h.membershipService.ListPlans(true)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar los planes", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    plans,
+	})
+}
+
+func (h *MembershipsHandler) CreatePlan(c *gin.Context) {
+	var req planRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	plan, err := h.membershipService.CreatePlan(toPlanInput(req))
+	if err != nil {
+		respondMembershipError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Plan creado",
+		Data:    plan,
+	})
+}
+
+func (h *MembershipsHandler) UpdatePlan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de plan invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req planRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	plan, err := h.membershipService.UpdatePlan(uint(id), toPlanInput(req))
+	if err != nil {
+		respondMembershipError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Plan actualizado",
+		Data:    plan,
+	})
+}
+
+func (h *MembershipsHandler) ListUserMemberships(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de usuario invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	memberships, err := h.membershipService.ListUserMemberships(uint(userID))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar las membresías", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    memberships,
+	})
+}
+
+func (h *MembershipsHandler) AssignPlan(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de usuario invalido", "VALIDATION_ERROR", "")
+		return
+	}
+
+	var req assignPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	startsAt := time.Now()
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+
+	membership, err := h.membershipService.AssignPlan(uint(userID), req.PlanID, startsAt, req.EndsAt, actorFromContext(c))
+	if err != nil {
+		respondMembershipError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Plan asignado",
+		Data:    membership,
+	})
+}
+
+func toPlanInput(req planRequest) services.PlanInput {
+	input := services.PlanInput{
+		Name:           req.Name,
+		Description:    req.Description,
+		WeeklyQuota:    req.WeeklyQuota,
+		DurationDays:   req.DurationDays,
+		PriceCents:     req.PriceCents,
+		IsActive:       true,
+		CategoryQuotas: make(map[string]int, len(req.CategoryQuotas)),
+	}
+	if req.IsActive != nil {
+		input.IsActive = *req.IsActive
+	}
+	for _, quota := range req.CategoryQuotas {
+		input.CategoryQuotas[quota.Category] = quota.WeeklyQuota
+	}
+	return input
+}
+
+func respondMembershipError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPlanNotFound):
+		respondError(c, http.StatusNotFound, "Plan no encontrado", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrUserNotFound):
+		respondError(c, http.StatusNotFound, "Usuario no encontrado", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrPlanInactive):
+		respondError(c, http.StatusConflict, "El plan no está activo", "PLAN_INACTIVE", "")
+	case errors.Is(err, services.ErrInvalidPlan):
+		respondError(c, http.StatusBadRequest, "Datos de plan inválidos", "VALIDATION_ERROR", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "No se pudo procesar la membresía", "INTERNAL_ERROR", err.Error())
+	}
+}
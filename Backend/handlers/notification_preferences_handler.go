@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationPreferencesHandler lets members choose which notifications they receive.
+type NotificationPreferencesHandler struct {
+	preferenceService *services.NotificationPreferenceService
+}
+
+type notificationPreferencesRequest struct {
+	EmailEnabled      *bool `json:"email_enabled" binding:"required"`
+	RemindersEnabled  *bool `json:"reminders_enabled" binding:"required"`
+	ReminderLeadHours *int  `json:"reminder_lead_hours"`
+}
+
+func NewNotificationPreferencesHandler(preferenceService *services.NotificationPreferenceService) *NotificationPreferencesHandler {
+	return &NotificationPreferencesHandler{preferenceService: preferenceService}
+}
+
+// RegisterRoutes mounts the member endpoints (requires AuthMiddleware).
+func (h *NotificationPreferencesHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/me/notification-preferences", h.GetPreferences)
+	router.PUT("/me/notification-preferences", h.UpdatePreferences)
+}
+
+func (h *NotificationPreferencesHandler) GetPreferences(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.preferenceService.GetPreferences(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron obtener las preferencias", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    prefs,
+	})
+}
+
+func (h *NotificationPreferencesHandler) UpdatePreferences(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req notificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	prefs, err := h.preferenceService.UpdatePreferences(userID, *req.EmailEnabled, *req.RemindersEnabled, req.ReminderLeadHours)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPreference) {
+			respondError(c, http.StatusBadRequest, "Preferencias inválidas", "VALIDATION_ERROR", err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "No se pudieron guardar las preferencias", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Preferencias actualizadas",
+		Data:    prefs,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCHandler exposes the OpenID Connect login endpoints.
+type OIDCHandler struct {
+	authService *services.AuthService
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler(authService *services.AuthService, oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{authService: authService, oidcService: oidcService}
+}
+
+func (h *OIDCHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/auth/oidc/login", h.Login)
+	router.GET("/auth/oidc/callback", h.Callback)
+}
+
+// Login redirects the browser to the provider. With ?redirect=false the URL is returned as JSON.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.oidcService.BeginLogin(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCDisabled) {
+			respondError(c, http.StatusNotFound, "Login externo no habilitado", "OIDC_DISABLED", "")
+			return
+		}
+		respondError(c, http.StatusBadGateway, "No se pudo contactar al proveedor de identidad", "OIDC_ERROR", err.Error())
+		return
+	}
+
+	if c.Query("redirect") == "false" {
+		c.JSON(http.StatusOK, APIResponse{
+			Success: true,
+			Data:    gin.H{"authorization_url": authURL},
+		})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		respondError(c, http.StatusUnauthorized, "El proveedor rechazó el login", "UNAUTHORIZED", providerErr)
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		respondError(c, http.StatusBadRequest, "Faltan los parámetros state y code", "VALIDATION_ERROR", "")
+		return
+	}
+
+	user, err := h.oidcService.CompleteLogin(c.Request.Context(), state, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOIDCDisabled):
+			respondError(c, http.StatusNotFound, "Login externo no habilitado", "OIDC_DISABLED", "")
+		case errors.Is(err, services.ErrOIDCInvalidState):
+			respondError(c, http.StatusBadRequest, "La sesión de login expiró, intentá nuevamente", "OIDC_INVALID_STATE", "")
+		case errors.Is(err, services.ErrOIDCEmailNotVerified):
+			respondError(c, http.StatusForbidden, "El proveedor no verificó tu email", "OIDC_EMAIL_NOT_VERIFIED", "")
+		case errors.Is(err, services.ErrOIDCExchangeFailed), errors.Is(err, services.ErrOIDCInvalidIDToken):
+			respondError(c, http.StatusUnauthorized, "No se pudo validar la identidad externa", "UNAUTHORIZED", err.Error())
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudo completar el login", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	token, err := h.authService.GenerateJWT(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo generar el token", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Login exitoso",
+		Data: gin.H{
+			"token": token,
+			"user":  toUserResponse(user),
+		},
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PageLinks points to the neighbouring pages of a listing, relative to the API host.
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// parsePageRequest reads limit, offset, cursor and sort from the query string. offset and cursor
+// are mutually exclusive.
+func parsePageRequest(c *gin.Context, sortFields []string) (services.PageRequest, bool) {
+	var page services.PageRequest
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > services.MaxPageLimit {
+			respondError(c, http.StatusBadRequest, "limit debe estar entre 1 y "+strconv.Itoa(services.MaxPageLimit), "VALIDATION_ERROR", "")
+			return page, false
+		}
+		page.Limit = limit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			respondError(c, http.StatusBadRequest, "offset debe ser un entero mayor o igual a 0", "VALIDATION_ERROR", "")
+			return page, false
+		}
+		page.Offset = offset
+	}
+	page.Cursor = c.Query("cursor")
+	if page.Cursor != "" && c.Query("offset") != "" {
+		respondError(c, http.StatusBadRequest, "Usá offset o cursor, no ambos", "VALIDATION_ERROR", "")
+		return page, false
+	}
+
+	sort, err := services.ParseSort(c.Query("sort"), sortFields)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "sort inválido", "VALIDATION_ERROR", err.Error())
+		return page, false
+	}
+	page.Sort = sort
+	return page, true
+}
+
+// respondPage writes a page of a listing with its PageInfo as meta and the links to its neighbours.
+func respondPage(c *gin.Context, data interface{}, info *services.PageInfo) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    data,
+		Meta:    info,
+		Links:   pageLinks(c, info),
+	})
+}
+
+// pageLinks keeps the filters of the current request and moves its offset or cursor.
+func pageLinks(c *gin.Context, info *services.PageInfo) *PageLinks {
+	links := &PageLinks{Self: c.Request.URL.RequestURI()}
+	withQuery := func(set map[string]string) string {
+		query := c.Request.URL.Query()
+		query.Del("offset")
+		query.Del("cursor")
+		for key, value := range set {
+			query.Set(key, value)
+		}
+		return c.Request.URL.Path + "?" + query.Encode()
+	}
+
+	if info.Offset == nil {
+		if info.HasMore {
+			links.Next = withQuery(map[string]string{"cursor": info.NextCursor})
+		}
+		return links
+	}
+	offset := *info.Offset
+	if info.HasMore {
+		links.Next = withQuery(map[string]string{"offset": strconv.Itoa(offset + info.Limit)})
+	}
+	if offset > 0 {
+		prev := offset - info.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = withQuery(map[string]string{"offset": strconv.Itoa(prev)})
+	}
+	return links
+}
+
+// respondPageError maps pagination errors to VALIDATION_ERROR and anything else to a 500.
+func respondPageError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSort):
+		respondError(c, http.StatusBadRequest, "sort inválido", "VALIDATION_ERROR", err.Error())
+	case errors.Is(err, services.ErrInvalidCursor):
+		respondError(c, http.StatusBadRequest, "cursor inválido o de otro orden", "VALIDATION_ERROR", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, message, "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alesio/gestion-actividades-deportivas/payments"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentsHandler exposes member checkouts and the provider webhook.
+type PaymentsHandler struct {
+	paymentService *services.PaymentService
+}
+
+type membershipCheckoutRequest struct {
+	PlanID uint `json:"plan_id" binding:"required"`
+}
+
+func NewPaymentsHandler(paymentService *services.PaymentService) *PaymentsHandler {
+	return &PaymentsHandler{paymentService: paymentService}
+}
+
+// RegisterRoutes mounts the member endpoints (requires AuthMiddleware).
+func (h *PaymentsHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/me/membership/checkout", h.CheckoutMembership)
+	router.GET("/me/payments", h.ListMyPayments)
+}
+
+// RegisterWebhookRoutes mounts the public webhook; requests are authenticated by their signature.
+func (h *PaymentsHandler) RegisterWebhookRoutes(router *gin.RouterGroup) {
+	router.POST("/payments/webhook/:provider", h.Webhook)
+}
+
+func (h *PaymentsHandler) CheckoutMembership(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req membershipCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	payment, err := h.paymentService.StartMembershipCheckout(userID, req.PlanID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPlanNotFound):
+			respondError(c, http.StatusNotFound, "Plan no encontrado", "NOT_FOUND", "")
+		case errors.Is(err, services.ErrPlanInactive):
+			respondError(c, http.StatusConflict, "El plan no está activo", "PLAN_INACTIVE", "")
+		case errors.Is(err, services.ErrNothingToPay):
+			respondError(c, http.StatusBadRequest, "El plan no tiene precio", "NOTHING_TO_PAY", "")
+		default:
+			respondPaymentError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Pago iniciado",
+		Data:    payment,
+	})
+}
+
+func (h *PaymentsHandler) ListMyPayments(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.paymentService.ListUserPayments(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron obtener los pagos", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+func (h *PaymentsHandler) Webhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "No se pudo leer el cuerpo", "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	if err := h.paymentService.HandleWebhook(c.Param("provider"), c.Request.Header, body); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			respondError(c, http.StatusNotFound, "Proveedor de pagos desconocido", "NOT_FOUND", "")
+		case errors.Is(err, payments.ErrInvalidSignature):
+			respondError(c, http.StatusUnauthorized, "Firma inválida", "INVALID_SIGNATURE", "")
+		case errors.Is(err, payments.ErrInvalidEvent):
+			respondError(c, http.StatusBadRequest, "Evento inválido", "VALIDATION_ERROR", "")
+		case errors.Is(err, services.ErrPaymentNotFound):
+			respondError(c, http.StatusNotFound, "Pago no encontrado", "PAYMENT_NOT_FOUND", "")
+		default:
+			respondError(c, http.StatusInternalServerError, "No se pudo procesar la notificación", "INTERNAL_ERROR", err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{Success: true})
+}
+
+// respondPaymentError covers the checkout failures shared by enrollments and memberships.
+func respondPaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentsUnavailable):
+		respondError(c, http.StatusServiceUnavailable, "Los pagos no están disponibles", "PAYMENTS_UNAVAILABLE", "")
+	default:
+		respondError(c, http.StatusBadGateway, "No se pudo iniciar el pago", "PAYMENT_PROVIDER_ERROR", err.Error())
+	}
+}
@@ -5,6 +5,9 @@ type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	// Meta and Links are set on paginated listings: totals and the neighbouring pages.
+	Meta  interface{} `json:"meta,omitempty"`
+	Links interface{} `json:"links,omitempty"`
 }
 
 // APIError represents a standard error response envelope.
@@ -13,4 +16,6 @@ type APIError struct {
 	Error   string `json:"error"`
 	Code    string `json:"code,omitempty"`
 	Details string `json:"details,omitempty"`
+	// Data carries a structured payload for errors the client can act on (e.g. UPDATE_IMPACT).
+	Data interface{} `json:"data,omitempty"`
 }
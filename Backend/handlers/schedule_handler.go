@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler serves the weekly timetable.
+type ScheduleHandler struct {
+	activityService *services.ActivityService
+}
+
+func NewScheduleHandler(activityService *services.ActivityService) *ScheduleHandler {
+	return &ScheduleHandler{activityService: activityService}
+}
+
+// RegisterRoutes mounts the timetable on a group using AuthMiddleware.Optional: it is public, and
+// personalized when the caller sends a valid token.
+func (h *ScheduleHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/schedule", h.GetSchedule)
+}
+
+// GetSchedule accepts the same filters as GET /activities (see parseActivityFilter).
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	filter, ok := parseActivityFilter(c)
+	if !ok {
+		return
+	}
+
+	userID := optionalUserID(c)
+	days, err := h.activityService.GetSchedule(filter, userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudo obtener la grilla", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data: gin.H{
+			"personalized": userID != nil,
+			"days":         days,
+		},
+	})
+}
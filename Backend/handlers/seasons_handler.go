@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SeasonsHandler exposes the seasons of the timetable, lets members re-enroll for the next one
+// and lets admins manage them.
+type SeasonsHandler struct {
+	seasonService *services.SeasonService
+}
+
+type seasonRequest struct {
+	Name              string `json:"name" binding:"required"`
+	StartsOn          string `json:"starts_on" binding:"required"`
+	EndsOn            string `json:"ends_on" binding:"required"`
+	EnrollmentOpensOn string `json:"enrollment_opens_on"`
+	PriorityOpensOn   string `json:"priority_opens_on"`
+}
+
+// reenrollItem is the outcome of re-enrolling in one activity: the enrollment or, when it could
+// not be made, the error code and message of a single enrollment.
+type reenrollItem struct {
+	ActivityID       uint               `json:"activity_id"`
+	SourceActivityID uint               `json:"source_activity_id"`
+	Title            string             `json:"title"`
+	Enrollment       *models.Enrollment `json:"enrollment,omitempty"`
+	Code             string             `json:"code,omitempty"`
+	Error            string             `json:"error,omitempty"`
+}
+
+func NewSeasonsHandler(seasonService *services.SeasonService) *SeasonsHandler {
+	return &SeasonsHandler{seasonService: seasonService}
+}
+
+// RegisterRoutes mounts the public list of seasons.
+func (h *SeasonsHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/seasons", h.ListSeasons)
+}
+
+// RegisterMemberRoutes mounts the re-enrollment of the logged-in member.
+func (h *SeasonsHandler) RegisterMemberRoutes(router *gin.RouterGroup) {
+	router.POST("/seasons/:id/reenroll", h.Reenroll)
+}
+
+// RegisterAdminRoutes mounts the season management, guarded by the activity permissions.
+func (h *SeasonsHandler) RegisterAdminRoutes(router *gin.RouterGroup, require func(permission string) gin.HandlerFunc) {
+	router.POST("/admin/seasons", require(security.PermActivitiesWrite), h.CreateSeason)
+	router.PUT("/admin/seasons/:id", require(security.PermActivitiesWrite), h.UpdateSeason)
+	router.DELETE("/admin/seasons/:id", require(security.PermActivitiesDelete), h.DeleteSeason)
+}
+
+func (h *SeasonsHandler) ListSeasons(c *gin.Context) {
+	seasons, err := h.seasonService.ListSeasons()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "No se pudieron listar las temporadas", "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    seasons,
+	})
+}
+
+func (h *SeasonsHandler) CreateSeason(c *gin.Context) {
+	input, ok := bindSeasonRequest(c)
+	if !ok {
+		return
+	}
+
+	season, err := h.seasonService.CreateSeason(input, actorFromContext(c))
+	if err != nil {
+		respondSeasonError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Success: true,
+		Message: "Temporada creada",
+		Data:    season,
+	})
+}
+
+func (h *SeasonsHandler) UpdateSeason(c *gin.Context) {
+	id, ok := parseSeasonID(c)
+	if !ok {
+		return
+	}
+	input, ok := bindSeasonRequest(c)
+	if !ok {
+		return
+	}
+
+	season, err := h.seasonService.UpdateSeason(id, input, actorFromContext(c))
+	if err != nil {
+		respondSeasonError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Temporada actualizada",
+		Data:    season,
+	})
+}
+
+func (h *SeasonsHandler) DeleteSeason(c *gin.Context) {
+	id, ok := parseSeasonID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.seasonService.DeleteSeason(id, actorFromContext(c)); err != nil {
+		respondSeasonError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: "Temporada eliminada",
+	})
+}
+
+// Reenroll enrolls the member in the activities of the season that continue theirs. Each one is
+// an enrollment of its own and may fail on its own rules; the response lists every outcome.
+func (h *SeasonsHandler) Reenroll(c *gin.Context) {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+	id, ok := parseSeasonID(c)
+	if !ok {
+		return
+	}
+
+	results, err := h.seasonService.Reenroll(userID, id, actorFromContext(c))
+	if err != nil {
+		respondSeasonError(c, err)
+		return
+	}
+
+	items := make([]reenrollItem, 0, len(results))
+	enrolled := 0
+	for _, result := range results {
+		item := reenrollItem{
+			ActivityID:       result.ActivityID,
+			SourceActivityID: result.SourceActivityID,
+			Title:            result.Title,
+			Enrollment:       result.Enrollment,
+		}
+		if result.Err != nil {
+			_, body := enrollmentError(result.Err)
+			item.Code, item.Error = body.Code, body.Error
+		} else {
+			enrolled++
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Te reinscribimos en %d de %d actividades", enrolled, len(items)),
+		Data:    items,
+	})
+}
+
+func bindSeasonRequest(c *gin.Context) (services.SeasonInput, bool) {
+	var req seasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "Payload inválido", "VALIDATION_ERROR", err.Error())
+		return services.SeasonInput{}, false
+	}
+	return services.SeasonInput{
+		Name:              req.Name,
+		StartsOn:          req.StartsOn,
+		EndsOn:            req.EndsOn,
+		EnrollmentOpensOn: req.EnrollmentOpensOn,
+		PriorityOpensOn:   req.PriorityOpensOn,
+	}, true
+}
+
+func parseSeasonID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "ID de temporada invalido", "VALIDATION_ERROR", "")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondSeasonError maps season service errors to their API error codes.
+func respondSeasonError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSeasonNotFound):
+		respondError(c, http.StatusNotFound, "Temporada no encontrada", "NOT_FOUND", "")
+	case errors.Is(err, services.ErrSeasonExists):
+		respondError(c, http.StatusConflict, "Ya existe una temporada con ese nombre", "SEASON_EXISTS", "")
+	case errors.Is(err, services.ErrSeasonOverlap):
+		respondError(c, http.StatusConflict, "Las fechas se superponen con otra temporada", "SEASON_OVERLAP", err.Error())
+	case errors.Is(err, services.ErrSeasonInUse):
+		respondError(c, http.StatusConflict, "La temporada tiene actividades asociadas", "SEASON_IN_USE", err.Error())
+	case errors.Is(err, services.ErrSeasonEnded):
+		respondError(c, http.StatusConflict, "La temporada ya terminó", "SEASON_ENDED", err.Error())
+	case errors.Is(err, services.ErrNothingToReenroll):
+		respondError(c, http.StatusNotFound, "Ninguna actividad de la temporada continúa las tuyas", "NOTHING_TO_REENROLL", "")
+	case errors.Is(err, services.ErrInvalidSeason):
+		respondError(c, http.StatusBadRequest, "Datos de temporada inválidos", "VALIDATION_ERROR", err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, "No se pudo procesar la temporada", "INTERNAL_ERROR", err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+// Package ical writes RFC 5545 iCalendar documents for the weekly class timetable.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed before folding (RFC 5545 §3.1).
+const maxLineOctets = 75
+
+var byDay = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Calendar is a VCALENDAR with weekly recurring events in a single time zone.
+type Calendar struct {
+	// ProdID identifies the product that generated the document.
+	ProdID string
+	// Name is shown by clients as the calendar title (X-WR-CALNAME).
+	Name     string
+	Location *time.Location
+	Events   []Event
+}
+
+// Event is a VEVENT repeating every week from Start until Until (forever when zero).
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Categories  []string
+	Start       time.Time
+	End         time.Time
+	Until       time.Time
+	// Exceptions are the start times of cancelled occurrences (EXDATE).
+	Exceptions   []time.Time
+	LastModified time.Time
+}
+
+// Bytes renders the calendar with CRLF line endings and folded lines.
+func (c *Calendar) Bytes(now time.Time) []byte {
+	w := &writer{}
+	tzid := c.Location.String()
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", c.ProdID)
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.line("X-WR-CALNAME", Escape(c.Name))
+	w.line("X-WR-TIMEZONE", tzid)
+	c.writeTimezone(w, now)
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, evt := range c.Events {
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", evt.UID)
+		w.line("DTSTAMP", stamp)
+		w.line("DTSTART;TZID="+tzid, localStamp(evt.Start, c.Location))
+		w.line("DTEND;TZID="+tzid, localStamp(evt.End, c.Location))
+		rule := "FREQ=WEEKLY;BYDAY=" + byDay[evt.Start.In(c.Location).Weekday()]
+		if !evt.Until.IsZero() {
+			rule += ";UNTIL=" + evt.Until.UTC().Format("20060102T150405Z")
+		}
+		w.line("RRULE", rule)
+		for _, exception := range evt.Exceptions {
+			w.line("EXDATE;TZID="+tzid, localStamp(exception, c.Location))
+		}
+		w.line("SUMMARY", Escape(evt.Summary))
+		if evt.Description != "" {
+			w.line("DESCRIPTION", Escape(evt.Description))
+		}
+		if evt.Location != "" {
+			w.line("LOCATION", Escape(evt.Location))
+		}
+		if len(evt.Categories) > 0 {
+			escaped := make([]string, 0, len(evt.Categories))
+			for _, category := range evt.Categories {
+				escaped = append(escaped, Escape(category))
+			}
+			w.line("CATEGORIES", strings.Join(escaped, ","))
+		}
+		if !evt.LastModified.IsZero() {
+			w.line("LAST-MODIFIED", evt.LastModified.UTC().Format("20060102T150405Z"))
+		}
+		w.line("STATUS", "CONFIRMED")
+		w.line("END", "VEVENT")
+	}
+	w.line("END", "VCALENDAR")
+	return w.buf.Bytes()
+}
+
+// writeTimezone emits a VTIMEZONE with the zone's offset at now. It is exact for zones without
+// daylight saving time; clients that know the TZID use their own rules anyway.
+func (c *Calendar) writeTimezone(w *writer, now time.Time) {
+	name, offset := now.In(c.Location).Zone()
+	w.line("BEGIN", "VTIMEZONE")
+	w.line("TZID", c.Location.String())
+	w.line("BEGIN", "STANDARD")
+	w.line("DTSTART", "19700101T000000")
+	w.line("TZOFFSETFROM", formatOffset(offset))
+	w.line("TZOFFSETTO", formatOffset(offset))
+	w.line("TZNAME", Escape(name))
+	w.line("END", "STANDARD")
+	w.line("END", "VTIMEZONE")
+}
+
+// Escape escapes a TEXT value (RFC 5545 §3.3.11).
+func Escape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return replacer.Replace(value)
+}
+
+func localStamp(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("20060102T150405")
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+type writer struct {
+	buf bytes.Buffer
+}
+
+// line writes "name:value", folding it into 75-octet lines without splitting UTF-8 sequences.
+func (w *writer) line(name, value string) {
+	content := name + ":" + value
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		w.buf.WriteString(content[:cut])
+		w.buf.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+	w.buf.WriteString(content)
+	w.buf.WriteString("\r\n")
+}
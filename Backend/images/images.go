@@ -0,0 +1,183 @@
+// Package images validates uploaded pictures and resizes them into the variants the frontend
+// shows: listings use the thumbnail and the detail page the large one. Every variant is a JPEG
+// flattened on white, with the EXIF orientation applied and the metadata dropped.
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image dimensions too large")
+	ErrInvalidImage    = errors.New("invalid image")
+)
+
+const (
+	// MaxSide and MaxPixels bound the decoded size, which is checked before decoding so a small
+	// file cannot expand into a huge bitmap.
+	MaxSide   = 10000
+	MaxPixels = 40_000_000
+
+	// ContentType and Extension describe every variant.
+	ContentType = "image/jpeg"
+	Extension   = ".jpg"
+
+	jpegQuality = 85
+)
+
+// SupportedTypes are the accepted content types, as sniffed from the file itself.
+var SupportedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// Variant is a size uploads are resized to: they fit in MaxWidth x MaxHeight, keeping the aspect
+// ratio, and are never enlarged.
+type Variant struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+var Variants = []Variant{
+	{Name: "large", MaxWidth: 1600, MaxHeight: 1600},
+	{Name: "medium", MaxWidth: 800, MaxHeight: 800},
+	{Name: "thumb", MaxWidth: 320, MaxHeight: 320},
+}
+
+// Rendition is an encoded variant.
+type Rendition struct {
+	Variant string
+	Width   int
+	Height  int
+	Data    []byte
+}
+
+// Sniff returns the content type of data, ignoring whatever the client declared.
+func Sniff(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// Process checks that data is a supported image of acceptable dimensions and renders every
+// variant.
+func Process(data []byte) ([]Rendition, error) {
+	contentType := Sniff(data)
+	supported := false
+	for _, candidate := range SupportedTypes {
+		supported = supported || candidate == contentType
+	}
+	if !supported {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if config.Width > MaxSide || config.Height > MaxSide || config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	orientation := 1
+	if contentType == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	renditions := make([]Rendition, 0, len(Variants))
+	for _, variant := range Variants {
+		rendition, err := render(source, orientation, variant)
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, rendition)
+	}
+	return renditions, nil
+}
+
+func render(source image.Image, orientation int, variant Variant) (Rendition, error) {
+	// Orientations 5 to 8 turn the picture by 90 degrees: fit the box with the sides swapped.
+	maxWidth, maxHeight := variant.MaxWidth, variant.MaxHeight
+	if orientation >= 5 {
+		maxWidth, maxHeight = maxHeight, maxWidth
+	}
+	width, height := fit(source.Bounds().Dx(), source.Bounds().Dy(), maxWidth, maxHeight)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(scaled, scaled.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), source, source.Bounds(), xdraw.Over, nil)
+	oriented := orient(scaled, orientation)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, oriented, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return Rendition{}, err
+	}
+	return Rendition{
+		Variant: variant.Name,
+		Width:   oriented.Bounds().Dx(),
+		Height:  oriented.Bounds().Dy(),
+		Data:    buf.Bytes(),
+	}, nil
+}
+
+// fit scales width x height down to fit in maxWidth x maxHeight, keeping the aspect ratio.
+func fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+	if width*maxHeight > height*maxWidth {
+		return maxWidth, max(1, height*maxWidth/width)
+	}
+	return max(1, width*maxHeight/height), maxHeight
+}
+
+// orient applies an EXIF orientation (1 to 8) so the picture displays upright.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	bounds := image.Rect(0, 0, width, height)
+	if orientation >= 5 {
+		bounds = image.Rect(0, 0, height, width)
+	}
+	dst := image.NewRGBA(bounds)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = width-1-x, y
+			case 3: // upside down
+				dx, dy = width-1-x, height-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, height-1-y
+			case 5: // mirrored, turned left
+				dx, dy = y, x
+			case 6: // turned left: rotate clockwise
+				dx, dy = height-1-y, x
+			case 7: // mirrored, turned right
+				dx, dy = height-1-y, width-1-x
+			case 8: // turned right: rotate counter-clockwise
+				dx, dy = y, width-1-x
+			}
+			dst.SetRGBA(dx, dy, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
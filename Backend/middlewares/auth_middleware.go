@@ -10,18 +10,30 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates tokens and hydrates the context with user data.
+// AuthMiddleware validates tokens or API keys and hydrates the context with the caller.
 type AuthMiddleware struct {
-	authService *services.AuthService
+	authService   *services.AuthService
+	apiKeyService *services.APIKeyService
 }
 
-func NewAuthMiddleware(authService *services.AuthService) *AuthMiddleware {
-	return &AuthMiddleware{authService: authService}
+func NewAuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService) *AuthMiddleware {
+	return &AuthMiddleware{authService: authService, apiKeyService: apiKeyService}
 }
 
+// Handle accepts a bearer JWT or an API key (X-API-Key header or bearer value starting with gad_).
+// API key callers get "apiKeyID" and "scopes" instead of "userID", so routes must opt in through PermissionMiddleware.Require.
 func (m *AuthMiddleware) Handle() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
+		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" && strings.HasPrefix(header, "Bearer ") && services.IsAPIKey(strings.TrimPrefix(header, "Bearer ")) {
+			apiKey = strings.TrimPrefix(header, "Bearer ")
+		}
+		if apiKey != "" {
+			m.handleAPIKey(c, apiKey)
+			return
+		}
+
 		if header == "" || !strings.HasPrefix(header, "Bearer ") {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, handlers.APIError{
 				Success: false,
@@ -63,3 +75,57 @@ func (m *AuthMiddleware) Handle() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// Optional identifies the member behind a bearer JWT when there is one, for public routes that
+// personalize their response. Requests without a valid JWT, or with an API key, continue
+// anonymously instead of being rejected.
+func (m *AuthMiddleware) Optional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.Next()
+			return
+		}
+		token := strings.TrimPrefix(header, "Bearer ")
+		if services.IsAPIKey(token) {
+			c.Next()
+			return
+		}
+		if claims, err := m.authService.ValidateJWT(token); err == nil {
+			c.Set("userID", claims.UserID)
+			c.Set("role", claims.Role)
+		}
+		c.Next()
+	}
+}
+
+func (m *AuthMiddleware) handleAPIKey(c *gin.Context, plain string) {
+	key, err := m.apiKeyService.Authenticate(plain)
+	if err != nil {
+		status := http.StatusUnauthorized
+		message := "API key inválida"
+		code := "UNAUTHORIZED"
+
+		switch {
+		case errors.Is(err, services.ErrAPIKeyExpired):
+			message = "API key expirada"
+		case errors.Is(err, services.ErrInvalidAPIKey):
+			// keep defaults
+		default:
+			status = http.StatusInternalServerError
+			message = "No se pudo validar la API key"
+			code = "INTERNAL_ERROR"
+		}
+
+		c.AbortWithStatusJSON(status, handlers.APIError{
+			Success: false,
+			Error:   message,
+			Code:    code,
+		})
+		return
+	}
+
+	c.Set("apiKeyID", key.ID)
+	c.Set("scopes", key.Scopes)
+	c.Next()
+}
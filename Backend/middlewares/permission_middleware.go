@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/alesio/gestion-actividades-deportivas/handlers"
+	"github.com/alesio/gestion-actividades-deportivas/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionMiddleware enforces the permission each route declares. Users are checked
+// through their role; API keys through the scopes they were granted.
+type PermissionMiddleware struct {
+	rbacService *services.RBACService
+}
+
+func NewPermissionMiddleware(rbacService *services.RBACService) *PermissionMiddleware {
+	return &PermissionMiddleware{rbacService: rbacService}
+}
+
+// Require must run after AuthMiddleware.Handle.
+func (m *PermissionMiddleware) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopesValue, isAPIKey := c.Get("scopes"); isAPIKey {
+			scopes, _ := scopesValue.([]string)
+			for _, granted := range scopes {
+				if granted == permission {
+					c.Next()
+					return
+				}
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, handlers.APIError{
+				Success: false,
+				Error:   "La API key no tiene el permiso " + permission,
+				Code:    "INSUFFICIENT_SCOPE",
+			})
+			return
+		}
+
+		roleValue, exists := c.Get("role")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, handlers.APIError{
+				Success: false,
+				Error:   "Contexto de rol faltante",
+				Code:    "FORBIDDEN",
+			})
+			return
+		}
+
+		role, _ := roleValue.(string)
+		allowed, err := m.rbacService.Can(role, permission)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, handlers.APIError{
+				Success: false,
+				Error:   "No se pudieron verificar los permisos",
+				Code:    "INTERNAL_ERROR",
+				Details: err.Error(),
+			})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, handlers.APIError{
+				Success: false,
+				Error:   "No tenés permiso para realizar esta acción",
+				Code:    "FORBIDDEN",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -2,24 +2,63 @@ package models
 
 import "time"
 
-// Activity describes sports activities offered by the gym.
+// Activity lifecycle statuses. Published and paused activities are listed; only published ones
+// take enrollments and hold classes.
+const (
+	ActivityDraft     = "borrador"
+	ActivityPublished = "publicada"
+	ActivityPaused    = "pausada"
+	ActivityArchived  = "archivada"
+)
+
+// Activity describes sports activities offered by the gym. Title, Description, Category and
+// Instructor share the FULLTEXT index used by search.SQLIndex. CategoryID references the catalogue
+// and Category keeps a copy of its slug for filtering, search and plan quotas.
 type Activity struct {
 	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title       string `gorm:"size:255;not null" json:"title"`
-	Description string `gorm:"type:text" json:"description"`
-	Category    string `gorm:"size:100;not null" json:"category"`
+	Title       string `gorm:"size:255;not null;index:idx_activities_search,class:FULLTEXT" json:"title"`
+	Description string `gorm:"type:text;index:idx_activities_search,class:FULLTEXT" json:"description"`
+	Category    string `gorm:"size:100;not null;index:idx_activities_search,class:FULLTEXT" json:"category"`
+	CategoryID  *uint  `gorm:"index" json:"category_id"`
 	DayOfWeek   int    `gorm:"not null" json:"day_of_week"`
 	StartTime   string `gorm:"size:8;not null" json:"start_time"`
 	EndTime     string `gorm:"size:8;not null" json:"end_time"`
 	Capacity    int    `gorm:"not null" json:"capacity"`
-	Instructor  string `gorm:"size:255;not null" json:"instructor"`
+	Instructor  string `gorm:"size:255;not null;index:idx_activities_search,class:FULLTEXT" json:"instructor"`
+	Location    string `gorm:"size:255" json:"location"`
 	ImageURL    string `gorm:"size:512" json:"image_url"`
-	IsActive    bool   `gorm:"default:true" json:"is_active"`
+	// PriceCents is charged on top of the membership; 0 means the class is included.
+	PriceCents int `gorm:"not null;default:0" json:"price_cents"`
+	// ImageKey locates the variants of an uploaded image in blob storage and ThumbnailURL serves
+	// the smallest one. Both are empty when ImageURL points elsewhere.
+	ImageKey     string `gorm:"size:255" json:"-"`
+	ThumbnailURL string `gorm:"size:512" json:"thumbnail_url"`
+	// Status is the lifecycle status and IsActive mirrors whether it is listed. IsActive has no
+	// column default, so that drafts can be created unlisted.
+	Status   string `gorm:"size:20;not null;default:publicada;index" json:"status"`
+	IsActive bool   `json:"is_active"`
+	// SourceActivityID is the activity this one was cloned from, if any.
+	SourceActivityID *uint `gorm:"index" json:"source_activity_id"`
+	// SeasonID is the season the activity is held in; without one it is held all year round.
+	SeasonID *uint `gorm:"index" json:"season_id"`
 	// Computed fields populated at runtime so the frontend can render cupos dinámicos.
-	AvailableSlots int       `gorm:"-" json:"available_slots"`
-	EnrolledCount  int       `gorm:"-" json:"enrolled_count"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	AvailableSlots int `gorm:"-" json:"available_slots"`
+	EnrolledCount  int `gorm:"-" json:"enrolled_count"`
+	// EligibilityRules are loaded with the activity by listings and lookups; they are stored and
+	// changed on their own, not by saving the activity. Eligibility is only filled in for a
+	// logged-in member.
+	EligibilityRules []EligibilityRule `gorm:"-" json:"eligibility_rules,omitempty"`
+	Eligibility      *Eligibility      `gorm:"-" json:"eligibility,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
 
 	Enrollments []Enrollment `gorm:"foreignKey:ActivityID" json:"-"`
+	CategoryRef *Category    `gorm:"foreignKey:CategoryID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	SeasonRef   *Season      `gorm:"foreignKey:SeasonID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+}
+
+// SetStatus changes the lifecycle status and keeps IsActive in line with it.
+func (a *Activity) SetStatus(status string) {
+	a.Status = status
+	a.IsActive = status == ActivityPublished || status == ActivityPaused
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// APIKey grants machine-to-machine access limited to a set of scopes. Only the hash is stored.
+type APIKey struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string     `gorm:"size:255;not null" json:"name"`
+	Prefix      string     `gorm:"size:16;not null;uniqueIndex" json:"prefix"`
+	KeyHash     string     `gorm:"size:64;not null" json:"-"`
+	Scopes      string     `gorm:"size:512;not null" json:"-"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedByID uint       `gorm:"not null;index" json:"created_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	CreatedBy User `gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogImmutable is returned when an audit entry is updated or deleted through the model.
+var ErrAuditLogImmutable = errors.New("audit log entries cannot be modified")
+
+// AuditLog is an append-only record of an admin change: who made it (a user or an API key), the
+// action and how each field changed. Changes holds a JSON object mapping every changed field to
+// its "before" and "after" values. Activity entries also keep the whole activity after the change
+// in Snapshot, numbered by Version, so a previous version can be restored.
+type AuditLog struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorUserID   *uint     `gorm:"index" json:"actor_user_id"`
+	ActorAPIKeyID *uint     `gorm:"index" json:"actor_api_key_id"`
+	Action        string    `gorm:"size:50;not null;index" json:"action"`
+	EntityType    string    `gorm:"size:30;not null;index:idx_audit_entity,priority:1" json:"entity_type"`
+	EntityID      uint      `gorm:"not null;index:idx_audit_entity,priority:2" json:"entity_id"`
+	Version       int       `gorm:"not null;default:0" json:"version"`
+	Changes       string    `gorm:"type:text" json:"-"`
+	Snapshot      string    `gorm:"type:text" json:"-"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+func (AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// CalendarToken is the secret behind a member's personal calendar URL. Only its hash is stored;
+// issuing a new one replaces the previous token.
+type CalendarToken struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	User User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// ActivityCancellation marks a single date on which a weekly activity does not take place.
+type ActivityCancellation struct {
+	ID         uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActivityID uint `gorm:"not null;uniqueIndex:idx_activity_cancellation_date" json:"activity_id"`
+	// Date is the day of the cancelled session, stored as YYYY-MM-DD in the gym's time zone.
+	Date        string    `gorm:"size:10;not null;uniqueIndex:idx_activity_cancellation_date" json:"date"`
+	Reason      string    `gorm:"size:255" json:"reason"`
+	CreatedByID uint      `gorm:"not null" json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Activity Activity `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Category is an entry of the managed catalogue activities are filed under. Slug is the stable
+// identifier used by filters, plan quotas and the activities' own category column; it cannot
+// change once created. Name, Color and Icon are for display.
+type Category struct {
+	ID   uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Slug string `gorm:"size:100;not null;uniqueIndex" json:"slug"`
+	Name string `gorm:"size:100;not null" json:"name"`
+	// Color is a hex color such as "#3FA34D".
+	Color string `gorm:"size:7" json:"color"`
+	// Icon names an icon of the frontend's set, e.g. "dumbbell".
+	Icon      string    `gorm:"size:50" json:"icon"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
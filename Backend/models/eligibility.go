@@ -0,0 +1,35 @@
+package models
+
+// Eligibility rule types. Value holds the parameter of the rule as text.
+const (
+	// RuleMinAge and RuleMaxAge bound the member's age in years, inclusive: "18".
+	RuleMinAge = "min_age"
+	RuleMaxAge = "max_age"
+	// RuleMinLevel requires a member level at least as high as one of MemberLevels: "avanzado".
+	RuleMinLevel = "min_level"
+	// RulePlan requires an active membership of one of the plans listed by id: "2,5".
+	RulePlan = "plan"
+	// RulePrerequisite requires having completed another activity, given by id: "7". An activity
+	// is completed when an enrollment in it finished with its season ("finalizado"); a current
+	// enrollment does not count. The prerequisite must be held in a season, as all-year activities
+	// never finish.
+	RulePrerequisite = "prerequisite"
+)
+
+// EligibilityRule restricts who may enroll in an activity. A member must satisfy every rule of
+// the activity to enroll.
+type EligibilityRule struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	ActivityID uint   `gorm:"not null;index" json:"-"`
+	Type       string `gorm:"size:20;not null" json:"type"`
+	Value      string `gorm:"size:255;not null" json:"value"`
+
+	Activity Activity `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// Eligibility tells a member whether the eligibility rules of an activity let them enroll and,
+// when they do not, the reason codes of the rules they fail.
+type Eligibility struct {
+	Eligible bool     `json:"eligible"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
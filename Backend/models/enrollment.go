@@ -4,13 +4,20 @@ import "time"
 
 // Enrollment links a user with an activity.
 type Enrollment struct {
-	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID     uint      `gorm:"not null;index" json:"user_id"`
-	ActivityID uint      `gorm:"not null;index" json:"activity_id"`
-	Status     string    `gorm:"size:20;not null;default:'inscripto'" json:"status"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint   `gorm:"not null;index" json:"user_id"`
+	ActivityID uint   `gorm:"not null;index" json:"activity_id"`
+	Status     string `gorm:"size:20;not null;default:'inscripto'" json:"status"`
+	// HoldExpiresAt is set while a paid enrollment is "pendiente_pago": the seat is held until then.
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+	// ScheduleConflict flags enrollments that overlap another one after an activity was rescheduled.
+	ScheduleConflict bool `gorm:"not null;default:false" json:"schedule_conflict"`
+	// WaitlistedAt orders "lista_espera" enrollments: the oldest is promoted first when a seat frees up.
+	WaitlistedAt *time.Time `gorm:"index" json:"waitlisted_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 
 	User     User     `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
 	Activity Activity `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Payment  *Payment `gorm:"foreignKey:EnrollmentID" json:"payment,omitempty"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// InvoiceSequence holds the last number issued for a branch. It is locked while issuing so
+// numbering stays sequential and gap-free.
+type InvoiceSequence struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement"`
+	Branch     string `gorm:"size:20;not null;uniqueIndex"`
+	LastNumber uint   `gorm:"not null;default:0"`
+}
+
+// Invoice is the receipt issued for an approved payment.
+type Invoice struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Branch        string    `gorm:"size:20;not null;uniqueIndex:idx_invoice_branch_number" json:"branch"`
+	Number        uint      `gorm:"not null;uniqueIndex:idx_invoice_branch_number" json:"number"`
+	Code          string    `gorm:"size:40;not null;uniqueIndex" json:"code"`
+	UserID        uint      `gorm:"not null;index" json:"user_id"`
+	PaymentID     uint      `gorm:"not null;uniqueIndex" json:"payment_id"`
+	CustomerName  string    `gorm:"size:255;not null" json:"customer_name"`
+	CustomerEmail string    `gorm:"size:255;not null" json:"customer_email"`
+	Currency      string    `gorm:"size:3;not null" json:"currency"`
+	TotalCents    int       `gorm:"not null" json:"total_cents"`
+	IssuedAt      time.Time `gorm:"not null" json:"issued_at"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	User  User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Lines []InvoiceLine `gorm:"foreignKey:InvoiceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"lines"`
+}
+
+// InvoiceLine is an item of an invoice, linked to the membership or enrollment it pays for.
+type InvoiceLine struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	InvoiceID    uint   `gorm:"not null;index" json:"-"`
+	Description  string `gorm:"size:255;not null" json:"description"`
+	Quantity     int    `gorm:"not null" json:"quantity"`
+	UnitCents    int    `gorm:"not null" json:"unit_cents"`
+	TotalCents   int    `gorm:"not null" json:"total_cents"`
+	EnrollmentID *uint  `json:"enrollment_id,omitempty"`
+	MembershipID *uint  `json:"membership_id,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// MembershipPlan is a commercial plan such as "2 clases por semana" or "Ilimitado".
+// WeeklyQuota 0 means unlimited; CategoryQuotas add per-category limits on top.
+type MembershipPlan struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string    `gorm:"size:255;not null;uniqueIndex" json:"name"`
+	Description  string    `gorm:"type:text" json:"description"`
+	WeeklyQuota  int       `gorm:"not null;default:0" json:"weekly_quota"`
+	DurationDays int       `gorm:"not null;default:30" json:"duration_days"`
+	PriceCents   int       `gorm:"not null;default:0" json:"price_cents"`
+	IsActive     bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	CategoryQuotas []PlanCategoryQuota `gorm:"foreignKey:PlanID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"category_quotas"`
+}
+
+// PlanCategoryQuota limits how many weekly classes of one category a plan allows.
+type PlanCategoryQuota struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	PlanID      uint   `gorm:"not null;uniqueIndex:idx_plan_category" json:"-"`
+	Category    string `gorm:"size:100;not null;uniqueIndex:idx_plan_category" json:"category"`
+	WeeklyQuota int    `gorm:"not null" json:"weekly_quota"`
+}
+
+// Membership assigns a plan to a user for a validity window.
+type Membership struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	PlanID    uint      `gorm:"not null;index" json:"plan_id"`
+	StartsAt  time.Time `gorm:"not null" json:"starts_at"`
+	EndsAt    time.Time `gorm:"not null" json:"ends_at"`
+	Status    string    `gorm:"size:20;not null;default:'activa'" json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	User User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	Plan MembershipPlan `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"plan"`
+}
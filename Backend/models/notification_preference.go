@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// NotificationPreference stores how a user wants to be contacted. Users without a row get the
+// defaults: emails and reminders enabled, with the server-wide reminder lead time.
+type NotificationPreference struct {
+	ID               uint `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID           uint `gorm:"not null;uniqueIndex" json:"user_id"`
+	EmailEnabled     bool `gorm:"not null;default:true" json:"email_enabled"`
+	RemindersEnabled bool `gorm:"not null;default:true" json:"reminders_enabled"`
+	// ReminderLeadHours overrides REMINDER_LEAD_HOURS when set.
+	ReminderLeadHours *int      `json:"reminder_lead_hours"`
+	UpdatedAt         time.Time `json:"updated_at"`
+
+	User User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// ReminderLog records that a reminder was queued for one session of an enrollment. The unique
+// index makes the scheduler idempotent across restarts and instances.
+type ReminderLog struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement"`
+	EnrollmentID uint      `gorm:"not null;uniqueIndex:idx_reminder_session"`
+	SessionStart time.Time `gorm:"not null;uniqueIndex:idx_reminder_session"`
+	CreatedAt    time.Time
+}
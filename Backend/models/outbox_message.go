@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// OutboxMessage is a notification waiting to be delivered through one channel.
+// Rows are written in the transaction of the domain change and delivered by a background worker.
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventType     string     `gorm:"size:50;not null" json:"event_type"`
+	Channel       string     `gorm:"size:20;not null" json:"channel"`
+	Recipient     string     `gorm:"size:512;not null" json:"recipient"`
+	Subject       string     `gorm:"size:255;not null" json:"subject"`
+	Body          string     `gorm:"type:text;not null" json:"body"`
+	Status        string     `gorm:"size:20;not null;default:'pendiente';index:idx_outbox_pending" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_pending" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
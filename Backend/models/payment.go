@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Payment tracks a checkout at the payment provider for a paid enrollment or a membership.
+// Purpose is "inscripcion" (EnrollmentID set) or "membresia" (PlanID set, MembershipID once paid).
+type Payment struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Purpose      string     `gorm:"size:20;not null" json:"purpose"`
+	EnrollmentID *uint      `gorm:"index" json:"enrollment_id,omitempty"`
+	PlanID       *uint      `gorm:"index" json:"plan_id,omitempty"`
+	MembershipID *uint      `json:"membership_id,omitempty"`
+	Description  string     `gorm:"size:255;not null" json:"description"`
+	AmountCents  int        `gorm:"not null" json:"amount_cents"`
+	Currency     string     `gorm:"size:3;not null" json:"currency"`
+	Provider     string     `gorm:"size:30;not null" json:"provider"`
+	ProviderRef  string     `gorm:"size:100;not null;uniqueIndex" json:"provider_ref"`
+	CheckoutURL  string     `gorm:"size:512" json:"checkout_url"`
+	Status       string     `gorm:"size:20;not null;default:'pendiente'" json:"status"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	PaidAt       *time.Time `json:"paid_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	User User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+}
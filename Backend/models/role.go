@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Role groups permissions; users reference it by name through User.Role.
+type Role struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"size:20;not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	IsSystem    bool      `gorm:"not null;default:false" json:"is_system"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Permissions []RolePermission `gorm:"foreignKey:RoleID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// RolePermission is one permission granted to a role.
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_permission" json:"role_id"`
+	Permission string `gorm:"size:64;not null;uniqueIndex:idx_role_permission" json:"permission"`
+}
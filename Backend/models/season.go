@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Season is a term of the timetable: its activities are only held, and their enrollments only
+// last, from StartsOn to EndsOn. Seasons do not overlap, so at most one is in progress. Dates are
+// YYYY-MM-DD in the gym's time zone.
+type Season struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name     string `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	StartsOn string `gorm:"size:10;not null;index" json:"starts_on"`
+	EndsOn   string `gorm:"size:10;not null;index" json:"ends_on"`
+	// EnrollmentOpensOn is the first day anyone can enroll; empty means as soon as an activity is
+	// published.
+	EnrollmentOpensOn string `gorm:"size:10" json:"enrollment_opens_on"`
+	// PriorityOpensOn starts the re-enrollment window before EnrollmentOpensOn, in which only the
+	// members of an activity can enroll in the one that continues it; empty means no window.
+	PriorityOpensOn string `gorm:"size:10" json:"priority_opens_on"`
+	// ClosedAt is set once the enrollments of the ended season were finished.
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
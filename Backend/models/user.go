@@ -2,15 +2,29 @@ package models
 
 import "time"
 
+// Member levels, from the lowest to the highest.
+const (
+	LevelBeginner     = "inicial"
+	LevelIntermediate = "intermedio"
+	LevelAdvanced     = "avanzado"
+)
+
+// MemberLevels lists the member levels in ascending order.
+var MemberLevels = []string{LevelBeginner, LevelIntermediate, LevelAdvanced}
+
 // User represents gym members and admins interacting with the system.
 type User struct {
-	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name         string    `gorm:"size:255;not null" json:"name"`
-	Email        string    `gorm:"size:255;uniqueIndex;not null" json:"email"`
-	PasswordHash string    `gorm:"size:255;not null" json:"-"`
-	Role         string    `gorm:"size:20;not null" json:"role"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string `gorm:"size:255;not null" json:"name"`
+	Email        string `gorm:"size:255;uniqueIndex;not null" json:"email"`
+	PasswordHash string `gorm:"size:255;not null" json:"-"`
+	Role         string `gorm:"size:20;not null" json:"role"`
+	// BirthDate (YYYY-MM-DD) and Level are kept by the staff and checked by eligibility rules;
+	// empty when unknown.
+	BirthDate string    `gorm:"size:10" json:"birth_date,omitempty"`
+	Level     string    `gorm:"size:20" json:"level,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	Enrollments []Enrollment `gorm:"foreignKey:UserID" json:"-"`
 }
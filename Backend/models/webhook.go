@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// WebhookSubscription is an external endpoint that receives signed domain events.
+type WebhookSubscription struct {
+	ID   uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name string `gorm:"size:255;not null" json:"name"`
+	URL  string `gorm:"size:512;not null" json:"url"`
+	// Secret signs every delivery; it is shown once on creation and never serialized.
+	Secret string `gorm:"size:128;not null" json:"-"`
+	// EventTypes is a space separated list of the event types the endpoint wants.
+	EventTypes  string    `gorm:"size:512;not null" json:"-"`
+	IsActive    bool      `gorm:"not null;default:true" json:"is_active"`
+	CreatedByID uint      `gorm:"not null;index" json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	CreatedBy User `gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+}
+
+// WebhookDelivery is one attempt log entry: an event payload to POST to a subscription.
+// Rows are written in the transaction of the domain change and sent by a background worker.
+type WebhookDelivery struct {
+	ID             uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubscriptionID uint   `gorm:"not null;index" json:"subscription_id"`
+	EventType      string `gorm:"size:50;not null" json:"event_type"`
+	Payload        string `gorm:"type:text;not null" json:"payload"`
+	// ReplayOfID points to the delivery this one re-sends.
+	ReplayOfID     *uint      `json:"replay_of_id,omitempty"`
+	Status         string     `gorm:"size:20;not null;default:'pendiente';index:idx_webhook_pending" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_pending" json:"next_attempt_at"`
+	ResponseStatus int        `gorm:"not null;default:0" json:"response_status"`
+	LastError      string     `gorm:"type:text" json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Subscription WebhookSubscription `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
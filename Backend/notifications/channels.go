@@ -0,0 +1,122 @@
+// Package notifications turns domain events into outbox messages and delivers them through
+// pluggable channels (SMTP, log, webhook) from a background worker.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/webhooks"
+)
+
+// Channel names, as used in NOTIFICATION_CHANNELS and outbox rows.
+const (
+	ChannelSMTP    = "smtp"
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+)
+
+// Channel delivers one outbox message. Returning an error schedules a retry.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg models.OutboxMessage) error
+}
+
+// LogChannel writes messages to the application log; useful in development.
+type LogChannel struct{}
+
+func NewLogChannel() *LogChannel {
+	return &LogChannel{}
+}
+
+func (c *LogChannel) Name() string {
+	return ChannelLog
+}
+
+func (c *LogChannel) Send(_ context.Context, msg models.OutboxMessage) error {
+	log.Printf("notification %s to %s: %s\n%s", msg.EventType, msg.Recipient, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTPChannel sends plain text emails, authenticating with PLAIN when a user is configured.
+type SMTPChannel struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPChannel(host, port, user, password, from string) *SMTPChannel {
+	channel := &SMTPChannel{addr: net.JoinHostPort(host, port), from: from}
+	if user != "" {
+		channel.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return channel
+}
+
+func (c *SMTPChannel) Name() string {
+	return ChannelSMTP
+}
+
+func (c *SMTPChannel) Send(_ context.Context, msg models.OutboxMessage) error {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", c.from)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.Recipient)
+	fmt.Fprintf(&body, "Subject: %s\r\n", encodeHeader(msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	body.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return smtp.SendMail(c.addr, c.auth, c.from, []string{msg.Recipient}, body.Bytes())
+}
+
+// WebhookChannel POSTs the JSON body of a message to its recipient URL, with the same headers
+// and signature as the webhook subscriptions (see webhooks.Sign) using a shared secret.
+type WebhookChannel struct {
+	secret string
+	client *http.Client
+}
+
+func NewWebhookChannel(secret string) *WebhookChannel {
+	return &WebhookChannel{secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *WebhookChannel) Name() string {
+	return ChannelWebhook
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, msg models.OutboxMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Recipient, strings.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.EventHeader, msg.EventType)
+	req.Header.Set(webhooks.DeliveryHeader, strconv.FormatUint(uint64(msg.ID), 10))
+	req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(c.secret, time.Now(), []byte(msg.Body)))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// encodeHeader applies RFC 2047 encoding when the subject has non-ASCII characters.
+func encodeHeader(value string) string {
+	return mime.BEncoding.Encode("UTF-8", value)
+}
@@ -0,0 +1,43 @@
+package notifications
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/webhooks"
+)
+
+func TestWebhookChannelSignsLikeSubscriptions(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	msg := models.OutboxMessage{ID: 7, EventType: "enrollment.confirmed", Recipient: server.URL, Body: `{"ok":true}`}
+	if err := NewWebhookChannel("s3cret").Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	signature := header.Get(webhooks.SignatureHeader)
+	unix, _, _ := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+	sentAt, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		t.Fatalf("signature %q has no timestamp", signature)
+	}
+	if want := webhooks.Sign("s3cret", time.Unix(sentAt, 0), body); signature != want {
+		t.Fatalf("signature = %q, want %q", signature, want)
+	}
+	if header.Get(webhooks.EventHeader) != msg.EventType || header.Get(webhooks.DeliveryHeader) != "7" {
+		t.Fatalf("headers = %v", header)
+	}
+}
@@ -0,0 +1,145 @@
+package notifications
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// Outbox is an events.Recorder that writes one outbox row per recipient and channel.
+// Member channels (smtp, log) get a rendered email per affected member; the webhook channel
+// gets a single JSON document per event.
+type Outbox struct {
+	channels   []string
+	webhookURL string
+}
+
+// NewOutbox records messages for the given channel names. webhookURL is required for the webhook channel.
+func NewOutbox(channels []string, webhookURL string) *Outbox {
+	return &Outbox{channels: channels, webhookURL: webhookURL}
+}
+
+// recipient is a member affected by an event.
+type recipient struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	// ScheduleConflict reports whether the member's enrollment now overlaps another class.
+	ScheduleConflict bool `json:"schedule_conflict"`
+}
+
+func (o *Outbox) Record(tx *gorm.DB, evt events.Event) error {
+	var activity models.Activity
+	if evt.ActivityID != 0 {
+		if err := tx.First(&activity, evt.ActivityID).Error; err != nil {
+			return err
+		}
+	}
+
+	recipients, err := recipientsFor(tx, evt)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	optedOut, err := emailOptOuts(tx, recipients)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var messages []models.OutboxMessage
+	for _, channel := range o.channels {
+		if channel == ChannelWebhook {
+			if o.webhookURL == "" {
+				continue
+			}
+			body, err := json.Marshal(map[string]interface{}{
+				"event":      evt,
+				"activity":   activity,
+				"recipients": recipients,
+			})
+			if err != nil {
+				return err
+			}
+			messages = append(messages, models.OutboxMessage{
+				EventType:     evt.Type,
+				Channel:       channel,
+				Recipient:     o.webhookURL,
+				Subject:       evt.Type,
+				Body:          string(body),
+				NextAttemptAt: now,
+			})
+			continue
+		}
+
+		for _, member := range recipients {
+			if optedOut[member.UserID] {
+				continue
+			}
+			subject, body, ok := render(evt, member, &activity)
+			if !ok {
+				continue
+			}
+			messages = append(messages, models.OutboxMessage{
+				EventType:     evt.Type,
+				Channel:       channel,
+				Recipient:     member.Email,
+				Subject:       subject,
+				Body:          body,
+				NextAttemptAt: now,
+			})
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return tx.Create(&messages).Error
+}
+
+// recipientsFor resolves the members an event concerns: the enrolled user, or everyone holding
+// a seat when the whole activity is affected.
+func recipientsFor(tx *gorm.DB, evt events.Event) ([]recipient, error) {
+	var recipients []recipient
+	switch evt.Type {
+	case events.EnrollmentConfirmed, events.EnrollmentCancelled, events.EnrollmentWaitlisted, events.EnrollmentPromoted, events.EnrollmentFinished, events.ClassReminder:
+		err := tx.Model(&models.User{}).
+			Select("id AS user_id, name, email").
+			Where("id = ?", evt.UserID).
+			Scan(&recipients).Error
+		return recipients, err
+	case events.ActivityDeactivated, events.ActivityRescheduled, events.ActivityPaused, events.ActivityPublished,
+		events.ActivitySessionCancelled, events.ActivitySessionRestored:
+		err := tx.Model(&models.Enrollment{}).
+			Select("users.id AS user_id, users.name, users.email, enrollments.schedule_conflict").
+			Joins("JOIN users ON users.id = enrollments.user_id").
+			Where("enrollments.activity_id = ? AND enrollments.status IN ?", evt.ActivityID, []string{"inscripto", "pendiente_pago"}).
+			Scan(&recipients).Error
+		return recipients, err
+	default:
+		return nil, nil
+	}
+}
+
+// emailOptOuts returns the recipients who disabled member emails in their preferences.
+func emailOptOuts(tx *gorm.DB, recipients []recipient) (map[uint]bool, error) {
+	userIDs := make([]uint, 0, len(recipients))
+	for _, member := range recipients {
+		userIDs = append(userIDs, member.UserID)
+	}
+	var optedOut []uint
+	if err := tx.Model(&models.NotificationPreference{}).
+		Where("user_id IN ? AND email_enabled = ?", userIDs, false).
+		Pluck("user_id", &optedOut).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]bool, len(optedOut))
+	for _, id := range optedOut {
+		result[id] = true
+	}
+	return result, nil
+}
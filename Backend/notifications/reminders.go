@@ -0,0 +1,258 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Clock abstracts the current time so the scheduler can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ReminderScheduler records a class.reminder event when an enrolled session is within the
+// member's lead time. Reminders then flow through the outbox like any other notification.
+// Class times, season bounds and cancelled dates are read in location, the gym's time zone.
+type ReminderScheduler struct {
+	db          *gorm.DB
+	bus         *events.Bus
+	clock       Clock
+	location    *time.Location
+	defaultLead time.Duration
+	interval    time.Duration
+}
+
+func NewReminderScheduler(db *gorm.DB, bus *events.Bus, clock Clock, location *time.Location, defaultLead, interval time.Duration) *ReminderScheduler {
+	return &ReminderScheduler{db: db, bus: bus, clock: clock, location: location, defaultLead: defaultLead, interval: interval}
+}
+
+// Run checks for due reminders every interval until ctx is cancelled.
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(); err != nil {
+			log.Printf("reminders: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce queues the reminders due at the clock's current time and returns how many it queued.
+// Only enrollments in activities starting within the longest lead time are loaded.
+func (s *ReminderScheduler) RunOnce() (int, error) {
+	now := s.clock.Now().In(s.location)
+	longest, err := s.longestLead()
+	if err != nil {
+		return 0, err
+	}
+
+	var enrollments []models.Enrollment
+	if err := s.db.Joins("Activity").
+		Where("enrollments.status = ? AND Activity.status = ?", "inscripto", models.ActivityPublished).
+		Where(startingWithin(s.db, now, now.Add(longest).In(s.location))).
+		Find(&enrollments).Error; err != nil {
+		return 0, err
+	}
+	if len(enrollments) == 0 {
+		return 0, nil
+	}
+
+	userIDs := make([]uint, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		userIDs = append(userIDs, enrollment.UserID)
+	}
+	var prefs []models.NotificationPreference
+	if err := s.db.Where("user_id IN ?", userIDs).Find(&prefs).Error; err != nil {
+		return 0, err
+	}
+	prefsByUser := make(map[uint]models.NotificationPreference, len(prefs))
+	for _, pref := range prefs {
+		prefsByUser[pref.UserID] = pref
+	}
+	seasons, err := s.seasons(enrollments)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, enrollment := range enrollments {
+		lead := s.defaultLead
+		if pref, ok := prefsByUser[enrollment.UserID]; ok {
+			if !pref.RemindersEnabled {
+				continue
+			}
+			if pref.ReminderLeadHours != nil {
+				lead = time.Duration(*pref.ReminderLeadHours) * time.Hour
+			}
+		}
+
+		session, err := NextOccurrence(now, enrollment.Activity.DayOfWeek, enrollment.Activity.StartTime)
+		if err != nil {
+			log.Printf("reminders: activity %d: %v", enrollment.ActivityID, err)
+			continue
+		}
+		if session.Sub(now) > lead {
+			continue
+		}
+		if id := enrollment.Activity.SeasonID; id != nil {
+			// No class is held outside the season of the activity.
+			date := session.Format("2006-01-02")
+			if season, ok := seasons[*id]; ok && (date < season.StartsOn || date > season.EndsOn) {
+				continue
+			}
+		}
+		cancelled, err := s.sessionCancelled(enrollment.ActivityID, session)
+		if err != nil {
+			return queued, err
+		}
+		if cancelled {
+			continue
+		}
+
+		sent, err := s.queue(&enrollment, session)
+		if err != nil {
+			return queued, err
+		}
+		if sent {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// longestLead is the longest lead time any member may have: the default or the longest one chosen
+// by a member who gets reminders.
+func (s *ReminderScheduler) longestLead() (time.Duration, error) {
+	var hours int
+	if err := s.db.Model(&models.NotificationPreference{}).
+		Where("reminders_enabled = ?", true).
+		Select("COALESCE(MAX(reminder_lead_hours), 0)").
+		Scan(&hours).Error; err != nil {
+		return 0, err
+	}
+	if lead := time.Duration(hours) * time.Hour; lead > s.defaultLead {
+		return lead, nil
+	}
+	return s.defaultLead, nil
+}
+
+// startingWithin is the condition on the joined Activity for its weekly session to start between
+// from and to, both in the gym's time zone. A week or more matches every activity.
+func startingWithin(db *gorm.DB, from, to time.Time) *gorm.DB {
+	condition := db.Session(&gorm.Session{NewDB: true})
+	if to.Sub(from) >= 7*24*time.Hour {
+		return condition
+	}
+	for day := from; ; day = day.AddDate(0, 0, 1) {
+		first, last := "00:00", "23:59"
+		if sameDate(day, from) {
+			first = from.Format("15:04")
+		}
+		final := sameDate(day, to)
+		if final {
+			last = to.Format("15:04")
+		}
+		condition = condition.Or("Activity.day_of_week = ? AND Activity.start_time BETWEEN ? AND ?", int(day.Weekday()), first, last)
+		if final {
+			return condition
+		}
+	}
+}
+
+func sameDate(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// queue records the reminder unless this session was already handled.
+func (s *ReminderScheduler) queue(enrollment *models.Enrollment, session time.Time) (bool, error) {
+	sent := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		entry := models.ReminderLog{EnrollmentID: enrollment.ID, SessionStart: session}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		evt := events.New(events.ClassReminder)
+		evt.OccurredAt = s.clock.Now()
+		evt.UserID = enrollment.UserID
+		evt.ActivityID = enrollment.ActivityID
+		evt.EnrollmentID = enrollment.ID
+		evt.Data = map[string]interface{}{"session_start": session}
+		sent = true
+		return s.bus.Record(tx, evt)
+	})
+	return sent, err
+}
+
+// seasons loads the seasons the activities of enrollments are held in, by id.
+func (s *ReminderScheduler) seasons(enrollments []models.Enrollment) (map[uint]models.Season, error) {
+	var ids []uint
+	for _, enrollment := range enrollments {
+		if enrollment.Activity.SeasonID != nil {
+			ids = append(ids, *enrollment.Activity.SeasonID)
+		}
+	}
+	seasons := map[uint]models.Season{}
+	if len(ids) == 0 {
+		return seasons, nil
+	}
+	var found []models.Season
+	if err := s.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, season := range found {
+		seasons[season.ID] = season
+	}
+	return seasons, nil
+}
+
+// sessionCancelled reports whether an admin cancelled the activity on the day of session.
+func (s *ReminderScheduler) sessionCancelled(activityID uint, session time.Time) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.ActivityCancellation{}).
+		Where("activity_id = ? AND date = ?", activityID, session.Format("2006-01-02")).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// NextOccurrence returns the first start of a weekly session (day 0 = Sunday, start "HH:MM")
+// at or after now, in now's location, which must be the zone the class times are expressed in.
+func NextOccurrence(now time.Time, dayOfWeek int, start string) (time.Time, error) {
+	clock, err := time.Parse("15:04", start)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time %q", start)
+	}
+	if dayOfWeek < 0 || dayOfWeek > 6 {
+		return time.Time{}, fmt.Errorf("invalid day of week %d", dayOfWeek)
+	}
+
+	days := (dayOfWeek - int(now.Weekday()) + 7) % 7
+	session := time.Date(now.Year(), now.Month(), now.Day()+days, clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if session.Before(now) {
+		session = session.AddDate(0, 0, 7)
+	}
+	return session, nil
+}
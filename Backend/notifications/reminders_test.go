@@ -0,0 +1,186 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// eventLog records the events passed to the bus.
+type eventLog struct {
+	events []events.Event
+}
+
+func (l *eventLog) Record(_ *gorm.DB, evt events.Event) error {
+	l.events = append(l.events, evt)
+	return nil
+}
+
+func TestReminderSchedulerRunOnce(t *testing.T) {
+	gym, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	db := dbtest.Open(t, &models.User{}, &models.Season{}, &models.Activity{}, &models.Enrollment{},
+		&models.NotificationPreference{}, &models.ReminderLog{}, &models.ActivityCancellation{})
+
+	// Monday 2024-05-13 09:00 at the gym, 12:00 UTC.
+	now := time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC)
+
+	morning := createActivity(t, db, "Funcional", "10:00")
+	evening := createActivity(t, db, "Yoga", "14:00")
+	cancelled := createActivity(t, db, "Spinning", "10:30")
+	if err := db.Create(&models.ActivityCancellation{ActivityID: cancelled.ID, Date: "2024-05-13", CreatedByID: 1}).Error; err != nil {
+		t.Fatalf("cancel session: %v", err)
+	}
+
+	defaults := createUser(t, db, "defaults@example.com")
+	optedOut := createUser(t, db, "optout@example.com")
+	longLead := createUser(t, db, "longlead@example.com")
+	if err := db.Create(&models.NotificationPreference{UserID: optedOut.ID}).Error; err != nil {
+		t.Fatalf("create preference: %v", err)
+	}
+	if err := db.Model(&models.NotificationPreference{}).Where("user_id = ?", optedOut.ID).
+		Update("reminders_enabled", false).Error; err != nil {
+		t.Fatalf("opt out: %v", err)
+	}
+	sixHours := 6
+	if err := db.Create(&models.NotificationPreference{UserID: longLead.ID, RemindersEnabled: true, ReminderLeadHours: &sixHours}).Error; err != nil {
+		t.Fatalf("create preference: %v", err)
+	}
+
+	due := []*models.Enrollment{
+		// The class starts in an hour, within the default lead time.
+		enroll(t, db, defaults, morning),
+		// Five hours ahead is only within the lead time chosen by the member.
+		enroll(t, db, longLead, evening),
+	}
+	// Outside the default lead time, opted out and cancelled for today.
+	enroll(t, db, defaults, evening)
+	enroll(t, db, optedOut, morning)
+	enroll(t, db, defaults, cancelled)
+
+	recorded := &eventLog{}
+	bus := events.NewBus()
+	bus.Subscribe(recorded)
+	scheduler := NewReminderScheduler(db, bus, fixedClock{now: now}, gym, 2*time.Hour, time.Minute)
+
+	queued, err := scheduler.RunOnce()
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if queued != len(due) || len(recorded.events) != len(due) {
+		t.Fatalf("queued %d reminders with %d events, want %d", queued, len(recorded.events), len(due))
+	}
+	for i, enrollment := range due {
+		evt := recorded.events[i]
+		if evt.Type != events.ClassReminder || evt.EnrollmentID != enrollment.ID || evt.UserID != enrollment.UserID {
+			t.Fatalf("event %d = %+v, want a reminder for enrollment %d", i, evt, enrollment.ID)
+		}
+	}
+	wantStart := time.Date(2024, 5, 13, 10, 0, 0, 0, gym)
+	if start, _ := recorded.events[0].Data["session_start"].(time.Time); !start.Equal(wantStart) {
+		t.Fatalf("session_start = %v, want %v", start, wantStart)
+	}
+
+	queued, err = scheduler.RunOnce()
+	if err != nil {
+		t.Fatalf("second RunOnce: %v", err)
+	}
+	if queued != 0 || len(recorded.events) != len(due) {
+		t.Fatalf("second run queued %d reminders, want none", queued)
+	}
+}
+
+func TestStartingWithinSpansMidnight(t *testing.T) {
+	gym, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	db := dbtest.Open(t, &models.User{}, &models.Activity{}, &models.Enrollment{})
+	member := createUser(t, db, "socio@example.com")
+	for _, session := range []struct {
+		title string
+		day   time.Weekday
+		start string
+	}{
+		{"before the window", time.Saturday, "21:00"},
+		{"saturday night", time.Saturday, "22:30"},
+		{"sunday early", time.Sunday, "01:00"},
+		{"after the window", time.Sunday, "03:00"},
+		{"another day", time.Monday, "01:00"},
+	} {
+		activity := createActivity(t, db, session.title, session.start)
+		if err := db.Model(activity).Update("day_of_week", int(session.day)).Error; err != nil {
+			t.Fatalf("move activity: %v", err)
+		}
+		enroll(t, db, member, activity)
+	}
+
+	// Saturday 2024-05-18 22:00 to Sunday 02:00 at the gym.
+	from := time.Date(2024, 5, 18, 22, 0, 0, 0, gym)
+	var enrollments []models.Enrollment
+	if err := db.Joins("Activity").Where(startingWithin(db, from, from.Add(4*time.Hour))).
+		Order("enrollments.id").Find(&enrollments).Error; err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	var titles []string
+	for _, enrollment := range enrollments {
+		titles = append(titles, enrollment.Activity.Title)
+	}
+	if len(titles) != 2 || titles[0] != "saturday night" || titles[1] != "sunday early" {
+		t.Fatalf("sessions in the window = %v, want [saturday night sunday early]", titles)
+	}
+
+	var all int64
+	if err := db.Model(&models.Enrollment{}).Joins("Activity").
+		Where(startingWithin(db, from, from.Add(7*24*time.Hour))).Count(&all).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if all != 5 {
+		t.Fatalf("a week-long window matched %d sessions, want 5", all)
+	}
+}
+
+func createActivity(t *testing.T, db *gorm.DB, title, start string) *models.Activity {
+	t.Helper()
+	end, _ := time.Parse("15:04", start)
+	activity := models.Activity{Title: title, Category: "general", DayOfWeek: int(time.Monday), StartTime: start,
+		EndTime: end.Add(time.Hour).Format("15:04"), Capacity: 10, Instructor: "Profe"}
+	activity.SetStatus(models.ActivityPublished)
+	if err := db.Create(&activity).Error; err != nil {
+		t.Fatalf("create activity: %v", err)
+	}
+	return &activity
+}
+
+func createUser(t *testing.T, db *gorm.DB, email string) *models.User {
+	t.Helper()
+	user := models.User{Name: email, Email: email, PasswordHash: "x", Role: "socio"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+func enroll(t *testing.T, db *gorm.DB, user *models.User, activity *models.Activity) *models.Enrollment {
+	t.Helper()
+	enrollment := models.Enrollment{UserID: user.ID, ActivityID: activity.ID, Status: "inscripto"}
+	if err := db.Create(&enrollment).Error; err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	return &enrollment
+}
@@ -0,0 +1,136 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+var dayNames = []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}
+
+// render builds the Spanish email for a member. ok is false for events without a template.
+func render(evt events.Event, member recipient, activity *models.Activity) (subject, body string, ok bool) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Hola %s,\n\n", member.Name)
+
+	switch evt.Type {
+	case events.EnrollmentConfirmed:
+		subject = "Inscripción confirmada: " + activity.Title
+		fmt.Fprintf(&text, "Tu inscripción a %s quedó confirmada.\n\n", activity.Title)
+		writeSchedule(&text, activity)
+		text.WriteString("\nSi no podés asistir, date de baja desde \"Mis actividades\" para liberar el lugar.\n")
+	case events.EnrollmentCancelled:
+		subject = "Baja registrada: " + activity.Title
+		fmt.Fprintf(&text, "Registramos tu baja de %s.\n\n", activity.Title)
+		writeSchedule(&text, activity)
+	case events.EnrollmentWaitlisted:
+		subject = "Pasaste a lista de espera: " + activity.Title
+		fmt.Fprintf(&text, "Se redujo el cupo de %s y tu inscripción pasó a la lista de espera.\n\n", activity.Title)
+		writeSchedule(&text, activity)
+		text.WriteString("\nSi se libera un lugar te inscribimos automáticamente y te avisamos. Si ya no te interesa, date de baja desde \"Mis actividades\".\n")
+	case events.EnrollmentPromoted:
+		subject = "Tenés lugar en " + activity.Title
+		if status, _ := evt.Data["status"].(string); status == "pendiente_pago" {
+			minutes, _ := evt.Data["hold_minutes"].(int)
+			fmt.Fprintf(&text, "Se liberó un lugar en %s y te lo reservamos durante %d minutos desde la lista de espera. Para confirmarlo, inscribite de nuevo en la actividad y completá el pago.\n\n", activity.Title, minutes)
+		} else {
+			fmt.Fprintf(&text, "Se liberó un lugar en %s y tu inscripción quedó confirmada desde la lista de espera.\n\n", activity.Title)
+		}
+		writeSchedule(&text, activity)
+		text.WriteString("\nSi no podés asistir, date de baja desde \"Mis actividades\" para liberar el lugar.\n")
+	case events.EnrollmentFinished:
+		season, _ := evt.Data["season"].(string)
+		subject = "Terminó la temporada: " + activity.Title
+		if status, _ := evt.Data["status"].(string); status == "finalizado" {
+			fmt.Fprintf(&text, "Con el fin de la temporada %s terminó tu inscripción a %s. ¡Gracias por participar!\n", season, activity.Title)
+		} else {
+			fmt.Fprintf(&text, "Con el fin de la temporada %s se cancelaron tu reserva o tu lugar en la lista de espera de %s.\n", season, activity.Title)
+		}
+		if next, _ := evt.Data["next_activity_id"].(uint); next != 0 {
+			text.WriteString("\nLa actividad sigue la próxima temporada y, como miembro, tenés prioridad para reinscribirte desde \"Temporadas\".\n")
+		}
+	case events.ClassReminder:
+		subject = "Recordatorio: " + activity.Title
+		if session, ok := evt.Data["session_start"].(time.Time); ok {
+			fmt.Fprintf(&text, "Te recordamos que tenés %s el %s %s a las %s.\n\n",
+				activity.Title, dayNames[session.Weekday()], session.Format("02/01"), session.Format("15:04"))
+		} else {
+			fmt.Fprintf(&text, "Te recordamos que tenés %s.\n\n", activity.Title)
+		}
+		writeSchedule(&text, activity)
+		text.WriteString("\nSi no vas a poder asistir, date de baja para liberar el lugar.\n")
+	case events.ActivityDeactivated:
+		subject = "Clase suspendida: " + activity.Title
+		fmt.Fprintf(&text, "Te avisamos que la actividad %s fue dada de baja y no se dictará hasta nuevo aviso.\n\n", activity.Title)
+		writeSchedule(&text, activity)
+		if cancelled, _ := evt.Data["enrollments_cancelled"].(bool); cancelled {
+			text.WriteString("\nTu inscripción fue cancelada y el lugar liberado. Si la habías pagado, te contactaremos para el reintegro.\n")
+		}
+		text.WriteString("\nPodés elegir otra actividad desde el listado de clases.\n")
+	case events.ActivityPaused:
+		subject = "Clases en pausa: " + activity.Title
+		fmt.Fprintf(&text, "Te avisamos que la actividad %s queda en pausa y no se dictará hasta nuevo aviso.\n\n", activity.Title)
+		writeSchedule(&text, activity)
+		text.WriteString("\nTu lugar se mantiene: te avisaremos cuando vuelva a dictarse. Si ya no te interesa, date de baja desde \"Mis actividades\".\n")
+	case events.ActivityPublished:
+		subject = "Vuelven las clases: " + activity.Title
+		fmt.Fprintf(&text, "La actividad %s vuelve a dictarse y mantenés tu lugar.\n\n", activity.Title)
+		writeSchedule(&text, activity)
+	case events.ActivitySessionCancelled:
+		subject = "Clase cancelada: " + activity.Title
+		fmt.Fprintf(&text, "La clase de %s del %s no se dictará.\n", activity.Title, sessionText(evt))
+		if reason, _ := evt.Data["reason"].(string); reason != "" {
+			fmt.Fprintf(&text, "Motivo: %s\n", reason)
+		}
+		text.WriteString("\nTu inscripción sigue vigente para las demás clases.\n")
+	case events.ActivitySessionRestored:
+		subject = "Se dicta la clase: " + activity.Title
+		fmt.Fprintf(&text, "La clase de %s del %s, que estaba cancelada, se dictará normalmente.\n\n", activity.Title, sessionText(evt))
+		writeSchedule(&text, activity)
+	case events.ActivityRescheduled:
+		subject = "Cambio de horario: " + activity.Title
+		fmt.Fprintf(&text, "La actividad %s cambió de horario.\n\n", activity.Title)
+		if day, ok := evt.Data["previous_day_of_week"].(int); ok {
+			previousStart, _ := evt.Data["previous_start_time"].(string)
+			previousEnd, _ := evt.Data["previous_end_time"].(string)
+			fmt.Fprintf(&text, "Horario anterior: %s\n", scheduleText(day, previousStart, previousEnd))
+		}
+		fmt.Fprintf(&text, "Nuevo horario: %s\n", scheduleText(activity.DayOfWeek, activity.StartTime, activity.EndTime))
+		if member.ScheduleConflict {
+			text.WriteString("\nAtención: con el nuevo horario esta clase se superpone con otra de tus inscripciones. Revisá \"Mis actividades\" y date de baja de la que no vayas a usar.\n")
+		}
+	default:
+		return "", "", false
+	}
+
+	text.WriteString("\nSaludos,\nEl equipo del gimnasio\n")
+	return subject, text.String(), true
+}
+
+func writeSchedule(text *strings.Builder, activity *models.Activity) {
+	fmt.Fprintf(text, "Horario: %s\n", scheduleText(activity.DayOfWeek, activity.StartTime, activity.EndTime))
+	if activity.Instructor != "" {
+		fmt.Fprintf(text, "Profesor/a: %s\n", activity.Instructor)
+	}
+}
+
+// sessionText renders the session date of evt, e.g. "lunes 13/05".
+func sessionText(evt events.Event) string {
+	date, _ := evt.Data["date"].(string)
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return dayNames[day.Weekday()] + " " + day.Format("02/01")
+}
+
+func scheduleText(dayOfWeek int, start, end string) string {
+	day := ""
+	if dayOfWeek >= 0 && dayOfWeek < len(dayNames) {
+		day = dayNames[dayOfWeek]
+	}
+	return fmt.Sprintf("%s de %s a %s", day, start, end)
+}
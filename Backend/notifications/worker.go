@@ -0,0 +1,65 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/delivery"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// maxBackoff caps the wait between retries of a notification.
+const maxBackoff = time.Hour
+
+// Worker polls the outbox and delivers pending messages, retrying failures with exponential
+// backoff. Several instances can run at once, see delivery.Claim.
+type Worker struct {
+	db          *gorm.DB
+	channels    map[string]Channel
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+}
+
+func NewWorker(db *gorm.DB, channels []Channel, interval time.Duration, maxAttempts int) *Worker {
+	byName := make(map[string]Channel, len(channels))
+	for _, channel := range channels {
+		byName[channel.Name()] = channel
+	}
+	return &Worker{db: db, channels: byName, interval: interval, batchSize: 50, maxAttempts: maxAttempts}
+}
+
+// Run delivers messages until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	delivery.Run(ctx, "outbox", w.interval, w.DeliverPending)
+}
+
+// DeliverPending sends one batch of due messages.
+func (w *Worker) DeliverPending(ctx context.Context) error {
+	messages, err := delivery.Claim[models.OutboxMessage](w.db, w.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		w.deliver(ctx, msg)
+	}
+	return nil
+}
+
+func (w *Worker) deliver(ctx context.Context, msg models.OutboxMessage) {
+	channel, ok := w.channels[msg.Channel]
+	var sendErr error
+	if !ok {
+		sendErr = fmt.Errorf("channel %q is not enabled", msg.Channel)
+	} else {
+		sendErr = channel.Send(ctx, msg)
+	}
+
+	updates := delivery.Outcome(msg.Attempts+1, w.maxAttempts, maxBackoff, sendErr, false, "sent_at")
+	if err := w.db.Model(&models.OutboxMessage{}).Where("id = ?", msg.ID).Updates(updates).Error; err != nil {
+		log.Printf("outbox: could not update message %d: %v", msg.ID, err)
+	}
+}
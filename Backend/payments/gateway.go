@@ -0,0 +1,49 @@
+// Package payments defines the contract between the billing flow and payment providers.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+const (
+	// EventApproved and EventRejected are the normalized outcomes reported by webhooks.
+	EventApproved = "approved"
+	EventRejected = "rejected"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidEvent     = errors.New("invalid webhook event")
+)
+
+// CheckoutRequest describes what the member is paying for.
+type CheckoutRequest struct {
+	Reference   string
+	Description string
+	AmountCents int
+	Currency    string
+	PayerEmail  string
+}
+
+// Checkout is the provider-side payment the member has to complete.
+type Checkout struct {
+	ProviderRef string
+	CheckoutURL string
+}
+
+// Event is a verified webhook notification about a checkout.
+type Event struct {
+	ProviderRef string
+	Status      string
+}
+
+// PaymentGateway is implemented by each payment provider.
+type PaymentGateway interface {
+	// Name identifies the provider in webhook URLs and stored payments.
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	// ParseWebhook verifies the request signature and decodes the event.
+	ParseWebhook(header http.Header, body []byte) (*Event, error)
+}
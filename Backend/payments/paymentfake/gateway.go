@@ -0,0 +1,176 @@
+// Package paymentfake is an in-process payment provider for local development and tests.
+// Checkouts are completed by calling Complete (or POSTing to /{ref}/complete), which sends a
+// signed webhook exactly like a real provider would.
+package paymentfake
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/payments"
+)
+
+// Name is the provider name used in webhook URLs.
+const Name = "fake"
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body.
+const SignatureHeader = "X-Fake-Signature"
+
+var ErrUnknownCheckout = errors.New("unknown checkout")
+
+type webhookPayload struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+}
+
+// Gateway implements payments.PaymentGateway and serves the fake checkout pages.
+type Gateway struct {
+	// BaseURL is the absolute URL where ServeHTTP is mounted.
+	BaseURL string
+	// WebhookURL receives the signed notifications sent by Complete.
+	WebhookURL string
+
+	secret []byte
+	client *http.Client
+
+	mu        sync.Mutex
+	checkouts map[string]payments.CheckoutRequest
+}
+
+func New(baseURL, webhookURL, secret string) *Gateway {
+	return &Gateway{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		WebhookURL: webhookURL,
+		secret:     []byte(secret),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		checkouts:  map[string]payments.CheckoutRequest{},
+	}
+}
+
+func (g *Gateway) Name() string {
+	return Name
+}
+
+func (g *Gateway) CreateCheckout(_ context.Context, req payments.CheckoutRequest) (*payments.Checkout, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	ref := "fake_" + hex.EncodeToString(buf)
+
+	g.mu.Lock()
+	g.checkouts[ref] = req
+	g.mu.Unlock()
+	return &payments.Checkout{ProviderRef: ref, CheckoutURL: g.BaseURL + "/" + ref}, nil
+}
+
+func (g *Gateway) ParseWebhook(header http.Header, body []byte) (*payments.Event, error) {
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil || !hmac.Equal(signature, g.sign(body)) {
+		return nil, payments.ErrInvalidSignature
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Reference == "" {
+		return nil, payments.ErrInvalidEvent
+	}
+	if payload.Status != payments.EventApproved && payload.Status != payments.EventRejected {
+		return nil, payments.ErrInvalidEvent
+	}
+	return &payments.Event{ProviderRef: payload.Reference, Status: payload.Status}, nil
+}
+
+// Complete settles a checkout with the given status and delivers the signed webhook.
+func (g *Gateway) Complete(ctx context.Context, ref, status string) error {
+	g.mu.Lock()
+	_, ok := g.checkouts[ref]
+	delete(g.checkouts, ref)
+	g.mu.Unlock()
+	if !ok {
+		return ErrUnknownCheckout
+	}
+
+	body, err := json.Marshal(webhookPayload{Reference: ref, Status: status})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, hex.EncodeToString(g.sign(body)))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ServeHTTP exposes GET /{ref} (checkout details) and POST /{ref}/complete?status=approved|rejected.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	ref, action, _ := strings.Cut(path, "/")
+
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		g.mu.Lock()
+		checkout, ok := g.checkouts[ref]
+		g.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"reference":    ref,
+			"description":  checkout.Description,
+			"amount_cents": checkout.AmountCents,
+			"currency":     checkout.Currency,
+			"complete_url": g.BaseURL + "/" + ref + "/complete?status=approved",
+		})
+	case r.Method == http.MethodPost && action == "complete":
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = payments.EventApproved
+		}
+		if err := g.Complete(r.Context(), ref, status); err != nil {
+			if errors.Is(err, ErrUnknownCheckout) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"reference": ref, "status": status})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (g *Gateway) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
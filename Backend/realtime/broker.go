@@ -0,0 +1,99 @@
+// Package realtime pushes live seat availability to connected clients. Updates flow through a
+// Broker; the in-process MemoryBroker serves a single instance and can be replaced by one backed
+// by a shared pub/sub (Redis, NATS...) when the API runs on several nodes.
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// Availability is the seat state of an activity at a point in time.
+type Availability struct {
+	// Seq increases with every published update; clients can use it to discard stale ones.
+	Seq            uint64    `json:"seq"`
+	ActivityID     uint      `json:"activity_id"`
+	IsActive       bool      `json:"is_active"`
+	Status         string    `json:"status"`
+	Capacity       int       `json:"capacity"`
+	EnrolledCount  int       `json:"enrolled_count"`
+	AvailableSlots int       `json:"available_slots"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Broker distributes availability updates to subscribers.
+type Broker interface {
+	// Publish sends update to the subscribers interested in its activity.
+	Publish(update Availability)
+	// Subscribe returns a channel with the updates of activityIDs (all activities when empty)
+	// and a function that must be called to release the subscription.
+	Subscribe(activityIDs []uint) (<-chan Availability, func())
+}
+
+// subscriberBuffer is how many updates a slow client may lag behind before it starts losing
+// the oldest ones. Availability is a snapshot, so only the latest value matters.
+const subscriberBuffer = 16
+
+type subscriber struct {
+	ch          chan Availability
+	activityIDs map[uint]bool
+}
+
+// MemoryBroker is an in-process Broker. Publish never blocks on slow subscribers.
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	seq         uint64
+	subscribers map[*subscriber]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: map[*subscriber]struct{}{}}
+}
+
+func (b *MemoryBroker) Publish(update Availability) {
+	b.mu.Lock()
+	b.seq++
+	update.Seq = b.seq
+	b.mu.Unlock()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if len(sub.activityIDs) > 0 && !sub.activityIDs[update.ActivityID] {
+			continue
+		}
+		select {
+		case sub.ch <- update:
+		default:
+			// Drop the oldest queued update to make room for the newest one.
+			select {
+			case <-sub.ch:
+			default:
+			}
+			select {
+			case sub.ch <- update:
+			default:
+			}
+		}
+	}
+}
+
+func (b *MemoryBroker) Subscribe(activityIDs []uint) (<-chan Availability, func()) {
+	sub := &subscriber{ch: make(chan Availability, subscriberBuffer), activityIDs: map[uint]bool{}}
+	for _, id := range activityIDs {
+		sub.activityIDs[id] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, sub)
+			b.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package realtime
+
+import (
+	"log"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+// seatEvents are the events after which an activity's availability may have changed.
+var seatEvents = map[string]bool{
+	events.ActivityCreated:      true,
+	events.ActivityUpdated:      true,
+	events.ActivityDeactivated:  true,
+	events.ActivityPaused:       true,
+	events.ActivityPublished:    true,
+	events.EnrollmentConfirmed:  true,
+	events.EnrollmentCancelled:  true,
+	events.EnrollmentWaitlisted: true,
+	events.EnrollmentPromoted:   true,
+	events.EnrollmentHeld:       true,
+	events.EnrollmentReleased:   true,
+	events.EnrollmentFinished:   true,
+}
+
+// Lookup loads an activity with its availability computed.
+type Lookup func(id uint) (*models.Activity, error)
+
+// NewAvailabilityFeed returns an events.Listener that publishes the fresh availability of the
+// activity touched by each committed seat event.
+func NewAvailabilityFeed(broker Broker, lookup Lookup) events.Listener {
+	return func(evt events.Event) {
+		if !seatEvents[evt.Type] || evt.ActivityID == 0 {
+			return
+		}
+		activity, err := lookup(evt.ActivityID)
+		if err != nil {
+			log.Printf("realtime: could not load activity %d: %v", evt.ActivityID, err)
+			return
+		}
+		broker.Publish(Snapshot(activity))
+	}
+}
+
+// Snapshot builds the availability of an activity whose counters are already populated.
+func Snapshot(activity *models.Activity) Availability {
+	return Availability{
+		ActivityID:     activity.ID,
+		IsActive:       activity.IsActive,
+		Status:         activity.Status,
+		Capacity:       activity.Capacity,
+		EnrolledCount:  activity.EnrolledCount,
+		AvailableSlots: activity.AvailableSlots,
+		UpdatedAt:      time.Now(),
+	}
+}
@@ -0,0 +1,46 @@
+package search
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// Populate loads every activity into index. Run it once at startup, before serving searches.
+func Populate(index Index, db *gorm.DB) error {
+	var activities []models.Activity
+	if err := db.Find(&activities).Error; err != nil {
+		return err
+	}
+	for i := range activities {
+		if err := index.Upsert(FromActivity(&activities[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewIndexer returns an events.Listener that re-indexes the activity of every committed
+// activity.* event. Register it with Bus.AfterCommit so the index only sees committed data.
+func NewIndexer(index Index, db *gorm.DB) events.Listener {
+	return func(evt events.Event) {
+		if evt.ActivityID == 0 || !strings.HasPrefix(evt.Type, "activity.") {
+			return
+		}
+		var activity models.Activity
+		err := db.First(&activity, evt.ActivityID).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = index.Remove(evt.ActivityID)
+		case err == nil:
+			err = index.Upsert(FromActivity(&activity))
+		}
+		if err != nil {
+			log.Printf("search: could not index activity %d: %v", evt.ActivityID, err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25 parameters: k1 dampens repeated terms and b normalizes by document length.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// minPrefix is the shortest last term that also matches as a prefix ("yo" finds "yoga").
+	minPrefix = 2
+)
+
+// Field weights: a match in the title counts three times one in the description.
+const (
+	titleWeight       = 3
+	categoryWeight    = 2
+	instructorWeight  = 2
+	descriptionWeight = 1
+)
+
+type memoryEntry struct {
+	doc    Document
+	length float64
+	terms  map[string]float64
+}
+
+// MemoryIndex is an in-process inverted index ranked with BM25 over weighted fields. It lives in a
+// single process: each instance keeps its own copy, fed with Populate and NewIndexer.
+type MemoryIndex struct {
+	mu          sync.RWMutex
+	docs        map[uint]*memoryEntry
+	postings    map[string]map[uint]float64
+	totalLength float64
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[uint]*memoryEntry),
+		postings: make(map[string]map[uint]float64),
+	}
+}
+
+func (i *MemoryIndex) Upsert(doc Document) error {
+	entry := &memoryEntry{doc: doc, terms: make(map[string]float64)}
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{doc.Title, titleWeight},
+		{doc.Category, categoryWeight},
+		{doc.Instructor, instructorWeight},
+		{doc.Description, descriptionWeight},
+	} {
+		for _, token := range Tokenize(field.text) {
+			entry.terms[token] += field.weight
+			entry.length += field.weight
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(doc.ID)
+	i.docs[doc.ID] = entry
+	i.totalLength += entry.length
+	for token, tf := range entry.terms {
+		if i.postings[token] == nil {
+			i.postings[token] = make(map[uint]float64)
+		}
+		i.postings[token][doc.ID] = tf
+	}
+	return nil
+}
+
+func (i *MemoryIndex) Remove(id uint) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(id)
+	return nil
+}
+
+func (i *MemoryIndex) remove(id uint) {
+	entry, ok := i.docs[id]
+	if !ok {
+		return
+	}
+	for token := range entry.terms {
+		delete(i.postings[token], id)
+		if len(i.postings[token]) == 0 {
+			delete(i.postings, token)
+		}
+	}
+	i.totalLength -= entry.length
+	delete(i.docs, id)
+}
+
+func (i *MemoryIndex) Search(query Query) (*Result, error) {
+	terms := Tokenize(query.Text)
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var scores map[uint]float64
+	if len(terms) > 0 {
+		scores = i.score(terms)
+	}
+
+	var hits []Hit
+	for id, entry := range i.docs {
+		score, matched := scores[id]
+		if scores != nil && !matched {
+			continue
+		}
+		if query.Active != nil && entry.doc.IsActive != *query.Active {
+			continue
+		}
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].ID < hits[b].ID
+	})
+	result := &Result{Ranked: len(terms) > 0, Total: len(hits)}
+	if len(hits) > MaxHits {
+		hits = hits[:MaxHits]
+	}
+	result.Hits = hits
+	return result, nil
+}
+
+// score returns the BM25 score of the documents containing every term. The last term also
+// matches the tokens it is a prefix of, so results show up while the user is still typing.
+func (i *MemoryIndex) score(terms []string) map[uint]float64 {
+	if len(i.docs) == 0 {
+		return map[uint]float64{}
+	}
+	n := float64(len(i.docs))
+	avgLength := i.totalLength / n
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	var scores map[uint]float64
+	for position, term := range terms {
+		frequencies := make(map[uint]float64)
+		for token, postings := range i.postings {
+			if token != term && !(position == len(terms)-1 && len(term) >= minPrefix && strings.HasPrefix(token, term)) {
+				continue
+			}
+			for id, tf := range postings {
+				frequencies[id] += tf
+			}
+		}
+
+		df := float64(len(frequencies))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		next := make(map[uint]float64, len(frequencies))
+		for id, tf := range frequencies {
+			if scores != nil {
+				if _, ok := scores[id]; !ok {
+					continue
+				}
+			}
+			norm := bm25K1 * (1 - bm25B + bm25B*i.docs[id].length/avgLength)
+			next[id] = scores[id] + idf*tf*(bm25K1+1)/(tf+norm)
+		}
+		scores = next
+	}
+	return scores
+}
@@ -0,0 +1,73 @@
+// Package search ranks activities by relevance to a free-text query. Index has two
+// implementations: MemoryIndex, an in-process BM25 index kept up
+// to date from domain events, and SQLIndex, which delegates to a MySQL FULLTEXT index.
+package search
+
+import (
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+// MaxHits bounds how many ranked ids a search returns; Result.Total still counts every match.
+const MaxHits = 1000
+
+// Document is the searchable view of an activity.
+type Document struct {
+	ID          uint
+	Title       string
+	Description string
+	Category    string
+	Instructor  string
+	IsActive    bool
+}
+
+// FromActivity builds the document of an activity.
+func FromActivity(activity *models.Activity) Document {
+	return Document{
+		ID:          activity.ID,
+		Title:       activity.Title,
+		Description: activity.Description,
+		Category:    activity.Category,
+		Instructor:  activity.Instructor,
+		IsActive:    activity.IsActive,
+	}
+}
+
+// Query selects documents. Text matches title, description, category and instructor, ignoring
+// case and accents; every term must match and the last one also matches as a prefix. The other
+// filters of a listing are applied by the caller, in SQL.
+type Query struct {
+	Text   string
+	Active *bool
+}
+
+// Hit is a matching document with its relevance score (higher is better).
+type Hit struct {
+	ID    uint    `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// Result is the outcome of a search.
+type Result struct {
+	// Ranked is false when the query has no searchable terms: every document passing the filters
+	// matches and Hits is in id order.
+	Ranked bool
+	Hits   []Hit
+	Total  int
+}
+
+// IDs returns the ids of the hits in rank order.
+func (r *Result) IDs() []uint {
+	ids := make([]uint, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+// Index is a searchable collection of activities.
+type Index interface {
+	// Upsert adds a document or replaces the one with the same id.
+	Upsert(doc Document) error
+	Remove(id uint) error
+	Search(query Query) (*Result, error)
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// matchColumns must list the columns of the idx_activities_search FULLTEXT index, in order.
+const matchColumns = "MATCH(activities.title, activities.description, activities.category, activities.instructor)"
+
+// SQLIndex searches the activities table through its MySQL FULLTEXT index. Accent folding comes
+// from the accent-insensitive utf8mb4 collation; relevance is MySQL's own score. The table is the
+// index, so Upsert and Remove have nothing to do and every instance sees the same data.
+type SQLIndex struct {
+	db *gorm.DB
+}
+
+func NewSQLIndex(db *gorm.DB) *SQLIndex {
+	return &SQLIndex{db: db}
+}
+
+func (i *SQLIndex) Upsert(Document) error {
+	return nil
+}
+
+func (i *SQLIndex) Remove(uint) error {
+	return nil
+}
+
+func (i *SQLIndex) Search(query Query) (*Result, error) {
+	terms := Tokenize(query.Text)
+	against := booleanQuery(terms)
+
+	scoped := func() *gorm.DB {
+		tx := i.db.Model(&models.Activity{})
+		if len(terms) > 0 {
+			tx = tx.Where(matchColumns+" AGAINST (? IN BOOLEAN MODE)", against)
+		}
+		if query.Active != nil {
+			tx = tx.Where("activities.is_active = ?", *query.Active)
+		}
+		return tx
+	}
+
+	result := &Result{Ranked: len(terms) > 0, Hits: []Hit{}}
+	var total int64
+	if err := scoped().Count(&total).Error; err != nil {
+		return nil, err
+	}
+	result.Total = int(total)
+
+	hits := scoped().Limit(MaxHits)
+	if result.Ranked {
+		hits = hits.Select("activities.id AS id, "+matchColumns+" AGAINST (? IN BOOLEAN MODE) AS score", against).
+			Order("score DESC, activities.id ASC")
+	} else {
+		hits = hits.Select("activities.id AS id, 0 AS score").Order("activities.id ASC")
+	}
+	if err := hits.Scan(&result.Hits).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// booleanQuery requires every term ("+term") and lets the last one match as a prefix, like
+// MemoryIndex. Tokens only hold letters and digits, so no boolean operator can slip through.
+func booleanQuery(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for position, term := range terms {
+		part := "+" + term
+		if position == len(terms)-1 && len(term) >= minPrefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// stopwords are frequent Spanish words that carry no meaning for a search.
+var stopwords = map[string]bool{
+	"a": true, "al": true, "con": true, "de": true, "del": true, "el": true, "en": true,
+	"la": true, "las": true, "lo": true, "los": true, "o": true, "para": true, "por": true,
+	"que": true, "se": true, "sin": true, "su": true, "un": true, "una": true, "y": true,
+}
+
+// Fold lower-cases text and strips its diacritics, so "Yóga Niño" becomes "yoga nino".
+func Fold(text string) string {
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, text)
+	if err != nil {
+		folded = text
+	}
+	return strings.ToLower(folded)
+}
+
+// Tokenize splits folded text into words of letters and digits, dropping stopwords.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(Fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if !stopwords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// Slug turns text into an identifier for URLs and filters: its folded words of letters and digits
+// joined with hyphens, so "Yoga Niños" becomes "yoga-ninos". Unlike Tokenize it keeps stopwords.
+func Slug(text string) string {
+	return strings.Join(strings.FieldsFunc(Fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+}
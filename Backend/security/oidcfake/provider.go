@@ -0,0 +1,237 @@
+// Package oidcfake is an in-process OpenID Connect provider used for local development and
+// end-to-end checks of the login flow without reaching an external identity service.
+package oidcfake
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// User is an account known by the fake provider.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider serves discovery, authorize, token and JWKS endpoints relative to its mount point.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+
+	keys *security.KeySet
+	key  *security.SigningKey
+
+	mu          sync.Mutex
+	users       map[string]User
+	defaultUser string
+	codes       map[string]authorization
+}
+
+type authorization struct {
+	User          User
+	Nonce         string
+	RedirectURI   string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// New creates a provider with a fresh RS256 key. issuer must be the absolute URL where it is mounted.
+func New(issuer, clientID, clientSecret string) (*Provider, error) {
+	keys, err := security.LoadKeySet("", security.AlgorithmRS256, "")
+	if err != nil {
+		return nil, err
+	}
+	key, err := keys.Active()
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		keys:         keys,
+		key:          key,
+		users:        map[string]User{},
+		codes:        map[string]authorization{},
+	}, nil
+}
+
+// AddUser registers an account. The first one added is used when no login_hint is sent.
+func (p *Provider) AddUser(user User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[user.Email] = user
+	if p.defaultUser == "" {
+		p.defaultUser = user.Email
+	}
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		p.discovery(w)
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	case "/jwks":
+		writeJSON(w, http.StatusOK, p.keys.JWKS())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *Provider) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{security.AlgorithmRS256},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize approves the request immediately as the hinted (or default) user.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != p.ClientID || query.Get("response_type") != "code" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "pkce_required", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid_redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	email := query.Get("login_hint")
+	if email == "" {
+		email = p.defaultUser
+	}
+	user, ok := p.users[email]
+	p.mu.Unlock()
+	if !ok {
+		http.Error(w, "access_denied", http.StatusForbidden)
+		return
+	}
+
+	code := randomToken()
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		User:          user,
+		Nonce:         query.Get("nonce"),
+		RedirectURI:   redirectURI.String(),
+		CodeChallenge: query.Get("code_challenge"),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, "invalid_request")
+		return
+	}
+	if p.ClientSecret != "" {
+		clientID, secret, ok := r.BasicAuth()
+		if ok {
+			clientID, _ = url.QueryUnescape(clientID)
+			secret, _ = url.QueryUnescape(secret)
+		} else {
+			clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if clientID != p.ClientID || secret != p.ClientSecret {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	auth, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(auth.ExpiresAt) || r.PostForm.Get("redirect_uri") != auth.RedirectURI {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	verifierSum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifierSum[:]) != auth.CodeChallenge {
+		writeTokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.Issuer,
+		"sub":            auth.User.Subject,
+		"aud":            p.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          auth.Nonce,
+		"email":          auth.User.Email,
+		"email_verified": auth.User.EmailVerified,
+		"name":           auth.User.Name,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.key.ID
+	idToken, err := token.SignedString(p.key.PrivateKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomToken(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func randomToken() string {
+	buf := make([]byte, 24)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
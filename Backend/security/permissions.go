@@ -0,0 +1,117 @@
+package security
+
+import "sort"
+
+// Permissions granted to roles and, where marked delegable, to API keys as scopes.
+const (
+	PermActivitiesRead    = "activities:read"
+	PermActivitiesWrite   = "activities:write"
+	PermActivitiesDelete  = "activities:delete"
+	PermActivitiesPurge   = "activities:purge"
+	PermEnrollmentsRead   = "enrollments:read"
+	PermRostersManage     = "rosters:manage"
+	PermAttendanceWrite   = "attendance:write"
+	PermMembershipsManage = "memberships:manage"
+	PermAPIKeysManage     = "api_keys:manage"
+	PermRolesManage       = "roles:manage"
+	PermUsersManage       = "users:manage"
+	PermWebhooksManage    = "webhooks:manage"
+	PermAuditRead         = "audit:read"
+)
+
+// Built-in role names.
+const (
+	RoleAdmin     = "admin"
+	RoleSocio     = "socio"
+	RoleRecepcion = "recepcion"
+)
+
+// permissionCatalog maps every known permission to whether API keys may hold it.
+// Credential and role management are never delegated to machines.
+var permissionCatalog = map[string]bool{
+	PermActivitiesRead:    true,
+	PermActivitiesWrite:   true,
+	PermActivitiesDelete:  true,
+	PermActivitiesPurge:   false,
+	PermEnrollmentsRead:   true,
+	PermRostersManage:     true,
+	PermAttendanceWrite:   true,
+	PermMembershipsManage: false,
+	PermAPIKeysManage:     false,
+	PermRolesManage:       false,
+	PermUsersManage:       false,
+	PermWebhooksManage:    false,
+	PermAuditRead:         false,
+}
+
+// AllPermissions lists the catalogue sorted by name.
+func AllPermissions() []string {
+	perms := make([]string, 0, len(permissionCatalog))
+	for perm := range permissionCatalog {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// DelegablePermissions lists the permissions that may be granted to API keys.
+func DelegablePermissions() []string {
+	perms := make([]string, 0, len(permissionCatalog))
+	for perm, delegable := range permissionCatalog {
+		if delegable {
+			perms = append(perms, perm)
+		}
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// IsPermission reports whether perm belongs to the catalogue.
+func IsPermission(perm string) bool {
+	_, ok := permissionCatalog[perm]
+	return ok
+}
+
+// IsDelegable reports whether perm may be granted to an API key.
+func IsDelegable(perm string) bool {
+	return permissionCatalog[perm]
+}
+
+// DefaultRolePermissions describes the roles created on startup when missing.
+func DefaultRolePermissions() map[string][]string {
+	return map[string][]string{
+		RoleAdmin: AllPermissions(),
+		RoleSocio: {},
+		RoleRecepcion: {
+			PermActivitiesRead,
+			PermEnrollmentsRead,
+			PermRostersManage,
+			PermAttendanceWrite,
+		},
+	}
+}
+
+// Policy is an in-memory role → permissions table. It has no I/O so checks can be
+// unit-tested without a database or HTTP stack.
+type Policy map[string]map[string]bool
+
+// NewPolicy builds a policy from role names and their permission lists.
+func NewPolicy(roles map[string][]string) Policy {
+	policy := make(Policy, len(roles))
+	for role, perms := range roles {
+		set := make(map[string]bool, len(perms))
+		for _, perm := range perms {
+			set[perm] = true
+		}
+		policy[role] = set
+	}
+	return policy
+}
+
+// Allows reports whether role holds permission. The admin role always holds everything.
+func (p Policy) Allows(role, permission string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	return p[role][permission]
+}
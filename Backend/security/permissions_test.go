@@ -0,0 +1,70 @@
+package security
+
+import "testing"
+
+func TestPolicyAllowsDefaultRoles(t *testing.T) {
+	policy := NewPolicy(DefaultRolePermissions())
+
+	tests := []struct {
+		name       string
+		role       string
+		permission string
+		want       bool
+	}{
+		{"admin manages roles", RoleAdmin, PermRolesManage, true},
+		{"admin purges activities", RoleAdmin, PermActivitiesPurge, true},
+		{"admin reads audit", RoleAdmin, PermAuditRead, true},
+		{"recepcion reads activities", RoleRecepcion, PermActivitiesRead, true},
+		{"recepcion manages rosters", RoleRecepcion, PermRostersManage, true},
+		{"recepcion takes attendance", RoleRecepcion, PermAttendanceWrite, true},
+		{"recepcion cannot write activities", RoleRecepcion, PermActivitiesWrite, false},
+		{"recepcion cannot manage memberships", RoleRecepcion, PermMembershipsManage, false},
+		{"recepcion cannot manage roles", RoleRecepcion, PermRolesManage, false},
+		{"socio cannot read enrollments", RoleSocio, PermEnrollmentsRead, false},
+		{"socio cannot write activities", RoleSocio, PermActivitiesWrite, false},
+		{"socio cannot manage api keys", RoleSocio, PermAPIKeysManage, false},
+		{"unknown role is denied", "entrenador", PermActivitiesRead, false},
+		{"empty role is denied", "", PermActivitiesRead, false},
+		{"unknown permission is denied", RoleRecepcion, "activities:export", false},
+		{"admin holds unknown permissions", RoleAdmin, "activities:export", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allows(tt.role, tt.permission); got != tt.want {
+				t.Fatalf("Allows(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowsCustomRole(t *testing.T) {
+	policy := NewPolicy(map[string][]string{
+		"entrenador": {PermAttendanceWrite},
+		RoleAdmin:    {},
+	})
+
+	if !policy.Allows("entrenador", PermAttendanceWrite) {
+		t.Fatalf("custom role should hold %s", PermAttendanceWrite)
+	}
+	if policy.Allows("entrenador", PermRostersManage) {
+		t.Fatalf("custom role should not hold %s", PermRostersManage)
+	}
+	if !policy.Allows(RoleAdmin, PermUsersManage) {
+		t.Fatalf("admin should hold every permission even with an empty list")
+	}
+	if policy.Allows(RoleRecepcion, PermActivitiesRead) {
+		t.Fatalf("roles missing from the table should hold nothing")
+	}
+}
+
+func TestPermissionCatalogue(t *testing.T) {
+	if !IsPermission(PermAuditRead) || IsPermission("activities:export") {
+		t.Fatalf("IsPermission does not match the catalogue")
+	}
+	if !IsDelegable(PermActivitiesRead) || IsDelegable(PermRolesManage) || IsDelegable("activities:export") {
+		t.Fatalf("IsDelegable does not match the catalogue")
+	}
+	if got, want := len(DefaultRolePermissions()[RoleAdmin]), len(AllPermissions()); got != want {
+		t.Fatalf("admin default permissions = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,352 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Supported asymmetric signing algorithms (JOSE names).
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	rsaKeyBits = 2048
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKeyID         = errors.New("unknown key id")
+	ErrNoSigningKey         = errors.New("no active signing key")
+)
+
+// SigningKey is one entry of the key ring. Retired keys keep only their public half.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// KeySet holds every key that may verify tokens plus the one currently used to sign.
+type KeySet struct {
+	keys     map[string]*SigningKey
+	order    []string
+	activeID string
+}
+
+// JWK is the public JSON Web Key representation published through JWKS.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeySet reads PEM keys from dir (file name without extension is the kid).
+// Files ending in .pub.pem only verify. When dir holds no private key one is generated
+// and persisted there so restarts keep validating already issued tokens; an empty dir
+// yields a throwaway in-memory key, only meant for tests and the fake OIDC provider.
+// activeID selects the signing key; by default the last private key by name wins.
+func LoadKeySet(dir, algorithm, activeID string) (*KeySet, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	set := &KeySet{keys: map[string]*SigningKey{}}
+	if dir != "" {
+		if err := set.loadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if set.lastPrivateID() == "" {
+		key, err := GenerateSigningKey(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			if err := writePrivateKey(dir, key); err != nil {
+				return nil, err
+			}
+		}
+		set.Add(key)
+	}
+
+	if activeID == "" {
+		activeID = set.lastPrivateID()
+	}
+	if err := set.SetActive(activeID); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// GenerateSigningKey creates a fresh key whose kid is derived from its public key.
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var signer crypto.Signer
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer = key
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	kid, err := thumbprintID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: kid, Algorithm: algorithm, PrivateKey: signer, PublicKey: signer.Public()}, nil
+}
+
+// Add registers a key in the ring, replacing any key with the same kid. A public-only key never
+// replaces a private one: a key directory usually holds both halves of a key under one kid.
+func (s *KeySet) Add(key *SigningKey) {
+	existing, exists := s.keys[key.ID]
+	if !exists {
+		s.order = append(s.order, key.ID)
+	}
+	if exists && existing.PrivateKey != nil && key.PrivateKey == nil {
+		return
+	}
+	s.keys[key.ID] = key
+}
+
+// SetActive switches the signing key. The key must hold a private half.
+func (s *KeySet) SetActive(kid string) error {
+	key, ok := s.keys[kid]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+	if key.PrivateKey == nil {
+		return fmt.Errorf("%w: %s has no private key", ErrNoSigningKey, kid)
+	}
+	s.activeID = kid
+	return nil
+}
+
+// Active returns the key used to sign new tokens.
+func (s *KeySet) Active() (*SigningKey, error) {
+	key, ok := s.keys[s.activeID]
+	if !ok {
+		return nil, ErrNoSigningKey
+	}
+	return key, nil
+}
+
+// Lookup returns the key matching kid for verification.
+func (s *KeySet) Lookup(kid string) (*SigningKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+	return key, nil
+}
+
+// JWKS returns the public keys in insertion order.
+func (s *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: make([]JWK, 0, len(s.order))}
+	for _, kid := range s.order {
+		key := s.keys[kid]
+		jwk, err := publicJWK(key)
+		if err != nil {
+			continue
+		}
+		doc.Keys = append(doc.Keys, jwk)
+	}
+	return doc
+}
+
+// PublicKeyFromJWK converts a published JWK back into a verification key.
+func PublicKeyFromJWK(jwk JWK) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedAlgorithm, jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: key type %s", ErrUnsupportedAlgorithm, jwk.KeyType)
+	}
+}
+
+func (s *KeySet) lastPrivateID() string {
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if s.keys[s.order[i]].PrivateKey != nil {
+			return s.order[i]
+		}
+	}
+	return ""
+}
+
+func (s *KeySet) loadDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		key, err := parsePEMKey(raw)
+		if err != nil {
+			return fmt.Errorf("key file %s: %w", name, err)
+		}
+		key.ID = strings.TrimSuffix(strings.TrimSuffix(name, ".pem"), ".pub")
+		s.Add(key)
+	}
+	return nil
+}
+
+func parsePEMKey(raw []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return signingKeyFromPrivate(parsed)
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return signingKeyFromPrivate(parsed)
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch pub := parsed.(type) {
+		case *rsa.PublicKey:
+			return &SigningKey{Algorithm: AlgorithmRS256, PublicKey: pub}, nil
+		case ed25519.PublicKey:
+			return &SigningKey{Algorithm: AlgorithmEdDSA, PublicKey: pub}, nil
+		}
+		return nil, fmt.Errorf("%w: public key type %T", ErrUnsupportedAlgorithm, parsed)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+}
+
+func signingKeyFromPrivate(parsed any) (*SigningKey, error) {
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Algorithm: AlgorithmRS256, PrivateKey: key, PublicKey: key.Public()}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Algorithm: AlgorithmEdDSA, PrivateKey: key, PublicKey: key.Public()}, nil
+	default:
+		return nil, fmt.Errorf("%w: private key type %T", ErrUnsupportedAlgorithm, parsed)
+	}
+}
+
+func writePrivateKey(dir string, key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(filepath.Join(dir, key.ID+".pem"), encoded, 0o600)
+}
+
+func publicJWK(key *SigningKey) (JWK, error) {
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: AlgorithmRS256,
+			N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: AlgorithmEdDSA,
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("%w: public key type %T", ErrUnsupportedAlgorithm, key.PublicKey)
+	}
+}
+
+func thumbprintID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -0,0 +1,142 @@
+package security
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestKey(t *testing.T, dir string, key *SigningKey, kid string, public bool) {
+	t.Helper()
+	if !public {
+		if err := writePrivateKey(dir, &SigningKey{ID: kid, PrivateKey: key.PrivateKey}); err != nil {
+			t.Fatalf("write private key: %v", err)
+		}
+		return
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pub.pem"), encoded, 0o600); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+}
+
+func generateTestKey(t *testing.T, algorithm string) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(algorithm)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func TestLoadKeySetKeepsPrivateKeyNextToItsPublicHalf(t *testing.T) {
+	dir := t.TempDir()
+	key := generateTestKey(t, AlgorithmEdDSA)
+	writeTestKey(t, dir, key, "a", false)
+	writeTestKey(t, dir, key, "a", true)
+
+	set, err := LoadKeySet(dir, AlgorithmEdDSA, "")
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	active, err := set.Active()
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if active.ID != "a" || active.PrivateKey == nil {
+		t.Fatalf("active key %q with private key %t, want a with its private key", active.ID, active.PrivateKey != nil)
+	}
+	if keys := len(set.JWKS().Keys); keys != 1 {
+		t.Fatalf("JWKS has %d keys, want 1", keys)
+	}
+}
+
+func TestLoadKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	retired := generateTestKey(t, AlgorithmRS256)
+	current := generateTestKey(t, AlgorithmRS256)
+	next := generateTestKey(t, AlgorithmRS256)
+	writeTestKey(t, dir, retired, "2024-01", true)
+	writeTestKey(t, dir, current, "2024-02", false)
+	writeTestKey(t, dir, next, "2024-03", false)
+
+	set, err := LoadKeySet(dir, AlgorithmRS256, "")
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	if active, _ := set.Active(); active.ID != "2024-03" {
+		t.Fatalf("default active key = %s, want the last private key 2024-03", active.ID)
+	}
+
+	set, err = LoadKeySet(dir, AlgorithmRS256, "2024-02")
+	if err != nil {
+		t.Fatalf("LoadKeySet with an active kid: %v", err)
+	}
+	if active, _ := set.Active(); active.ID != "2024-02" {
+		t.Fatalf("active key = %s, want 2024-02", active.ID)
+	}
+	if err := set.SetActive("2024-01"); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("SetActive on a retired key = %v, want %v", err, ErrNoSigningKey)
+	}
+	if _, err := set.Lookup("2024-01"); err != nil {
+		t.Fatalf("retired key still verifies: Lookup = %v", err)
+	}
+	if _, err := set.Lookup("2023-12"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("Lookup of an unknown kid = %v, want %v", err, ErrUnknownKeyID)
+	}
+}
+
+func TestLoadKeySetPersistsGeneratedKey(t *testing.T) {
+	dir := t.TempDir()
+	first, err := LoadKeySet(dir, AlgorithmEdDSA, "")
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	second, err := LoadKeySet(dir, AlgorithmEdDSA, "")
+	if err != nil {
+		t.Fatalf("LoadKeySet after a restart: %v", err)
+	}
+	a, _ := first.Active()
+	b, _ := second.Active()
+	if a.ID != b.ID {
+		t.Fatalf("active key changed across restarts: %s then %s", a.ID, b.ID)
+	}
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	set := &KeySet{keys: map[string]*SigningKey{}}
+	rsaKey := generateTestKey(t, AlgorithmRS256)
+	edKey := generateTestKey(t, AlgorithmEdDSA)
+	set.Add(rsaKey)
+	set.Add(edKey)
+
+	doc := set.JWKS()
+	if len(doc.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(doc.Keys))
+	}
+	for i, want := range []struct {
+		key *SigningKey
+		kty string
+	}{{rsaKey, "RSA"}, {edKey, "OKP"}} {
+		jwk := doc.Keys[i]
+		if jwk.KeyID != want.key.ID || jwk.KeyType != want.kty || jwk.Algorithm != want.key.Algorithm || jwk.Use != "sig" {
+			t.Fatalf("JWK %d = %+v, want kid %s, kty %s, alg %s", i, jwk, want.key.ID, want.kty, want.key.Algorithm)
+		}
+		public, err := PublicKeyFromJWK(jwk)
+		if err != nil {
+			t.Fatalf("PublicKeyFromJWK: %v", err)
+		}
+		got, _ := x509.MarshalPKIXPublicKey(public)
+		expected, _ := x509.MarshalPKIXPublicKey(want.key.PublicKey)
+		if string(got) != string(expected) {
+			t.Fatalf("JWK %s does not round-trip to its public key", jwk.KeyID)
+		}
+	}
+}
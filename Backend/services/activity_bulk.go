@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxBulkActivities is the most activities a bulk update may match.
+const MaxBulkActivities = 200
+
+// Reasons a bulk update cannot be applied to an activity.
+const (
+	// BulkOutOfDay: shifting the schedule would move the class out of its day.
+	BulkOutOfDay = "out_of_day"
+	// BulkUpdateImpact: the change affects enrolled members and no strategy allows it.
+	BulkUpdateImpact = "update_impact"
+	// BulkInvalidTransition: the activity cannot move to the requested status from its own.
+	BulkInvalidTransition = "invalid_transition"
+	// BulkHasEnrollments: members hold or wait for a seat in an activity being archived without
+	// cancelling them, or sent back to draft.
+	BulkHasEnrollments = "has_enrollments"
+)
+
+var (
+	ErrNoBulkChanges      = errors.New("bulk update changes nothing")
+	ErrInvalidBulkChanges = errors.New("invalid bulk update")
+	ErrBulkTooLarge       = errors.New("bulk update matches too many activities")
+	ErrBulkRejected       = errors.New("bulk update rejected")
+	ErrScheduleOutOfDay   = errors.New("schedule leaves the day")
+
+	// errBulkRollback undoes a bulk update that was only previewed or that some activity rejects.
+	errBulkRollback = errors.New("bulk update rolled back")
+)
+
+// BulkChanges is the change a bulk update makes to every activity it matches; nil fields are left
+// as they are. Status moves the activities along their lifecycle instead, and cannot be combined
+// with field changes.
+type BulkChanges struct {
+	// ShiftMinutes moves the start and end times, later when positive and earlier when negative.
+	ShiftMinutes *int
+	DayOfWeek    *int
+	CategoryID   *uint
+	Instructor   *string
+	Location     *string
+	Capacity     *int
+	PriceCents   *int
+	SeasonID     *uint
+	Status       string
+	// CancelEnrollments lets archiving cancel the members who hold or wait for a seat, as with
+	// ChangeActivityStatus.
+	CancelEnrollments bool
+}
+
+func (c BulkChanges) validate() error {
+	fields := c.ShiftMinutes != nil || c.DayOfWeek != nil || c.CategoryID != nil || c.Instructor != nil ||
+		c.Location != nil || c.Capacity != nil || c.PriceCents != nil || c.SeasonID != nil
+	switch {
+	case c.Status == "" && !fields:
+		return ErrNoBulkChanges
+	case c.Status != "" && fields:
+		return fmt.Errorf("%w: a status change cannot be combined with field changes", ErrInvalidBulkChanges)
+	case c.Status != "" && !ValidActivityStatus(c.Status):
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, c.Status)
+	case c.CancelEnrollments && c.Status != models.ActivityArchived:
+		return fmt.Errorf("%w: enrollments are only cancelled when archiving", ErrInvalidBulkChanges)
+	}
+	return nil
+}
+
+// apply makes the field changes to activity, or sets its status.
+func (c BulkChanges) apply(activity *models.Activity) error {
+	if c.Status != "" {
+		activity.SetStatus(c.Status)
+		return nil
+	}
+	if c.ShiftMinutes != nil {
+		start, err := shiftClock(activity.StartTime, *c.ShiftMinutes)
+		if err != nil {
+			return err
+		}
+		end, err := shiftClock(activity.EndTime, *c.ShiftMinutes)
+		if err != nil {
+			return err
+		}
+		activity.StartTime, activity.EndTime = start, end
+	}
+	if c.DayOfWeek != nil {
+		activity.DayOfWeek = *c.DayOfWeek
+	}
+	if c.CategoryID != nil {
+		activity.CategoryID = c.CategoryID
+	}
+	if c.Instructor != nil {
+		activity.Instructor = *c.Instructor
+	}
+	if c.Location != nil {
+		activity.Location = *c.Location
+	}
+	if c.Capacity != nil {
+		activity.Capacity = *c.Capacity
+	}
+	if c.PriceCents != nil {
+		activity.PriceCents = *c.PriceCents
+	}
+	if c.SeasonID != nil {
+		activity.SeasonID = c.SeasonID
+	}
+	return nil
+}
+
+// shiftClock moves an "HH:MM" time by minutes. The result must stay within the same day.
+func shiftClock(value string, minutes int) (string, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		if clock, err = time.Parse("15:04:05", value); err != nil {
+			return "", fmt.Errorf("%w: %q is not a time", ErrScheduleOutOfDay, value)
+		}
+	}
+	shifted := clock.Hour()*60 + clock.Minute() + minutes
+	if shifted < 0 || shifted >= 24*60 {
+		return "", fmt.Errorf("%w: %s moved %d minutes", ErrScheduleOutOfDay, value, minutes)
+	}
+	return fmt.Sprintf("%02d:%02d", shifted/60, shifted%60), nil
+}
+
+// BulkItem is what a bulk update does, or would do, to one activity. Changes is empty when the
+// activity already looks like the change; Reason is set when it cannot take it.
+type BulkItem struct {
+	ActivityID           uint                   `json:"activity_id"`
+	Title                string                 `json:"title"`
+	Changes              map[string]FieldChange `json:"changes"`
+	Impact               *UpdateImpact          `json:"impact,omitempty"`
+	CancelledEnrollments int                    `json:"cancelled_enrollments,omitempty"`
+	Reason               string                 `json:"reason,omitempty"`
+	Detail               string                 `json:"detail,omitempty"`
+}
+
+// BulkResult reports a bulk update activity by activity.
+type BulkResult struct {
+	Matched  int        `json:"matched"`
+	Rejected int        `json:"rejected"`
+	DryRun   bool       `json:"dry_run"`
+	Applied  bool       `json:"applied"`
+	Items    []BulkItem `json:"items"`
+}
+
+// BulkUpdateActivities applies changes to every activity matching filter, at most
+// MaxBulkActivities, in a single transaction: either every activity takes the change or none
+// does. Each one goes through the same rules as a single update or status change, and the
+// activities are handled in id order, each seeing the ones before already changed.
+//
+// When some activity cannot take the change it fails with ErrBulkRejected and the result tells
+// which and why. With dryRun the update is carried out and rolled back, so the result previews it
+// exactly without changing anything.
+func (s *ActivityService) BulkUpdateActivities(filter AdminActivityFilter, changes BulkChanges, strategy string, dryRun bool, actor Actor) (*BulkResult, error) {
+	if err := changes.validate(); err != nil {
+		return nil, err
+	}
+	if !ValidImpactStrategy(strategy) {
+		return nil, ErrInvalidStrategy
+	}
+	if err := s.waitlist.expireStaleHolds(); err != nil {
+		return nil, err
+	}
+
+	result := &BulkResult{DryRun: dryRun, Items: []BulkItem{}}
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		found, err := s.searchAdminActivities(tx, filter)
+		if err != nil {
+			return err
+		}
+		var activities []models.Activity
+		if err := found.scoped(true, true).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Order("activities.id ASC").
+			Limit(MaxBulkActivities + 1).
+			Find(&activities).Error; err != nil {
+			return err
+		}
+		if len(activities) > MaxBulkActivities {
+			return fmt.Errorf("%w: the limit is %d", ErrBulkTooLarge, MaxBulkActivities)
+		}
+
+		result.Matched = len(activities)
+		for i := range activities {
+			item, err := s.bulkUpdateActivity(tx, &activities[i], changes, strategy, actor)
+			if err != nil {
+				return err
+			}
+			if item.Reason != "" {
+				result.Rejected++
+			}
+			result.Items = append(result.Items, *item)
+		}
+		if dryRun || result.Rejected > 0 {
+			return errBulkRollback
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errBulkRollback):
+		if !dryRun {
+			return result, ErrBulkRejected
+		}
+		return result, nil
+	case err != nil:
+		return nil, err
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// bulkUpdateActivity applies changes to one activity on tx. An activity that cannot take them is
+// left untouched and the returned item says why; other failures are returned.
+func (s *ActivityService) bulkUpdateActivity(tx *gorm.DB, activity *models.Activity, changes BulkChanges, strategy string, actor Actor) (*BulkItem, error) {
+	item := &BulkItem{ActivityID: activity.ID, Title: activity.Title, Changes: map[string]FieldChange{}}
+	updated := *activity
+	if err := changes.apply(&updated); err != nil {
+		return item, rejectBulkItem(item, err)
+	}
+	if changes.CategoryID != nil {
+		if err := assignCategory(tx, &updated); err != nil {
+			return nil, err
+		}
+	}
+
+	before, err := auditFields(activity)
+	if err != nil {
+		return nil, err
+	}
+	after, err := auditFields(&updated)
+	if err != nil {
+		return nil, err
+	}
+	item.Changes = diffFields(before, after)
+	if len(item.Changes) == 0 {
+		return item, nil
+	}
+
+	if changes.Status != "" {
+		_, cancelled, err := s.changeStatus(tx, activity.ID, changes.Status, changes.CancelEnrollments, actor)
+		if err != nil {
+			return item, rejectBulkItem(item, err)
+		}
+		item.CancelledEnrollments = cancelled
+		return item, nil
+	}
+
+	impact, err := s.applyUpdate(tx, &updated, strategy, actor, auditChange{Action: "activity.bulk_updated"})
+	item.Impact = impact
+	if err != nil {
+		return item, rejectBulkItem(item, err)
+	}
+	return item, nil
+}
+
+// rejectBulkItem records on item why its activity cannot take a bulk update. Errors that are not
+// about the activity are returned as they are.
+func rejectBulkItem(item *BulkItem, err error) error {
+	switch {
+	case errors.Is(err, ErrScheduleOutOfDay):
+		item.Reason = BulkOutOfDay
+	case errors.Is(err, ErrUpdateImpact):
+		item.Reason = BulkUpdateImpact
+	case errors.Is(err, ErrInvalidTransition):
+		item.Reason = BulkInvalidTransition
+	case errors.Is(err, ErrActivityHasEnrollments):
+		item.Reason = BulkHasEnrollments
+	default:
+		return err
+	}
+	item.Detail = err.Error()
+	return nil
+}
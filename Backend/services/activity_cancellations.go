@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sessionDateLayout is the format of a session date, e.g. "2024-05-13".
+const sessionDateLayout = "2006-01-02"
+
+var (
+	ErrInvalidSessionDate      = errors.New("date is not a session of the activity")
+	ErrSessionAlreadyCancelled = errors.New("session already cancelled")
+	ErrCancellationNotFound    = errors.New("cancellation not found")
+)
+
+// CancelSession records that the activity does not take place on date (YYYY-MM-DD), which must
+// fall on the activity's day of the week. Members holding a seat are told.
+func (s *ActivityService) CancelSession(activityID uint, date, reason string, createdByID uint) (*models.ActivityCancellation, error) {
+	activity, err := s.findActivity(activityID)
+	if err != nil {
+		return nil, err
+	}
+	day, err := time.Parse(sessionDateLayout, strings.TrimSpace(date))
+	if err != nil || int(day.Weekday()) != activity.DayOfWeek {
+		return nil, ErrInvalidSessionDate
+	}
+
+	cancellation := models.ActivityCancellation{
+		ActivityID:  activityID,
+		Date:        day.Format(sessionDateLayout),
+		Reason:      strings.TrimSpace(reason),
+		CreatedByID: createdByID,
+	}
+	err = s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&cancellation)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSessionAlreadyCancelled
+		}
+		if err := recordAudit(tx, UserActor(createdByID), auditChange{
+			Action:     "activity.session_cancelled",
+			EntityType: AuditActivity,
+			EntityID:   activityID,
+			Extra: map[string]FieldChange{
+				"cancelled_session": {After: map[string]string{"date": cancellation.Date, "reason": cancellation.Reason}},
+			},
+		}); err != nil {
+			return err
+		}
+		return s.events.Record(tx, sessionEvent(events.ActivitySessionCancelled, &cancellation))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cancellation, nil
+}
+
+// ListCancellations returns the cancelled sessions of an activity, oldest first.
+func (s *ActivityService) ListCancellations(activityID uint) ([]models.ActivityCancellation, error) {
+	if _, err := s.findActivity(activityID); err != nil {
+		return nil, err
+	}
+	var cancellations []models.ActivityCancellation
+	if err := s.db.Where("activity_id = ?", activityID).Order("date ASC").Find(&cancellations).Error; err != nil {
+		return nil, err
+	}
+	return cancellations, nil
+}
+
+// RestoreSession removes the cancellation of a session, so it takes place again. Members holding
+// a seat are told.
+func (s *ActivityService) RestoreSession(activityID uint, date string, actor Actor) error {
+	if _, err := s.findActivity(activityID); err != nil {
+		return err
+	}
+	return s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var cancellation models.ActivityCancellation
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("activity_id = ? AND date = ?", activityID, date).
+			First(&cancellation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCancellationNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&cancellation).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, actor, auditChange{
+			Action:     "activity.session_restored",
+			EntityType: AuditActivity,
+			EntityID:   activityID,
+			Extra: map[string]FieldChange{
+				"cancelled_session": {Before: map[string]string{"date": cancellation.Date, "reason": cancellation.Reason}},
+			},
+		}); err != nil {
+			return err
+		}
+		return s.events.Record(tx, sessionEvent(events.ActivitySessionRestored, &cancellation))
+	})
+}
+
+func sessionEvent(eventType string, cancellation *models.ActivityCancellation) events.Event {
+	evt := events.New(eventType)
+	evt.ActivityID = cancellation.ActivityID
+	evt.Data = map[string]interface{}{"date": cancellation.Date, "reason": cancellation.Reason}
+	return evt
+}
+
+// cancelledSessions maps each of the given activities to its cancelled session dates.
+func cancelledSessions(db *gorm.DB, activityIDs []uint) (map[uint][]string, error) {
+	dates := make(map[uint][]string)
+	if len(activityIDs) == 0 {
+		return dates, nil
+	}
+	var cancellations []models.ActivityCancellation
+	if err := db.Where("activity_id IN ?", activityIDs).Order("date ASC").Find(&cancellations).Error; err != nil {
+		return nil, err
+	}
+	for _, cancellation := range cancellations {
+		dates[cancellation.ActivityID] = append(dates[cancellation.ActivityID], cancellation.Date)
+	}
+	return dates, nil
+}
+
+func (s *ActivityService) findActivity(id uint) (*models.Activity, error) {
+	var activity models.Activity
+	if err := s.db.First(&activity, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrActivityNotFound
+		}
+		return nil, err
+	}
+	return &activity, nil
+}
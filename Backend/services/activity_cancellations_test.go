@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+func TestCancelAndRestoreSessionRecordEvents(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.Activity{}, &models.ActivityCancellation{}, &models.AuditLog{})
+	bus := events.NewBus()
+	var recorded []events.Event
+	bus.AfterCommit(func(evt events.Event) { recorded = append(recorded, evt) })
+	admin := models.User{Name: "Admin", Email: "admin@example.com", PasswordHash: "x", Role: "admin"}
+	mustCreate(t, db, &admin)
+	activity := models.Activity{Title: "Yoga", Category: "yoga", DayOfWeek: 1, StartTime: "10:00", EndTime: "11:00",
+		Capacity: 10, Instructor: "Profe"}
+	activity.SetStatus(models.ActivityPublished)
+	mustCreate(t, db, &activity)
+	service := NewActivityService(db, bus, nil, nil, time.UTC)
+
+	if _, err := service.CancelSession(activity.ID, "2024-05-13", "Feriado", admin.ID); err != nil {
+		t.Fatalf("CancelSession: %v", err)
+	}
+	if _, err := service.CancelSession(activity.ID, "2024-05-13", "Feriado", admin.ID); !errors.Is(err, ErrSessionAlreadyCancelled) {
+		t.Fatalf("CancelSession twice = %v, want %v", err, ErrSessionAlreadyCancelled)
+	}
+	if err := service.RestoreSession(activity.ID, "2024-05-13", UserActor(admin.ID)); err != nil {
+		t.Fatalf("RestoreSession: %v", err)
+	}
+
+	var types []string
+	for _, evt := range recorded {
+		types = append(types, evt.Type)
+		if evt.ActivityID != activity.ID || evt.Data["date"] != "2024-05-13" || evt.Data["reason"] != "Feriado" {
+			t.Fatalf("%s event = %+v, want activity %d on 2024-05-13 for Feriado", evt.Type, evt, activity.ID)
+		}
+	}
+	want := []string{events.ActivitySessionCancelled, events.ActivitySessionRestored}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/search"
+	"gorm.io/gorm"
+)
+
+// durationExpr is the length of an activity in seconds. TIME_TO_SEC reads "HH:MM" as hours and
+// minutes, so it also handles times stored without a leading zero.
+const durationExpr = "(TIME_TO_SEC(activities.end_time) - TIME_TO_SEC(activities.start_time))"
+
+// CategoryFacet counts the matches in a category.
+type CategoryFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// DayFacet counts the matches on a day of the week.
+type DayFacet struct {
+	Value int `json:"value"`
+	Count int `json:"count"`
+}
+
+// ActivityFacets count the matches of a listing per category and per day. Each facet ignores its
+// own filter, so the counts show what selecting another value would return.
+type ActivityFacets struct {
+	Category []CategoryFacet `json:"category"`
+	Day      []DayFacet      `json:"day"`
+}
+
+// activityQuery is a listing narrowed by an ActivityFilter. base holds the conditions that are
+// not part of the filter, such as is_active.
+type activityQuery struct {
+	base   *gorm.DB
+	filter ActivityFilter
+	// now is the instant availability is evaluated at, for filtering, ordering and the page, in the
+	// gym's time zone.
+	now time.Time
+	// like matches Query with LIKE on title and description, when there is no search index.
+	like bool
+	// ranked is set when the search index matched Query; ranking holds its hits, best first.
+	ranked  bool
+	ranking []uint
+}
+
+// searchActivities resolves the text of filter through the search index, if any, and returns the
+// query of the listing.
+func (s *ActivityService) searchActivities(base *gorm.DB, filter ActivityFilter, active *bool) (*activityQuery, error) {
+	query := &activityQuery{base: base, filter: filter, now: time.Now().In(s.location)}
+	if filter.Query == "" {
+		return query, nil
+	}
+	if s.search == nil {
+		query.like = true
+		return query, nil
+	}
+	result, err := s.search.Search(search.Query{Text: filter.Query, Active: active})
+	if err != nil {
+		return nil, err
+	}
+	if result.Ranked {
+		query.ranked = true
+		query.ranking = result.IDs()
+	}
+	return query, nil
+}
+
+// scoped applies the filter. The category and day filters can be left out to count their facets.
+// The result can be reused for several statements.
+func (q *activityQuery) scoped(withCategories, withDays bool) *gorm.DB {
+	tx := q.base.Session(&gorm.Session{})
+	filter := q.filter
+	if q.like {
+		like := "%" + filter.Query + "%"
+		tx = tx.Where("activities.title LIKE ? OR activities.description LIKE ?", like, like)
+	}
+	if q.ranked {
+		if len(q.ranking) == 0 {
+			tx = tx.Where("1 = 0")
+		} else {
+			tx = tx.Where("activities.id IN ?", q.ranking)
+		}
+	}
+	if withCategories && len(filter.Categories) > 0 {
+		tx = tx.Where("activities.category IN ?", filter.Categories)
+	}
+	if withDays && len(filter.Days) > 0 {
+		tx = tx.Where("activities.day_of_week IN ?", filter.Days)
+	}
+	if filter.Instructor != "" {
+		tx = tx.Where("activities.instructor LIKE ?", "%"+filter.Instructor+"%")
+	}
+	if filter.StartAfter != "" {
+		tx = tx.Where("TIME_TO_SEC(activities.start_time) >= TIME_TO_SEC(?)", filter.StartAfter)
+	}
+	if filter.StartBefore != "" {
+		tx = tx.Where("TIME_TO_SEC(activities.start_time) < TIME_TO_SEC(?)", filter.StartBefore)
+	}
+	if filter.MinDuration != nil {
+		tx = tx.Where(durationExpr+" >= ?", *filter.MinDuration*60)
+	}
+	if filter.MaxDuration != nil {
+		tx = tx.Where(durationExpr+" <= ?", *filter.MaxDuration*60)
+	}
+	if filter.OnlyAvailable {
+		tx = tx.Where(availabilityExpr+" > 0", q.now)
+	}
+	if filter.SeasonID != nil {
+		tx = tx.Where("activities.season_id = ?", *filter.SeasonID)
+	}
+	return tx.Session(&gorm.Session{})
+}
+
+// facets counts the matches per category and per day.
+func (q *activityQuery) facets() (*ActivityFacets, error) {
+	facets := &ActivityFacets{Category: []CategoryFacet{}, Day: []DayFacet{}}
+	if err := q.scoped(false, true).
+		Select("activities.category AS value, COUNT(*) AS count").
+		Group("activities.category").
+		Order("count DESC, value ASC").
+		Scan(&facets.Category).Error; err != nil {
+		return nil, err
+	}
+	if err := q.scoped(true, false).
+		Select("activities.day_of_week AS value, COUNT(*) AS count").
+		Group("activities.day_of_week").
+		Order("value ASC").
+		Scan(&facets.Day).Error; err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
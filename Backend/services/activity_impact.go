@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// Strategies an admin picks to apply an activity update that affects enrolled members.
+const (
+	// ImpactReject refuses the update; it is also what happens when no strategy is given.
+	ImpactReject = "reject"
+	// ImpactKeepOverbooking applies the update and leaves the class above its capacity.
+	ImpactKeepOverbooking = "keep_overbooking"
+	// ImpactWaitlist applies the update and moves the most recent enrollments above the new
+	// capacity to the waitlist. Paid members keep their payment as credit for the seat they get
+	// back when promoted; it is refunded if they leave the waitlist instead.
+	ImpactWaitlist = "waitlist"
+)
+
+var (
+	ErrUpdateImpact    = errors.New("activity update affects enrolled members")
+	ErrInvalidStrategy = errors.New("unknown update strategy")
+)
+
+// ImpactedEnrollment is a member affected by an activity update.
+type ImpactedEnrollment struct {
+	EnrollmentID uint   `json:"enrollment_id"`
+	UserID       uint   `json:"user_id"`
+	UserName     string `json:"user_name"`
+	UserEmail    string `json:"user_email"`
+	Status       string `json:"status"`
+	// Paid is set when the enrollment already has an approved payment.
+	Paid bool `json:"paid"`
+	// ConflictsWith lists the activities that would overlap with the new schedule.
+	ConflictsWith []uint `json:"conflicts_with,omitempty"`
+}
+
+// UpdateImpact previews what an activity update does to the members holding a seat in it.
+type UpdateImpact struct {
+	EnrolledCount     int                  `json:"enrolled_count"`
+	PreviousCapacity  int                  `json:"previous_capacity"`
+	NewCapacity       int                  `json:"new_capacity"`
+	Excess            int                  `json:"excess"`
+	ExcessEnrollments []ImpactedEnrollment `json:"excess_enrollments"`
+	// PaidExcess counts the excess enrollments that were already paid.
+	PaidExcess      int                  `json:"paid_excess"`
+	ScheduleChanged bool                 `json:"schedule_changed"`
+	NewConflicts    []ImpactedEnrollment `json:"new_conflicts"`
+	// Strategy, Waitlisted and ReleasedHolds describe how an applied update handled the impact.
+	Strategy      string `json:"strategy,omitempty"`
+	Waitlisted    int    `json:"waitlisted"`
+	ReleasedHolds int    `json:"released_holds"`
+}
+
+// HasImpact reports whether the update needs an explicit strategy to be applied.
+func (i *UpdateImpact) HasImpact() bool {
+	return i.Excess > 0 || len(i.NewConflicts) > 0
+}
+
+// ValidImpactStrategy reports whether strategy is empty or one of the known strategies.
+func ValidImpactStrategy(strategy string) bool {
+	switch strategy {
+	case "", ImpactReject, ImpactKeepOverbooking, ImpactWaitlist:
+		return true
+	}
+	return false
+}
+
+// PreviewUpdate computes the impact of saving activity without changing anything.
+func (s *ActivityService) PreviewUpdate(activity *models.Activity) (*UpdateImpact, error) {
+	var previous models.Activity
+	if err := s.db.First(&previous, activity.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrActivityNotFound
+		}
+		return nil, err
+	}
+	if err := s.waitlist.expireStaleHolds(); err != nil {
+		return nil, err
+	}
+	return analyzeUpdate(s.db, &previous, activity)
+}
+
+// analyzeUpdate compares the stored activity with its updated version: seat holders beyond the
+// new capacity (most recent first) and members whose other enrollments would start overlapping.
+func analyzeUpdate(tx *gorm.DB, previous, updated *models.Activity) (*UpdateImpact, error) {
+	var holders []models.Enrollment
+	if err := seatHolders(tx.Preload("User")).
+		Where("activity_id = ?", previous.ID).
+		Order("created_at DESC, id DESC").
+		Find(&holders).Error; err != nil {
+		return nil, err
+	}
+
+	impact := &UpdateImpact{
+		EnrolledCount:     len(holders),
+		PreviousCapacity:  previous.Capacity,
+		NewCapacity:       updated.Capacity,
+		ExcessEnrollments: []ImpactedEnrollment{},
+		NewConflicts:      []ImpactedEnrollment{},
+		ScheduleChanged: previous.DayOfWeek != updated.DayOfWeek ||
+			previous.StartTime != updated.StartTime ||
+			previous.EndTime != updated.EndTime ||
+			!sameSeason(previous.SeasonID, updated.SeasonID),
+	}
+	if excess := len(holders) - updated.Capacity; excess > 0 {
+		impact.Excess = excess
+		excessIDs := make([]uint, 0, excess)
+		for _, enrollment := range holders[:excess] {
+			excessIDs = append(excessIDs, enrollment.ID)
+		}
+		var paidIDs []uint
+		if err := tx.Model(&models.Payment{}).
+			Where("enrollment_id IN ? AND status = ?", excessIDs, "aprobado").
+			Pluck("enrollment_id", &paidIDs).Error; err != nil {
+			return nil, err
+		}
+		paid := make(map[uint]bool, len(paidIDs))
+		for _, id := range paidIDs {
+			paid[id] = true
+		}
+		for _, enrollment := range holders[:excess] {
+			affected := impactedEnrollment(&enrollment)
+			affected.Paid = paid[enrollment.ID]
+			if affected.Paid {
+				impact.PaidExcess++
+			}
+			impact.ExcessEnrollments = append(impact.ExcessEnrollments, affected)
+		}
+	}
+	if !impact.ScheduleChanged || len(holders) == 0 {
+		return impact, nil
+	}
+
+	userIDs := make([]uint, 0, len(holders))
+	for _, enrollment := range holders {
+		userIDs = append(userIDs, enrollment.UserID)
+	}
+	var others []models.Enrollment
+	if err := seatHolders(tx.Preload("Activity")).
+		Where("user_id IN ? AND activity_id <> ?", userIDs, previous.ID).
+		Find(&others).Error; err != nil {
+		return nil, err
+	}
+
+	for _, enrollment := range holders {
+		var conflicts []uint
+		for _, other := range others {
+			if other.UserID != enrollment.UserID {
+				continue
+			}
+			after, err := activitiesOverlap(updated, &other.Activity)
+			if err != nil {
+				return nil, err
+			}
+			before, err := activitiesOverlap(previous, &other.Activity)
+			if err != nil {
+				return nil, err
+			}
+			if after && !before {
+				conflicts = append(conflicts, other.ActivityID)
+			}
+		}
+		if len(conflicts) > 0 {
+			affected := impactedEnrollment(&enrollment)
+			affected.ConflictsWith = conflicts
+			impact.NewConflicts = append(impact.NewConflicts, affected)
+		}
+	}
+	return impact, nil
+}
+
+// applyWaitlistStrategy moves the excess enrollments to the waitlist. Seats still waiting for
+// payment are released instead, since a waitlisted seat cannot keep an open checkout. Approved
+// payments stay with their enrollment: promoteWaitlist gives the seat back without a new checkout.
+func (s *ActivityService) applyWaitlistStrategy(tx *gorm.DB, impact *UpdateImpact) error {
+	now := time.Now()
+	for _, affected := range impact.ExcessEnrollments {
+		var enrollment models.Enrollment
+		if err := tx.First(&enrollment, affected.EnrollmentID).Error; err != nil {
+			return err
+		}
+
+		eventType := events.EnrollmentWaitlisted
+		updates := map[string]interface{}{"status": "lista_espera", "waitlisted_at": now, "schedule_conflict": false}
+		if enrollment.Status == "pendiente_pago" {
+			eventType = events.EnrollmentCancelled
+			updates = map[string]interface{}{"status": "cancelado", "hold_expires_at": nil, "schedule_conflict": false}
+			if err := tx.Model(&models.Payment{}).
+				Where("enrollment_id = ? AND status = ?", enrollment.ID, "pendiente").
+				Update("status", "cancelado").Error; err != nil {
+				return err
+			}
+			impact.ReleasedHolds++
+		} else {
+			impact.Waitlisted++
+		}
+		if err := tx.Model(&enrollment).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := s.events.Record(tx, enrollmentEvent(eventType, &enrollment)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func impactedEnrollment(enrollment *models.Enrollment) ImpactedEnrollment {
+	return ImpactedEnrollment{
+		EnrollmentID: enrollment.ID,
+		UserID:       enrollment.UserID,
+		UserName:     enrollment.User.Name,
+		UserEmail:    enrollment.User.Email,
+		Status:       enrollment.Status,
+	}
+}
+
+// activitiesOverlap reports whether two classes meet at the same time. Seasons do not overlap,
+// so activities of different seasons never do.
+func activitiesOverlap(a, b *models.Activity) (bool, error) {
+	if a.DayOfWeek != b.DayOfWeek {
+		return false, nil
+	}
+	if a.SeasonID != nil && b.SeasonID != nil && *a.SeasonID != *b.SeasonID {
+		return false, nil
+	}
+	return schedulesOverlap(a.StartTime, a.EndTime, b.StartTime, b.EndTime)
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+func TestWaitlistStrategyKeepsPaymentsAsCredit(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	activity := env.activity(t, "Yoga", 3, 1000)
+	first := env.enrollment(t, env.user(t), activity, "inscripto")
+	env.payment(t, first, "aprobado")
+	unpaid := env.enrollment(t, env.user(t), activity, "inscripto")
+	paid := env.enrollment(t, env.user(t), activity, "inscripto")
+	payment := env.payment(t, paid, "aprobado")
+
+	updated := *activity
+	updated.Capacity = 1
+	service := NewActivityService(env.db, env.bus, nil, env.waitlist, time.UTC)
+	var impact *UpdateImpact
+	err := env.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if impact, err = analyzeUpdate(tx, activity, &updated); err != nil {
+			return err
+		}
+		return service.applyWaitlistStrategy(tx, impact)
+	})
+	if err != nil {
+		t.Fatalf("apply waitlist strategy: %v", err)
+	}
+
+	if impact.Excess != 2 || impact.PaidExcess != 1 {
+		t.Fatalf("excess = %d, paid excess = %d; want 2 and 1", impact.Excess, impact.PaidExcess)
+	}
+	for _, affected := range impact.ExcessEnrollments {
+		if want := affected.EnrollmentID == paid.ID; affected.Paid != want {
+			t.Fatalf("enrollment %d paid = %t, want %t", affected.EnrollmentID, affected.Paid, want)
+		}
+	}
+	for _, id := range []uint{unpaid.ID, paid.ID} {
+		if status := env.status(t, &models.Enrollment{}, id); status != "lista_espera" {
+			t.Fatalf("enrollment %d status = %s, want lista_espera", id, status)
+		}
+	}
+	if status := env.status(t, &models.Payment{}, payment.ID); status != "aprobado" {
+		t.Fatalf("payment of a waitlisted member = %s, want aprobado", status)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidStatus          = errors.New("invalid activity status")
+	ErrInvalidTransition      = errors.New("activity status transition not allowed")
+	ErrActivityHasEnrollments = errors.New("activity has enrollments")
+	ErrActivityHasPayments    = errors.New("activity has payments")
+)
+
+// activityTransitions lists the statuses each status can move to. A draft is only reachable
+// again once the activity is archived, and nothing leaves the lifecycle except a purge.
+var activityTransitions = map[string][]string{
+	models.ActivityDraft:     {models.ActivityPublished, models.ActivityArchived},
+	models.ActivityPublished: {models.ActivityPaused, models.ActivityArchived},
+	models.ActivityPaused:    {models.ActivityPublished, models.ActivityArchived},
+	models.ActivityArchived:  {models.ActivityPublished, models.ActivityDraft},
+}
+
+// ValidActivityStatus reports whether status is a lifecycle status.
+func ValidActivityStatus(status string) bool {
+	_, ok := activityTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an activity in status from may move to status to.
+func CanTransition(from, to string) bool {
+	return containsString(activityTransitions[from], to)
+}
+
+// ChangeActivityStatus moves an activity along its lifecycle:
+//   - publishing opens it to enrollments and, coming back from a pause, tells the members with a
+//     seat that classes resume;
+//   - pausing keeps it listed and keeps every seat, but takes no enrollments and holds no classes;
+//   - archiving unlists it. Members holding a seat or waiting for one must be let go, so it fails
+//     with ErrActivityHasEnrollments unless cancelEnrollments, which cancels them (pending
+//     payments are cancelled and approved ones flagged for refund);
+//   - going back to draft needs an archived activity without enrollments.
+//
+// It returns the activity and how many enrollments were cancelled.
+func (s *ActivityService) ChangeActivityStatus(id uint, status string, cancelEnrollments bool, actor Actor) (*models.Activity, int, error) {
+	if !ValidActivityStatus(status) {
+		return nil, 0, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+
+	var activity *models.Activity
+	cancelled := 0
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		activity, cancelled, err = s.changeStatus(tx, id, status, cancelEnrollments, actor)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return activity, cancelled, s.populateAvailability(activity)
+}
+
+// changeStatus applies ChangeActivityStatus on tx, once status is known to be valid.
+func (s *ActivityService) changeStatus(tx *gorm.DB, id uint, status string, cancelEnrollments bool, actor Actor) (*models.Activity, int, error) {
+	var activity models.Activity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrActivityNotFound
+		}
+		return nil, 0, err
+	}
+	previous := activity
+	if !CanTransition(previous.Status, status) {
+		return nil, 0, fmt.Errorf("%w: %s → %s", ErrInvalidTransition, previous.Status, status)
+	}
+
+	if status == models.ActivityArchived || status == models.ActivityDraft {
+		var enrolled int64
+		if err := tx.Model(&models.Enrollment{}).
+			Where("activity_id = ? AND status IN ?", id, []string{"inscripto", "pendiente_pago", "lista_espera"}).
+			Count(&enrolled).Error; err != nil {
+			return nil, 0, err
+		}
+		if enrolled > 0 && (status == models.ActivityDraft || !cancelEnrollments) {
+			return nil, 0, fmt.Errorf("%w: %d members hold or wait for a seat", ErrActivityHasEnrollments, enrolled)
+		}
+	}
+
+	activity.SetStatus(status)
+	if err := tx.Model(&activity).Updates(map[string]interface{}{
+		"status":    activity.Status,
+		"is_active": activity.IsActive,
+	}).Error; err != nil {
+		return nil, 0, err
+	}
+	change := auditChange{
+		Action:     "activity.status_changed",
+		EntityType: AuditActivity,
+		EntityID:   id,
+		Before:     &previous,
+		After:      &activity,
+		Snapshot:   true,
+	}
+	if status == models.ActivityArchived {
+		change.Extra = map[string]FieldChange{"enrollments_cancelled": {After: cancelEnrollments}}
+	}
+	if err := recordAudit(tx, actor, change); err != nil {
+		return nil, 0, err
+	}
+
+	var evt events.Event
+	switch status {
+	case models.ActivityPublished:
+		evt = events.New(events.ActivityPublished)
+		evt.Data = map[string]interface{}{"previous_status": previous.Status}
+	case models.ActivityPaused:
+		evt = events.New(events.ActivityPaused)
+	case models.ActivityArchived:
+		// The event is recorded before cancelling so its recipients still hold their seats.
+		evt = events.New(events.ActivityDeactivated)
+		evt.Data = map[string]interface{}{"enrollments_cancelled": cancelEnrollments}
+	default:
+		evt = events.New(events.ActivityUpdated)
+	}
+	evt.ActivityID = id
+	if err := s.events.Record(tx, evt); err != nil {
+		return nil, 0, err
+	}
+	if status != models.ActivityArchived || !cancelEnrollments {
+		return &activity, 0, nil
+	}
+
+	cancelled, err := cancelActivityEnrollments(tx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &activity, cancelled, nil
+}
+
+// PurgeActivity deletes a draft or archived activity for good, with its cancelled sessions, its
+// eligibility rules, the prerequisite rules of other activities that require it and, when
+// deleteEnrollments, its enrollments and their reminders. Otherwise any enrollment, past ones
+// included, fails with ErrActivityHasEnrollments. Enrollments that have payments are never
+// deleted, so they fail with ErrActivityHasPayments: payments and invoices are accounting records.
+// The audit log keeps the history of the activity. It returns the deleted activity, whose image
+// the caller discards, and how many enrollments were deleted.
+func (s *ActivityService) PurgeActivity(id uint, deleteEnrollments bool, actor Actor) (*models.Activity, int, error) {
+	var activity models.Activity
+	var enrollmentIDs []uint
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrActivityNotFound
+			}
+			return err
+		}
+		if activity.Status != models.ActivityDraft && activity.Status != models.ActivityArchived {
+			return fmt.Errorf("%w: only draft or archived activities can be purged, this one is %s", ErrInvalidTransition, activity.Status)
+		}
+
+		if err := tx.Model(&models.Enrollment{}).Where("activity_id = ?", id).Pluck("id", &enrollmentIDs).Error; err != nil {
+			return err
+		}
+		if len(enrollmentIDs) > 0 {
+			var payments int64
+			if err := tx.Model(&models.Payment{}).Where("enrollment_id IN ?", enrollmentIDs).Count(&payments).Error; err != nil {
+				return err
+			}
+			if payments > 0 {
+				return fmt.Errorf("%w: %d payments reference its enrollments", ErrActivityHasPayments, payments)
+			}
+			if !deleteEnrollments {
+				return fmt.Errorf("%w: %d enrollments, active or past", ErrActivityHasEnrollments, len(enrollmentIDs))
+			}
+		}
+
+		if err := recordAudit(tx, actor, auditChange{
+			Action:     "activity.purged",
+			EntityType: AuditActivity,
+			EntityID:   id,
+			Before:     &activity,
+			Extra:      map[string]FieldChange{"enrollments_deleted": {After: len(enrollmentIDs)}},
+		}); err != nil {
+			return err
+		}
+		// Recorders may still need to load the activity, so the event goes before the deletes.
+		evt := events.New(events.ActivityPurged)
+		evt.ActivityID = id
+		evt.Data = map[string]interface{}{"title": activity.Title}
+		if err := s.events.Record(tx, evt); err != nil {
+			return err
+		}
+
+		if len(enrollmentIDs) > 0 {
+			if err := tx.Where("enrollment_id IN ?", enrollmentIDs).Delete(&models.ReminderLog{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("activity_id = ?", id).Delete(&models.Enrollment{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("activity_id = ?", id).Delete(&models.ActivityCancellation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Activity{}).Where("source_activity_id = ?", id).Update("source_activity_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("activity_id = ? OR (type = ? AND value = ?)", id, models.RulePrerequisite, strconv.FormatUint(uint64(id), 10)).
+			Delete(&models.EligibilityRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Activity{}, id).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &activity, len(enrollmentIDs), nil
+}
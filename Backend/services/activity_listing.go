@@ -0,0 +1,223 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm/clause"
+)
+
+// ActivitySortFields lists the fields activity listings can be sorted by. relevance is only valid
+// with a search text, and is the default order then.
+var ActivitySortFields = []string{"relevance", "title", "day", "start_time", "availability", "created_at"}
+
+// DefaultActivitySort orders listings like the weekly timetable.
+var DefaultActivitySort = []SortField{{Field: "day"}, {Field: "start_time"}}
+
+// availabilityExpr computes available_slots in SQL, like populateAvailability: capacity minus the
+// seats held by confirmed enrollments and unexpired payment holds, never below zero.
+const availabilityExpr = `GREATEST(activities.capacity - (SELECT COUNT(*) FROM enrollments seats
+	WHERE seats.activity_id = activities.id
+	AND (seats.status = 'inscripto' OR (seats.status = 'pendiente_pago' AND seats.hold_expires_at > ?))), 0)`
+
+// activitySortKey is the SQL expression of a sort field and how to read it from a row.
+type activitySortKey struct {
+	expr   string
+	vars   []interface{}
+	desc   bool
+	value  func(*models.Activity) interface{}
+	decode func(json.RawMessage) (interface{}, error)
+}
+
+// activitySortKeys builds the keys of sort. ranking holds the ids of the search hits, best first.
+func activitySortKeys(sort []SortField, now time.Time, ranking []uint) []activitySortKey {
+	keys := make([]activitySortKey, 0, len(sort))
+	for _, field := range sort {
+		var key activitySortKey
+		switch field.Field {
+		case "relevance":
+			key = relevanceSortKey(ranking)
+		case "title":
+			key = activitySortKey{expr: "activities.title", value: func(a *models.Activity) interface{} { return a.Title }, decode: decodeCursorValue[string]}
+		case "day":
+			key = activitySortKey{expr: "activities.day_of_week", value: func(a *models.Activity) interface{} { return a.DayOfWeek }, decode: decodeCursorValue[int]}
+		case "start_time":
+			key = activitySortKey{expr: "activities.start_time", value: func(a *models.Activity) interface{} { return a.StartTime }, decode: decodeCursorValue[string]}
+		case "availability":
+			key = activitySortKey{expr: availabilityExpr, vars: []interface{}{now}, value: func(a *models.Activity) interface{} { return a.AvailableSlots }, decode: decodeCursorValue[int]}
+		case "created_at":
+			key = activitySortKey{expr: "activities.created_at", value: func(a *models.Activity) interface{} { return a.CreatedAt }, decode: decodeCursorValue[time.Time]}
+		}
+		key.desc = field.Desc
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// relevanceSortKey orders by position in the ranking: FIELD returns the 1-based index of the id.
+func relevanceSortKey(ranking []uint) activitySortKey {
+	positions := make(map[uint]int, len(ranking))
+	placeholders := make([]string, 0, len(ranking))
+	vars := make([]interface{}, 0, len(ranking))
+	for i, id := range ranking {
+		positions[id] = i + 1
+		placeholders = append(placeholders, "?")
+		vars = append(vars, id)
+	}
+	expr := "0"
+	if len(ranking) > 0 {
+		expr = "FIELD(activities.id, " + strings.Join(placeholders, ", ") + ")"
+	}
+	return activitySortKey{
+		expr:   expr,
+		vars:   vars,
+		value:  func(a *models.Activity) interface{} { return positions[a.ID] },
+		decode: decodeCursorValue[int],
+	}
+}
+
+func decodeCursorValue[T any](raw json.RawMessage) (interface{}, error) {
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return value, nil
+}
+
+// paginateActivities runs query one page at a time. Rows are ordered by the requested fields and
+// then by id, which keeps the order total so cursors never skip or repeat a row.
+func (s *ActivityService) paginateActivities(query *activityQuery, page PageRequest) ([]models.Activity, *PageInfo, error) {
+	for _, field := range page.Sort {
+		if field.Field == "relevance" && !query.ranked {
+			return nil, nil, fmt.Errorf("%w: relevance needs a search text", ErrInvalidSort)
+		}
+	}
+	if len(page.Sort) == 0 {
+		page.Sort = DefaultActivitySort
+		if query.ranked {
+			page.Sort = []SortField{{Field: "relevance"}}
+		}
+	}
+	if page.Limit <= 0 {
+		page.Limit = DefaultPageLimit
+	}
+	if page.Limit > MaxPageLimit {
+		page.Limit = MaxPageLimit
+	}
+
+	// Availability is evaluated at a single instant for filtering, ordering, the cursor and the page.
+	now := query.now
+	keys := activitySortKeys(page.Sort, now, query.ranking)
+	base := query.scoped(true, true)
+	info := &PageInfo{Limit: page.Limit, Sort: formatSort(page.Sort)}
+	if err := base.Count(&info.Total).Error; err != nil {
+		return nil, nil, err
+	}
+	facets, err := query.facets()
+	if err != nil {
+		return nil, nil, err
+	}
+	info.Facets = facets
+
+	paged := base.Order(activityOrderBy(keys)).Limit(page.Limit + 1)
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Cursor, page.Sort)
+		if err != nil {
+			return nil, nil, err
+		}
+		condition, err := keysetCondition(keys, cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		paged = paged.Where(condition)
+	} else {
+		offset := page.Offset
+		info.Offset = &offset
+		paged = paged.Offset(page.Offset)
+	}
+
+	var activities []models.Activity
+	if err := paged.Find(&activities).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(activities) > page.Limit {
+		activities = activities[:page.Limit]
+		info.HasMore = true
+	}
+	if err := s.populateAvailabilityAt(now, slicePointers(activities)...); err != nil {
+		return nil, nil, err
+	}
+	if err := populateEligibilityRules(s.db, slicePointers(activities)...); err != nil {
+		return nil, nil, err
+	}
+
+	if info.HasMore {
+		last := &activities[len(activities)-1]
+		values := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, key.value(last))
+		}
+		cursor, err := encodeCursor(page.Sort, values, last.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		info.NextCursor = cursor
+	}
+	return activities, info, nil
+}
+
+func activityOrderBy(keys []activitySortKey) clause.OrderBy {
+	var sql strings.Builder
+	var vars []interface{}
+	for _, key := range keys {
+		sql.WriteString(key.expr)
+		if key.desc {
+			sql.WriteString(" DESC, ")
+		} else {
+			sql.WriteString(" ASC, ")
+		}
+		vars = append(vars, key.vars...)
+	}
+	sql.WriteString("activities.id ASC")
+	return clause.OrderBy{Expression: clause.Expr{SQL: sql.String(), Vars: vars, WithoutParentheses: true}}
+}
+
+// keysetCondition selects the rows after the cursor in the order given by keys:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > cursor id),
+// with "<" for descending keys.
+func keysetCondition(keys []activitySortKey, cursor *pageCursor) (clause.Expr, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		value, err := key.decode(cursor.Values[i])
+		if err != nil {
+			return clause.Expr{}, err
+		}
+		values[i] = value
+	}
+
+	var branches []string
+	var vars []interface{}
+	for i := 0; i <= len(keys); i++ {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, "("+keys[j].expr+") = ?")
+			vars = append(append(vars, keys[j].vars...), values[j])
+		}
+		if i < len(keys) {
+			operator := " > ?"
+			if keys[i].desc {
+				operator = " < ?"
+			}
+			terms = append(terms, "("+keys[i].expr+")"+operator)
+			vars = append(append(vars, keys[i].vars...), values[i])
+		} else {
+			terms = append(terms, "activities.id > ?")
+			vars = append(vars, cursor.ID)
+		}
+		branches = append(branches, "("+strings.Join(terms, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(branches, " OR ") + ")", Vars: vars}, nil
+}
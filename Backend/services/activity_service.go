@@ -2,62 +2,121 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/alesio/gestion-actividades-deportivas/events"
 	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/search"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ActivityService encapsulates interactions with the activities table.
+// ActivityService manages the activities. location is the gym's time zone, where season dates
+// and member ages are read.
 type ActivityService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	events   *events.Bus
+	search   search.Index
+	waitlist *Waitlist
+	location *time.Location
 }
 
-func NewActivityService(db *gorm.DB) *ActivityService {
-	return &ActivityService{db: db}
+func NewActivityService(db *gorm.DB, bus *events.Bus, index search.Index, waitlist *Waitlist, location *time.Location) *ActivityService {
+	return &ActivityService{db: db, events: bus, search: index, waitlist: waitlist, location: location}
 }
 
-// ActivityFilter captures optional search parameters for listing activities.
+// ActivityFilter captures optional search parameters for listing activities. Empty fields match
+// everything; list fields match any of their values.
 type ActivityFilter struct {
-	Query    string
-	Category string
-	Day      *int
+	Query      string
+	Categories []string
+	Days       []int
+	// Instructor matches part of the instructor's name, ignoring case.
+	Instructor string
+	// StartAfter and StartBefore bound the start time ("HH:MM"): from StartAfter inclusive up to
+	// StartBefore exclusive.
+	StartAfter  string
+	StartBefore string
+	// MinDuration and MaxDuration bound the length of the class in minutes, inclusive.
+	MinDuration *int
+	MaxDuration *int
+	// OnlyAvailable keeps the activities with at least one free seat.
+	OnlyAvailable bool
+	// SeasonID keeps the activities of a season. Without it, public listings show the season in
+	// progress and the activities held all year round.
+	SeasonID *uint
 }
 
-// AdminActivityFilter extends ActivityFilter to allow filtering by status.
+// AdminActivityFilter extends ActivityFilter to allow filtering by status. IsActive selects
+// listed (published or paused) or unlisted activities; Statuses and IDs match any of their values.
 type AdminActivityFilter struct {
 	ActivityFilter
 	IsActive *bool
+	Statuses []string
+	IDs      []uint
 }
 
-func (s *ActivityService) ListActivities(filter ActivityFilter) ([]models.Activity, error) {
-	query := s.db.Model(&models.Activity{}).Where("is_active = ?", true)
-	query = applyActivityFilters(query, filter)
+// Empty reports whether the filter matches every activity.
+func (f AdminActivityFilter) Empty() bool {
+	return f.Query == "" && len(f.Categories) == 0 && len(f.Days) == 0 && f.Instructor == "" &&
+		f.StartAfter == "" && f.StartBefore == "" && f.MinDuration == nil && f.MaxDuration == nil &&
+		!f.OnlyAvailable && f.SeasonID == nil && f.IsActive == nil && len(f.Statuses) == 0 && len(f.IDs) == 0
+}
 
-	var activities []models.Activity
-	if err := query.Find(&activities).Error; err != nil {
-		return nil, err
+// ListActivities returns one page of the active activities matching filter. With userID set, each
+// activity says whether the member is eligible for it.
+func (s *ActivityService) ListActivities(filter ActivityFilter, page PageRequest, userID *uint) ([]models.Activity, *PageInfo, error) {
+	active := true
+	found, err := s.searchActivities(s.listedActivities(filter), filter, &active)
+	if err != nil {
+		return nil, nil, err
 	}
-	if err := s.populateAvailability(slicePointers(activities)...); err != nil {
-		return nil, err
+	activities, info, err := s.paginateActivities(found, page)
+	if err != nil {
+		return nil, nil, err
 	}
-	return activities, nil
+	if userID != nil {
+		if err := annotateEligibility(s.db, *userID, found.now, slicePointers(activities)...); err != nil {
+			return nil, nil, err
+		}
+	}
+	return activities, info, nil
 }
 
-func (s *ActivityService) ListActivitiesAdmin(filter AdminActivityFilter) ([]models.Activity, error) {
-	query := s.db.Model(&models.Activity{})
+// listedActivities is the base query of the public listings: active activities of the season
+// asked for by filter or, by default, in effect today.
+func (s *ActivityService) listedActivities(filter ActivityFilter) *gorm.DB {
+	query := s.db.Model(&models.Activity{}).Where("is_active = ?", true)
+	if filter.SeasonID == nil {
+		query = inSeasonInEffect(query, gymDate(time.Now(), s.location))
+	}
+	return query
+}
+
+// ListActivitiesAdmin returns one page of all activities, active or not, matching filter.
+func (s *ActivityService) ListActivitiesAdmin(filter AdminActivityFilter, page PageRequest) ([]models.Activity, *PageInfo, error) {
+	found, err := s.searchAdminActivities(s.db, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.paginateActivities(found, page)
+}
+
+// searchAdminActivities returns the query of an admin listing on db.
+func (s *ActivityService) searchAdminActivities(db *gorm.DB, filter AdminActivityFilter) (*activityQuery, error) {
+	query := db.Model(&models.Activity{})
 	if filter.IsActive != nil {
 		query = query.Where("is_active = ?", *filter.IsActive)
 	}
-	query = applyActivityFilters(query, filter.ActivityFilter)
-
-	var activities []models.Activity
-	if err := query.Find(&activities).Error; err != nil {
-		return nil, err
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
 	}
-	if err := s.populateAvailability(slicePointers(activities)...); err != nil {
-		return nil, err
+	if len(filter.IDs) > 0 {
+		query = query.Where("activities.id IN ?", filter.IDs)
 	}
-	return activities, nil
+	return s.searchActivities(query, filter.ActivityFilter, filter.IsActive)
 }
 
 func (s *ActivityService) GetActivityByID(id uint) (*models.Activity, error) {
@@ -71,11 +130,76 @@ func (s *ActivityService) GetActivityByID(id uint) (*models.Activity, error) {
 	if err := s.populateAvailability(&activity); err != nil {
 		return nil, err
 	}
+	if err := populateEligibilityRules(s.db, &activity); err != nil {
+		return nil, err
+	}
 	return &activity, nil
 }
 
-func (s *ActivityService) CreateActivity(activity *models.Activity) error {
-	if err := s.db.Create(activity).Error; err != nil {
+// GetAvailability loads the given activities with their availability computed. Without ids it
+// returns every active activity.
+func (s *ActivityService) GetAvailability(ids []uint) ([]models.Activity, error) {
+	query := s.db.Model(&models.Activity{})
+	if len(ids) == 0 {
+		query = query.Where("is_active = ?", true)
+	} else {
+		query = query.Where("id IN ?", ids)
+	}
+
+	var activities []models.Activity
+	if err := query.Order("id ASC").Find(&activities).Error; err != nil {
+		return nil, err
+	}
+	if err := s.populateAvailability(slicePointers(activities)...); err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
+
+// CreateActivity stores a new activity, published unless its Status is a draft. Its category is
+// taken from CategoryID or, when unset, from the Category slug; it fails with ErrCategoryNotFound
+// when the catalogue has no such category. A SeasonID must name a season that has not ended.
+// EligibilityRules, if any, are stored with it.
+func (s *ActivityService) CreateActivity(activity *models.Activity, actor Actor) error {
+	switch activity.Status {
+	case "":
+		activity.SetStatus(models.ActivityPublished)
+	case models.ActivityDraft, models.ActivityPublished:
+		activity.SetStatus(activity.Status)
+	default:
+		return fmt.Errorf("%w: new activities are %s or %s", ErrInvalidStatus, models.ActivityDraft, models.ActivityPublished)
+	}
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := assignCategory(tx, activity); err != nil {
+			return err
+		}
+		if err := assignSeason(tx, activity, nil, gymDate(time.Now(), s.location)); err != nil {
+			return err
+		}
+		if err := tx.Create(activity).Error; err != nil {
+			return err
+		}
+		if len(activity.EligibilityRules) > 0 {
+			rules, err := replaceEligibilityRules(tx, activity.ID, activity.EligibilityRules)
+			if err != nil {
+				return err
+			}
+			activity.EligibilityRules = rules
+		}
+		if err := recordAudit(tx, actor, auditChange{
+			Action:     "activity.created",
+			EntityType: AuditActivity,
+			EntityID:   activity.ID,
+			After:      activity,
+			Snapshot:   true,
+		}); err != nil {
+			return err
+		}
+		evt := events.New(events.ActivityCreated)
+		evt.ActivityID = activity.ID
+		return s.events.Record(tx, evt)
+	})
+	if err != nil {
 		return err
 	}
 	activity.EnrolledCount = 0
@@ -83,41 +207,175 @@ func (s *ActivityService) CreateActivity(activity *models.Activity) error {
 	return nil
 }
 
-func (s *ActivityService) UpdateActivity(activity *models.Activity) error {
-	if err := s.db.Save(activity).Error; err != nil {
-		return err
+// UpdateActivity saves an activity. When the change leaves seat holders above the new capacity or
+// creates schedule conflicts for them, it is only applied with an explicit strategy (see the
+// Impact* constants); otherwise it fails with ErrUpdateImpact and returns the impact preview.
+// Moving the activity to another day or time notifies the members holding a seat and
+// re-evaluates their schedule conflicts; deactivating it notifies them as well. Seats freed by
+// the change are offered to the waitlist. Every saved change is a new version in the audit log.
+func (s *ActivityService) UpdateActivity(activity *models.Activity, strategy string, actor Actor) (*UpdateImpact, error) {
+	return s.updateActivity(activity, strategy, actor, auditChange{Action: "activity.updated"})
+}
+
+// RestoreActivityVersion puts back the fields an activity had in a version of its audit log. It
+// is an update like any other, subject to the same impact rules, and becomes a new version. The
+// image is kept, as the variants of an old upload may no longer exist, and so is the status,
+// which only changes through ChangeActivityStatus. The season is restored under the rules of an
+// update: an activity cannot go back to a season that has ended since.
+func (s *ActivityService) RestoreActivityVersion(activityID uint, version int, strategy string, actor Actor) (*models.Activity, *UpdateImpact, error) {
+	activity, err := s.findActivity(activityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	saved, err := activityVersion(s.db, activityID, version)
+	if err != nil {
+		return nil, nil, err
 	}
-	return s.populateAvailability(activity)
+
+	activity.Title = saved.Title
+	activity.Description = saved.Description
+	activity.Category = saved.Category
+	activity.CategoryID = saved.CategoryID
+	activity.DayOfWeek = saved.DayOfWeek
+	activity.StartTime = saved.StartTime
+	activity.EndTime = saved.EndTime
+	activity.Capacity = saved.Capacity
+	activity.Instructor = saved.Instructor
+	activity.Location = saved.Location
+	activity.PriceCents = saved.PriceCents
+	activity.SeasonID = saved.SeasonID
+	impact, err := s.updateActivity(activity, strategy, actor, auditChange{
+		Action: "activity.restored",
+		Extra:  map[string]FieldChange{"restored_version": {After: version}},
+	})
+	return activity, impact, err
 }
 
-func (s *ActivityService) DeleteActivity(id uint) error {
-	result := s.db.Model(&models.Activity{}).Where("id = ?", id).Update("is_active", false)
-	if result.Error != nil {
-		return result.Error
+// updateActivity implements UpdateActivity, recording audit as the audit entry of the change.
+func (s *ActivityService) updateActivity(activity *models.Activity, strategy string, actor Actor, audit auditChange) (*UpdateImpact, error) {
+	if !ValidImpactStrategy(strategy) {
+		return nil, ErrInvalidStrategy
 	}
-	if result.RowsAffected == 0 {
-		return ErrActivityNotFound
+	if err := s.waitlist.expireStaleHolds(); err != nil {
+		return nil, err
 	}
-	return nil
+
+	var impact *UpdateImpact
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		impact, err = s.applyUpdate(tx, activity, strategy, actor, audit)
+		return err
+	})
+	if err != nil {
+		return impact, err
+	}
+	return impact, s.populateAvailability(activity)
 }
 
-func applyActivityFilters(query *gorm.DB, filter ActivityFilter) *gorm.DB {
-	if filter.Query != "" {
-		like := "%" + filter.Query + "%"
-		query = query.Where("title LIKE ? OR description LIKE ?", like, like)
+// applyUpdate saves activity on tx: it checks the impact of the change against strategy, records
+// audit as its audit entry and notifies whoever the change concerns.
+func (s *ActivityService) applyUpdate(tx *gorm.DB, activity *models.Activity, strategy string, actor Actor, audit auditChange) (*UpdateImpact, error) {
+	var previous models.Activity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&previous, activity.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrActivityNotFound
+		}
+		return nil, err
 	}
 
-	if filter.Category != "" {
-		query = query.Where("category = ?", filter.Category)
+	impact, err := analyzeUpdate(tx, &previous, activity)
+	if err != nil {
+		return nil, err
+	}
+	if impact.HasImpact() {
+		if strategy == "" || strategy == ImpactReject {
+			return impact, ErrUpdateImpact
+		}
+		impact.Strategy = strategy
 	}
 
-	if filter.Day != nil {
-		query = query.Where("day_of_week = ?", *filter.Day)
+	if err := assignCategory(tx, activity); err != nil {
+		return nil, err
 	}
-	return query
+	if err := assignSeason(tx, activity, previous.SeasonID, gymDate(time.Now(), s.location)); err != nil {
+		return nil, err
+	}
+	if previous.SeasonID != nil && activity.SeasonID == nil {
+		if err := ensurePrerequisitesKeepSeason(tx, activity.ID); err != nil {
+			return nil, err
+		}
+	}
+	// An image URL set by hand replaces the uploaded image; its blobs are left to the caller.
+	if activity.ImageURL != previous.ImageURL {
+		activity.ImageKey = ""
+		activity.ThumbnailURL = ""
+	}
+	if err := tx.Save(activity).Error; err != nil {
+		return nil, err
+	}
+	audit.EntityType, audit.EntityID = AuditActivity, activity.ID
+	audit.Before, audit.After, audit.Snapshot = &previous, activity, true
+	if err := recordAudit(tx, actor, audit); err != nil {
+		return nil, err
+	}
+	updated := events.New(events.ActivityUpdated)
+	updated.ActivityID = activity.ID
+	if err := s.events.Record(tx, updated); err != nil {
+		return nil, err
+	}
+	if impact.HasImpact() && strategy == ImpactWaitlist {
+		if err := s.applyWaitlistStrategy(tx, impact); err != nil {
+			return nil, err
+		}
+	}
+
+	if impact.ScheduleChanged {
+		var userIDs []uint
+		if err := seatHolders(tx.Model(&models.Enrollment{})).
+			Where("activity_id = ?", activity.ID).
+			Pluck("user_id", &userIDs).Error; err != nil {
+			return nil, err
+		}
+		if err := refreshScheduleConflicts(tx, userIDs); err != nil {
+			return nil, err
+		}
+
+		evt := events.New(events.ActivityRescheduled)
+		evt.ActivityID = activity.ID
+		evt.Data = map[string]interface{}{
+			"previous_day_of_week": previous.DayOfWeek,
+			"previous_start_time":  previous.StartTime,
+			"previous_end_time":    previous.EndTime,
+		}
+		if err := s.events.Record(tx, evt); err != nil {
+			return nil, err
+		}
+	}
+
+	return impact, s.waitlist.promote(tx, activity.ID)
+}
+
+// cancelActivityEnrollments cancels every seat of an activity, and its place on the waitlist,
+// settling their payments as cancelEnrollments does.
+func cancelActivityEnrollments(tx *gorm.DB, activityID uint) (int, error) {
+	var enrollmentIDs []uint
+	if err := tx.Model(&models.Enrollment{}).
+		Where("activity_id = ? AND status IN ?", activityID, []string{"inscripto", "pendiente_pago", "lista_espera"}).
+		Pluck("id", &enrollmentIDs).Error; err != nil {
+		return 0, err
+	}
+	if err := cancelEnrollments(tx, enrollmentIDs); err != nil {
+		return 0, err
+	}
+	return len(enrollmentIDs), nil
 }
 
 func (s *ActivityService) populateAvailability(activities ...*models.Activity) error {
+	return s.populateAvailabilityAt(time.Now(), activities...)
+}
+
+// populateAvailabilityAt computes the availability with the payment holds evaluated at now.
+func (s *ActivityService) populateAvailabilityAt(now time.Time, activities ...*models.Activity) error {
 	idSet := make([]uint, 0, len(activities))
 	for _, activity := range activities {
 		if activity == nil || activity.ID == 0 {
@@ -134,9 +392,9 @@ func (s *ActivityService) populateAvailability(activities ...*models.Activity) e
 		Count      int64
 	}
 	var counters []counter
-	if err := s.db.Model(&models.Enrollment{}).
+	if err := seatHoldersAt(s.db.Model(&models.Enrollment{}), now).
 		Select("activity_id, COUNT(*) as count").
-		Where("activity_id IN ? AND status = ?", idSet, "inscripto").
+		Where("activity_id IN ?", idSet).
 		Group("activity_id").
 		Find(&counters).Error; err != nil {
 		return err
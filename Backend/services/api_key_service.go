@@ -0,0 +1,175 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"gorm.io/gorm"
+)
+
+const apiKeyPrefix = "gad_"
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyExpired  = errors.New("api key expired")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidScope   = errors.New("invalid scope")
+
+	// lastUsedResolution throttles last_used_at writes so busy integrations don't update on every call.
+	lastUsedResolution = time.Minute
+)
+
+// APIKeyView is the public representation of a key, including its scopes.
+type APIKeyView struct {
+	models.APIKey
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyService manages scoped keys for machine-to-machine integrations.
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// ValidScopes lists the scopes that can be granted: the delegable RBAC permissions.
+func ValidScopes() []string {
+	return security.DelegablePermissions()
+}
+
+// CreateKey stores a new key and returns it together with the plain text secret,
+// which is never retrievable again.
+func (s *APIKeyService) CreateKey(name string, scopes []string, expiresAt *time.Time, createdByID uint) (*APIKeyView, string, error) {
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	plain := apiKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := models.APIKey{
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     hashAPIKey(plain),
+		Scopes:      strings.Join(normalized, " "),
+		ExpiresAt:   expiresAt,
+		CreatedByID: createdByID,
+	}
+	if err := s.db.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+	return toAPIKeyView(key), plain, nil
+}
+
+func (s *APIKeyService) ListKeys() ([]APIKeyView, error) {
+	var keys []models.APIKey
+	if err := s.db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	views := make([]APIKeyView, 0, len(keys))
+	for _, key := range keys {
+		views = append(views, *toAPIKeyView(key))
+	}
+	return views, nil
+}
+
+// RevokeKey disables a key permanently; the row is kept for auditing.
+func (s *APIKeyService) RevokeKey(id uint) error {
+	result := s.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// IsAPIKey reports whether a credential looks like one of our keys rather than a JWT.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// Authenticate resolves a plain text key and records its use.
+func (s *APIKeyService) Authenticate(plain string) (*APIKeyView, error) {
+	parts := strings.SplitN(strings.TrimPrefix(plain, apiKeyPrefix), "_", 2)
+	if !IsAPIKey(plain) || len(parts) != 2 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := s.db.Where("prefix = ?", parts[0]).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(plain))) != 1 || key.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.db.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return toAPIKeyView(key), nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !security.IsDelegable(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func toAPIKeyView(key models.APIKey) *APIKeyView {
+	return &APIKeyView{APIKey: key, Scopes: strings.Fields(key.Scopes)}
+}
+
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
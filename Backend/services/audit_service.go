@@ -0,0 +1,311 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// Audited entity types.
+const (
+	AuditActivity   = "activity"
+	AuditEnrollment = "enrollment"
+	AuditUser       = "user"
+	AuditSeason     = "season"
+)
+
+// auditIgnoredFields are serialized fields that are computed or bookkeeping, never a change.
+// Eligibility rules are audited on their own when they change.
+var auditIgnoredFields = []string{"created_at", "updated_at", "available_slots", "enrolled_count", "payment", "eligibility_rules", "eligibility"}
+
+// Actor identifies who makes a change: a user or, for integrations, an API key.
+type Actor struct {
+	UserID   *uint
+	APIKeyID *uint
+}
+
+func UserActor(id uint) Actor {
+	return Actor{UserID: &id}
+}
+
+func APIKeyActor(id uint) Actor {
+	return Actor{APIKeyID: &id}
+}
+
+// isUser reports whether the actor is the user with the given id.
+func (a Actor) isUser(id uint) bool {
+	return a.UserID != nil && *a.UserID == id
+}
+
+// FieldChange is the value of a field before and after a change; nil when it did not exist.
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditChange describes a change for the audit log. Before and After are the entity as it
+// serializes to JSON, nil when it did not exist; only the fields that differ are kept. Extra adds
+// changes that are not fields of the entity, such as a cancelled session date.
+type auditChange struct {
+	Action     string
+	EntityType string
+	EntityID   uint
+	Before     interface{}
+	After      interface{}
+	Extra      map[string]FieldChange
+	// Snapshot keeps After as a new version of the entity.
+	Snapshot bool
+}
+
+// recordAudit appends an entry to the audit log. Call it on the transaction of the change.
+func recordAudit(tx *gorm.DB, actor Actor, change auditChange) error {
+	before, err := auditFields(change.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditFields(change.After)
+	if err != nil {
+		return err
+	}
+
+	changes := diffFields(before, after)
+	for key, value := range change.Extra {
+		changes[key] = value
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	entry := models.AuditLog{
+		ActorUserID:   actor.UserID,
+		ActorAPIKeyID: actor.APIKeyID,
+		Action:        change.Action,
+		EntityType:    change.EntityType,
+		EntityID:      change.EntityID,
+		Changes:       string(encoded),
+	}
+	if change.Snapshot {
+		var last int
+		if err := tx.Model(&models.AuditLog{}).
+			Where("entity_type = ? AND entity_id = ?", change.EntityType, change.EntityID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+		snapshot, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		entry.Version = last + 1
+		entry.Snapshot = string(snapshot)
+	}
+	return tx.Create(&entry).Error
+}
+
+// diffFields lists the fields whose value differs between two flattened entities.
+func diffFields(before, after map[string]interface{}) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changes[key] = FieldChange{Before: before[key], After: value}
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			changes[key] = FieldChange{Before: value}
+		}
+	}
+	return changes
+}
+
+// auditFields flattens an entity to its JSON fields, without the ignored ones.
+func auditFields(entity interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if entity == nil || reflect.ValueOf(entity).Kind() == reflect.Ptr && reflect.ValueOf(entity).IsNil() {
+		return fields, nil
+	}
+	encoded, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("audit: %T does not serialize to an object: %w", entity, err)
+	}
+	for _, key := range auditIgnoredFields {
+		delete(fields, key)
+	}
+	return fields, nil
+}
+
+// AuditFilter narrows the audit log. Empty fields match everything.
+type AuditFilter struct {
+	EntityType  string
+	EntityID    *uint
+	ActorUserID *uint
+	Action      string
+	// From and To bound the creation time: From inclusive, To exclusive.
+	From *time.Time
+	To   *time.Time
+}
+
+// AuditEntry is an audit log entry with its changes decoded and the name of the acting user.
+type AuditEntry struct {
+	models.AuditLog
+	ActorName string                 `json:"actor_name,omitempty"`
+	Changes   map[string]FieldChange `json:"changes"`
+	Snapshot  json.RawMessage        `json:"snapshot,omitempty"`
+}
+
+// AuditService reads the audit log, which services append to with recordAudit.
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// ListAudit returns one page of the entries matching filter, newest first. The order is fixed,
+// so page.Sort must be empty.
+func (s *AuditService) ListAudit(filter AuditFilter, page PageRequest) ([]AuditEntry, *PageInfo, error) {
+	if len(page.Sort) > 0 {
+		return nil, nil, fmt.Errorf("%w: the audit log is always sorted newest first", ErrInvalidSort)
+	}
+	if page.Limit <= 0 {
+		page.Limit = DefaultPageLimit
+	}
+	if page.Limit > MaxPageLimit {
+		page.Limit = MaxPageLimit
+	}
+
+	query := s.db.Model(&models.AuditLog{})
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		query = query.Where("entity_id = ?", *filter.EntityID)
+	}
+	if filter.ActorUserID != nil {
+		query = query.Where("actor_user_id = ?", *filter.ActorUserID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	query = query.Session(&gorm.Session{})
+
+	info := &PageInfo{Limit: page.Limit, Sort: []string{"-id"}}
+	if err := query.Count(&info.Total).Error; err != nil {
+		return nil, nil, err
+	}
+	paged := query.Order("id DESC").Limit(page.Limit + 1)
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Cursor, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		paged = paged.Where("id < ?", cursor.ID)
+	} else {
+		offset := page.Offset
+		info.Offset = &offset
+		paged = paged.Offset(page.Offset)
+	}
+
+	var logs []models.AuditLog
+	if err := paged.Find(&logs).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(logs) > page.Limit {
+		logs = logs[:page.Limit]
+		info.HasMore = true
+		cursor, err := encodeCursor(nil, nil, logs[len(logs)-1].ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		info.NextCursor = cursor
+	}
+	entries, err := s.decode(logs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, info, nil
+}
+
+// ListActivityVersions returns the versions of an activity, newest first, with their snapshots.
+func (s *AuditService) ListActivityVersions(activityID uint) ([]AuditEntry, error) {
+	var logs []models.AuditLog
+	if err := s.db.Where("entity_type = ? AND entity_id = ? AND version > 0", AuditActivity, activityID).
+		Order("version DESC").
+		Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return s.decode(logs)
+}
+
+func (s *AuditService) decode(logs []models.AuditLog) ([]AuditEntry, error) {
+	var userIDs []uint
+	for _, log := range logs {
+		if log.ActorUserID != nil {
+			userIDs = append(userIDs, *log.ActorUserID)
+		}
+	}
+	names := make(map[uint]string)
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := s.db.Select("id", "name").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			names[user.ID] = user.Name
+		}
+	}
+
+	entries := make([]AuditEntry, 0, len(logs))
+	for _, log := range logs {
+		entry := AuditEntry{AuditLog: log, Changes: map[string]FieldChange{}}
+		if log.ActorUserID != nil {
+			entry.ActorName = names[*log.ActorUserID]
+		}
+		if log.Changes != "" {
+			if err := json.Unmarshal([]byte(log.Changes), &entry.Changes); err != nil {
+				return nil, err
+			}
+		}
+		if log.Snapshot != "" {
+			entry.Snapshot = json.RawMessage(log.Snapshot)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// activityVersion loads the activity as it was saved in version.
+func activityVersion(db *gorm.DB, activityID uint, version int) (*models.Activity, error) {
+	var log models.AuditLog
+	err := db.Where("entity_type = ? AND entity_id = ? AND version = ?", AuditActivity, activityID, version).
+		First(&log).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && log.Snapshot == "") {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var activity models.Activity
+	if err := json.Unmarshal([]byte(log.Snapshot), &activity); err != nil {
+		return nil, err
+	}
+	return &activity, nil
+}
@@ -29,12 +29,14 @@ var (
 
 // AuthService coordinates authentication and token management logic.
 type AuthService struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db   *gorm.DB
+	cfg  *config.Config
+	keys *security.KeySet
 }
 
-func NewAuthService(db *gorm.DB, cfg *config.Config) *AuthService {
-	return &AuthService{db: db, cfg: cfg}
+// NewAuthService wires the service. keys may be nil when cfg.JWTAlgorithm is HS256.
+func NewAuthService(db *gorm.DB, cfg *config.Config, keys *security.KeySet) *AuthService {
+	return &AuthService{db: db, cfg: cfg, keys: keys}
 }
 
 // Authenticate validates the provided credentials and returns the matching user when valid.
@@ -67,23 +69,46 @@ func (s *AuthService) GenerateJWT(user *models.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if s.cfg.JWTAlgorithm == "HS256" {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(s.cfg.JWTSecret))
+	}
+
+	if s.keys == nil {
+		return "", security.ErrNoSigningKey
+	}
+	key, err := s.keys.Active()
+	if err != nil {
+		return "", err
+	}
+	method, err := signingMethodFor(key.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", err
 	}
 	return signed, nil
 }
 
-// ValidateJWT parses and validates the token string against the configured secret.
+// JWKS exposes the public signing keys so other services can verify our tokens.
+func (s *AuthService) JWKS() security.JWKS {
+	if s.keys == nil {
+		return security.JWKS{Keys: []security.JWK{}}
+	}
+	return s.keys.JWKS()
+}
+
+// ValidateJWT parses and validates the token string. Asymmetric tokens are checked
+// against the key named in their kid header; HS256 ones against the shared secret
+// while the migration fallback is enabled.
 func (s *AuthService) ValidateJWT(tokenString string) (*JWTClaims, error) {
 	claims := &JWTClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
 			return nil, ErrTokenExpired
@@ -105,3 +130,36 @@ func (s *AuthService) ValidateJWT(tokenString string) (*JWTClaims, error) {
 	claims.Role = user.Role
 	return claims, nil
 }
+
+func (s *AuthService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if s.cfg.JWTAlgorithm != "HS256" && !s.cfg.JWTAcceptHS256 {
+			return nil, fmt.Errorf("HS256 tokens are no longer accepted")
+		}
+		return []byte(s.cfg.JWTSecret), nil
+	}
+
+	if s.keys == nil {
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, err := s.keys.Lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
+	}
+	return key.PublicKey, nil
+}
+
+func signingMethodFor(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case security.AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case security.AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", security.ErrUnsupportedAlgorithm, algorithm)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/config"
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const authTestSecret = "legacy-secret"
+
+func newAuthTestUser(t *testing.T) (*gorm.DB, *models.User) {
+	t.Helper()
+	db := dbtest.Open(t, &models.User{})
+	user := models.User{Name: "Socio", Email: "socio@example.com", PasswordHash: "x", Role: "socio"}
+	mustCreate(t, db, &user)
+	return db, &user
+}
+
+func newAuthTestKeys(t *testing.T) *security.KeySet {
+	t.Helper()
+	keys, err := security.LoadKeySet(t.TempDir(), security.AlgorithmEdDSA, "")
+	if err != nil {
+		t.Fatalf("LoadKeySet: %v", err)
+	}
+	return keys
+}
+
+// legacyToken signs a token for user with the shared secret, as before the migration.
+func legacyToken(t *testing.T, user *models.User) string {
+	t.Helper()
+	claims := JWTClaims{UserID: user.ID, Role: user.Role, RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(authTestSecret))
+	if err != nil {
+		t.Fatalf("sign legacy token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateJWTLooksUpRotatedKeys(t *testing.T) {
+	db, user := newAuthTestUser(t)
+	keys := newAuthTestKeys(t)
+	cfg := &config.Config{JWTSecret: authTestSecret, JWTAlgorithm: security.AlgorithmEdDSA}
+	service := NewAuthService(db, cfg, keys)
+
+	before, err := service.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	next, err := security.GenerateSigningKey(security.AlgorithmEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey: %v", err)
+	}
+	keys.Add(next)
+	if err := keys.SetActive(next.ID); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	after, err := service.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT after rotating: %v", err)
+	}
+
+	for name, token := range map[string]string{"signed before the rotation": before, "signed after the rotation": after} {
+		claims, err := service.ValidateJWT(token)
+		if err != nil || claims.UserID != user.ID {
+			t.Fatalf("token %s: claims %+v, err %v", name, claims, err)
+		}
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(after, &JWTClaims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if parsed.Header["kid"] != next.ID {
+		t.Fatalf("kid = %v, want the active key %s", parsed.Header["kid"], next.ID)
+	}
+
+	// A token naming a key the ring does not hold is rejected.
+	other := NewAuthService(db, cfg, newAuthTestKeys(t))
+	if _, err := other.ValidateJWT(after); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token with an unknown kid = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestValidateJWTHS256Fallback(t *testing.T) {
+	db, user := newAuthTestUser(t)
+	keys := newAuthTestKeys(t)
+	token := legacyToken(t, user)
+
+	accepting := NewAuthService(db, &config.Config{JWTSecret: authTestSecret, JWTAlgorithm: security.AlgorithmEdDSA, JWTAcceptHS256: true}, keys)
+	if claims, err := accepting.ValidateJWT(token); err != nil || claims.UserID != user.ID {
+		t.Fatalf("HS256 token during the migration: claims %+v, err %v", claims, err)
+	}
+
+	rejecting := NewAuthService(db, &config.Config{JWTSecret: authTestSecret, JWTAlgorithm: security.AlgorithmEdDSA}, keys)
+	if _, err := rejecting.ValidateJWT(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("HS256 token after the migration = %v, want %v", err, ErrInvalidToken)
+	}
+
+	legacy := NewAuthService(db, &config.Config{JWTSecret: authTestSecret, JWTAlgorithm: "HS256"}, nil)
+	if _, err := legacy.ValidateJWT(token); err != nil {
+		t.Fatalf("HS256 token with JWT_ALGORITHM=HS256 = %v", err)
+	}
+}
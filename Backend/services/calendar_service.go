@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/ical"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	calendarTokenPrefix = "cal_"
+	calendarProdID      = "-//Gestion Actividades Deportivas//Calendario//ES"
+	calendarUIDDomain   = "gestion-actividades-deportivas"
+)
+
+var ErrCalendarTokenNotFound = errors.New("calendar token not found")
+
+// CalendarTokenInfo tells a member whether a personal calendar URL is active, without revealing it.
+type CalendarTokenInfo struct {
+	Active     bool       `json:"active"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CalendarService builds the iCalendar feeds of the timetable and manages the secret tokens of the
+// personal feeds.
+type CalendarService struct {
+	db          *gorm.DB
+	enrollments EnrollmentService
+	location    *time.Location
+}
+
+func NewCalendarService(db *gorm.DB, enrollments EnrollmentService, location *time.Location) *CalendarService {
+	return &CalendarService{db: db, enrollments: enrollments, location: location}
+}
+
+// IssueToken creates the secret of the member's calendar URL and returns it in plain text. Any
+// previous token stops working.
+func (s *CalendarService) IssueToken(userID uint) (string, error) {
+	secret, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	plain := calendarTokenPrefix + secret
+
+	token := models.CalendarToken{UserID: userID, TokenHash: hashAPIKey(plain)}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"token_hash": token.TokenHash, "last_used_at": nil, "created_at": time.Now(), "updated_at": time.Now()}),
+	}).Create(&token).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// RevokeToken disables the member's calendar URL.
+func (s *CalendarService) RevokeToken(userID uint) error {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.CalendarToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCalendarTokenNotFound
+	}
+	return nil
+}
+
+func (s *CalendarService) TokenInfo(userID uint) (*CalendarTokenInfo, error) {
+	var token models.CalendarToken
+	if err := s.db.Where("user_id = ?", userID).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &CalendarTokenInfo{}, nil
+		}
+		return nil, err
+	}
+	return &CalendarTokenInfo{Active: true, CreatedAt: &token.CreatedAt, LastUsedAt: token.LastUsedAt}, nil
+}
+
+// ResolveToken returns the member a calendar token belongs to and records its use.
+func (s *CalendarService) ResolveToken(plain string) (uint, error) {
+	var token models.CalendarToken
+	if err := s.db.Where("token_hash = ?", hashAPIKey(plain)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrCalendarTokenNotFound
+		}
+		return 0, err
+	}
+	if err := s.db.Model(&token).Update("last_used_at", time.Now()).Error; err != nil {
+		return 0, err
+	}
+	return token.UserID, nil
+}
+
+// MemberFeed renders the classes the member holds a seat in. Each enrollment repeats weekly from
+// the first session after it was made, within the season of its activity; cancelled sessions are
+// listed as exceptions.
+func (s *CalendarService) MemberFeed(userID uint) ([]byte, error) {
+	enrollments, err := s.enrollments.GetUserEnrollments(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var enrolled []models.Enrollment
+	activityIDs := make([]uint, 0, len(enrollments))
+	for _, enrollment := range enrollments {
+		if enrollment.Status != "inscripto" || enrollment.Activity.Status != models.ActivityPublished {
+			continue
+		}
+		enrolled = append(enrolled, enrollment)
+		activityIDs = append(activityIDs, enrollment.ActivityID)
+	}
+	cancelled, err := cancelledSessions(s.db, activityIDs)
+	if err != nil {
+		return nil, err
+	}
+	seasons, err := activitySeasons(s.db, activityIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &ical.Calendar{ProdID: calendarProdID, Name: "Mis clases", Location: s.location}
+	for _, enrollment := range enrolled {
+		lastModified := enrollment.Activity.UpdatedAt
+		if enrollment.UpdatedAt.After(lastModified) {
+			lastModified = enrollment.UpdatedAt
+		}
+		evt, err := s.activityEvent(&enrollment.Activity, enrollment.CreatedAt, cancelled[enrollment.ActivityID], seasons[enrollment.ActivityID])
+		if err != nil {
+			return nil, err
+		}
+		if evt == nil {
+			continue
+		}
+		evt.UID = fmt.Sprintf("enrollment-%d@%s", enrollment.ID, calendarUIDDomain)
+		evt.LastModified = lastModified
+		calendar.Events = append(calendar.Events, *evt)
+	}
+	return calendar.Bytes(time.Now()), nil
+}
+
+// PublicFeed renders the whole timetable of published activities, each within its season: the
+// feed carries the coming seasons too, so calendars switch timetable on their own.
+func (s *CalendarService) PublicFeed() ([]byte, error) {
+	var activities []models.Activity
+	if err := s.db.Where("status = ?", models.ActivityPublished).Order("day_of_week ASC, start_time ASC, id ASC").Find(&activities).Error; err != nil {
+		return nil, err
+	}
+	activityIDs := make([]uint, 0, len(activities))
+	for _, activity := range activities {
+		activityIDs = append(activityIDs, activity.ID)
+	}
+	cancelled, err := cancelledSessions(s.db, activityIDs)
+	if err != nil {
+		return nil, err
+	}
+	seasons, err := activitySeasons(s.db, activityIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &ical.Calendar{ProdID: calendarProdID, Name: "Grilla de actividades", Location: s.location}
+	for i := range activities {
+		activity := &activities[i]
+		season := seasons[activity.ID]
+		if season != nil && seasonEnded(season, gymDate(time.Now(), s.location)) {
+			continue
+		}
+		evt, err := s.activityEvent(activity, activity.CreatedAt, cancelled[activity.ID], season)
+		if err != nil {
+			return nil, err
+		}
+		if evt == nil {
+			continue
+		}
+		evt.UID = fmt.Sprintf("activity-%d@%s", activity.ID, calendarUIDDomain)
+		evt.LastModified = activity.UpdatedAt
+		calendar.Events = append(calendar.Events, *evt)
+	}
+	return calendar.Bytes(time.Now()), nil
+}
+
+// activityEvent builds the weekly event of activity starting with its first session on or after
+// the day of since. Cancellations before that session are left out. With a season, the sessions
+// are bounded by its dates, and there is no event when none falls within them.
+func (s *CalendarService) activityEvent(activity *models.Activity, since time.Time, cancelledDates []string, season *models.Season) (*ical.Event, error) {
+	var until time.Time
+	if season != nil {
+		startsOn, err := time.ParseInLocation(sessionDateLayout, season.StartsOn, s.location)
+		if err != nil {
+			return nil, fmt.Errorf("season %d: %w", season.ID, err)
+		}
+		endsOn, err := time.ParseInLocation(sessionDateLayout, season.EndsOn, s.location)
+		if err != nil {
+			return nil, fmt.Errorf("season %d: %w", season.ID, err)
+		}
+		if startsOn.After(since) {
+			since = startsOn
+		}
+		until = endsOn.AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	start, err := s.sessionAt(since, activity.DayOfWeek, activity.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("activity %d: %w", activity.ID, err)
+	}
+	end, err := s.sessionAt(start, activity.DayOfWeek, activity.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("activity %d: %w", activity.ID, err)
+	}
+
+	if !until.IsZero() && start.After(until) {
+		return nil, nil
+	}
+
+	evt := &ical.Event{
+		Summary:     activity.Title,
+		Description: activity.Description,
+		Location:    activity.Location,
+		Categories:  []string{activity.Category},
+		Start:       start,
+		End:         end,
+		Until:       until,
+	}
+	if activity.Instructor != "" {
+		if evt.Description != "" {
+			evt.Description += "\n\n"
+		}
+		evt.Description += "Profesor/a: " + activity.Instructor
+	}
+	for _, date := range cancelledDates {
+		day, err := time.ParseInLocation(sessionDateLayout, date, s.location)
+		if err != nil {
+			continue
+		}
+		exception := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, s.location)
+		if !exception.Before(start) {
+			evt.Exceptions = append(evt.Exceptions, exception)
+		}
+	}
+	return evt, nil
+}
+
+// sessionAt returns the first dayOfWeek (0 = Sunday) on or after the calendar day of from, at
+// clock "HH:MM" in the feed's time zone.
+func (s *CalendarService) sessionAt(from time.Time, dayOfWeek int, clock string) (time.Time, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", clock)
+	}
+	local := from.In(s.location)
+	days := (dayOfWeek - int(local.Weekday()) + 7) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()+days, parsed.Hour(), parsed.Minute(), 0, 0, s.location), nil
+}
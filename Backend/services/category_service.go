@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/search"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryExists   = errors.New("a category with that slug already exists")
+	ErrCategoryInUse    = errors.New("category is in use")
+	ErrInvalidCategory  = errors.New("invalid category")
+)
+
+var (
+	slugPattern  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	iconPattern  = regexp.MustCompile(`^[a-z0-9-]{1,50}$`)
+)
+
+// CategoryInput carries the editable fields of a category. Slug is only read on creation and
+// defaults to the slug of Name.
+type CategoryInput struct {
+	Slug  string
+	Name  string
+	Color string
+	Icon  string
+}
+
+// CategoryView is a category with the number of active activities filed under it.
+type CategoryView struct {
+	models.Category
+	ActiveActivities int `json:"active_activities"`
+}
+
+// CategoryService manages the category catalogue.
+type CategoryService struct {
+	db *gorm.DB
+}
+
+func NewCategoryService(db *gorm.DB) *CategoryService {
+	return &CategoryService{db: db}
+}
+
+// ListCategories returns every category sorted by name, with its active activity count.
+func (s *CategoryService) ListCategories() ([]CategoryView, error) {
+	var views []CategoryView
+	err := s.db.Model(&models.Category{}).
+		Select("categories.*, COUNT(activities.id) AS active_activities").
+		Joins("LEFT JOIN activities ON activities.category_id = categories.id AND activities.is_active = ?", true).
+		Group("categories.id").
+		Order("categories.name ASC").
+		Scan(&views).Error
+	if err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+func (s *CategoryService) CreateCategory(input CategoryInput) (*models.Category, error) {
+	if input.Slug == "" {
+		input.Slug = search.Slug(input.Name)
+	}
+	if err := validateCategoryInput(input); err != nil {
+		return nil, err
+	}
+	if !slugPattern.MatchString(input.Slug) {
+		return nil, fmt.Errorf("%w: slug must be lower-case letters, digits and hyphens", ErrInvalidCategory)
+	}
+
+	var existing int64
+	if err := s.db.Model(&models.Category{}).Where("slug = ?", input.Slug).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrCategoryExists
+	}
+
+	category := models.Category{
+		Slug:  input.Slug,
+		Name:  strings.TrimSpace(input.Name),
+		Color: input.Color,
+		Icon:  input.Icon,
+	}
+	if err := s.db.Create(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// UpdateCategory changes how a category is displayed. The slug is kept: activities, filters and
+// plan quotas refer to it.
+func (s *CategoryService) UpdateCategory(id uint, input CategoryInput) (*models.Category, error) {
+	if err := validateCategoryInput(input); err != nil {
+		return nil, err
+	}
+	category, err := findCategory(s.db, &id, "")
+	if err != nil {
+		return nil, err
+	}
+	if input.Slug != "" && input.Slug != category.Slug {
+		return nil, fmt.Errorf("%w: the slug cannot change", ErrInvalidCategory)
+	}
+
+	category.Name = strings.TrimSpace(input.Name)
+	category.Color = input.Color
+	category.Icon = input.Icon
+	if err := s.db.Save(category).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category no activity or plan quota uses.
+func (s *CategoryService) DeleteCategory(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		category, err := findCategory(tx, &id, "")
+		if err != nil {
+			return err
+		}
+		var activities, quotas int64
+		if err := tx.Model(&models.Activity{}).Where("category_id = ?", category.ID).Count(&activities).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PlanCategoryQuota{}).Where("category = ?", category.Slug).Count(&quotas).Error; err != nil {
+			return err
+		}
+		if activities > 0 || quotas > 0 {
+			return fmt.Errorf("%w: %d activities and %d plan quotas use it", ErrCategoryInUse, activities, quotas)
+		}
+		return tx.Delete(category).Error
+	})
+}
+
+func validateCategoryInput(input CategoryInput) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > 100 {
+		return fmt.Errorf("%w: name is required and up to 100 characters", ErrInvalidCategory)
+	}
+	if input.Color != "" && !colorPattern.MatchString(input.Color) {
+		return fmt.Errorf("%w: color must look like #RRGGBB", ErrInvalidCategory)
+	}
+	if input.Icon != "" && !iconPattern.MatchString(input.Icon) {
+		return fmt.Errorf("%w: icon must be lower-case letters, digits and hyphens", ErrInvalidCategory)
+	}
+	return nil
+}
+
+// findCategory loads a category by id or, when id is nil, by slug. The slug may be written like
+// a display name ("Fuerza" finds "fuerza").
+func findCategory(db *gorm.DB, id *uint, slug string) (*models.Category, error) {
+	var category models.Category
+	var err error
+	if id != nil {
+		err = db.First(&category, *id).Error
+	} else {
+		slug = search.Slug(slug)
+		if slug == "" {
+			return nil, ErrCategoryNotFound
+		}
+		err = db.Where("slug = ?", slug).First(&category).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// assignCategory points activity at its category, given by CategoryID or else by the Category
+// slug, and refreshes the slug copy.
+func assignCategory(db *gorm.DB, activity *models.Activity) error {
+	category, err := findCategory(db, activity.CategoryID, activity.Category)
+	if err != nil {
+		return err
+	}
+	activity.CategoryID = &category.ID
+	activity.Category = category.Slug
+	return nil
+}
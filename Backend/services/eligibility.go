@@ -0,0 +1,422 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reasons a member is not eligible for an activity, one per failed rule.
+const (
+	// ReasonTooYoung and ReasonTooOld: the member's age is out of the range of the activity.
+	ReasonTooYoung = "too_young"
+	ReasonTooOld   = "too_old"
+	// ReasonBirthDateMissing: an age rule cannot be checked because the birth date is unknown.
+	ReasonBirthDateMissing = "birth_date_missing"
+	// ReasonLevelTooLow: the member's level, if any, is below the one the activity requires.
+	ReasonLevelTooLow = "level_too_low"
+	// ReasonPlanRequired: the member has no active membership of the plans the activity requires.
+	ReasonPlanRequired = "plan_required"
+	// ReasonPrerequisiteMissing: the member has not completed the prerequisite activity.
+	ReasonPrerequisiteMissing = "prerequisite_missing"
+)
+
+var (
+	ErrNotEligible            = errors.New("user is not eligible for this activity")
+	ErrInvalidEligibilityRule = errors.New("invalid eligibility rule")
+)
+
+// NotEligibleError is returned by enrollments the eligibility rules forbid; it matches
+// ErrNotEligible and carries the reason codes.
+type NotEligibleError struct {
+	Reasons []string
+}
+
+func (e *NotEligibleError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrNotEligible, strings.Join(e.Reasons, ", "))
+}
+
+func (e *NotEligibleError) Is(target error) bool {
+	return target == ErrNotEligible
+}
+
+// eligibilityProfile is what the rules are evaluated against, loaded once per member.
+type eligibilityProfile struct {
+	user  models.User
+	today string
+	// planID is the plan of the active membership; 0 without one.
+	planID uint
+	// completed holds the activities the member completed: enrollments that reached the end of
+	// their season ("finalizado"). Being enrolled in an activity does not complete it.
+	completed map[uint]bool
+}
+
+// eligibilityCheck implements a rule type. normalize validates the value an admin sets for
+// activityID and returns it in canonical form; evaluate returns the reason a member fails the
+// rule, or "" when they pass it.
+type eligibilityCheck struct {
+	normalize func(tx *gorm.DB, activityID uint, value string) (string, error)
+	evaluate  func(profile *eligibilityProfile, value string) string
+}
+
+// eligibilityChecks is the rule engine: a rule type is supported once it has a check here.
+var eligibilityChecks = map[string]eligibilityCheck{
+	models.RuleMinAge: {
+		normalize: normalizeAge,
+		evaluate: func(profile *eligibilityProfile, value string) string {
+			age, ok := profile.age()
+			if !ok {
+				return ReasonBirthDateMissing
+			}
+			if limit, _ := strconv.Atoi(value); age < limit {
+				return ReasonTooYoung
+			}
+			return ""
+		},
+	},
+	models.RuleMaxAge: {
+		normalize: normalizeAge,
+		evaluate: func(profile *eligibilityProfile, value string) string {
+			age, ok := profile.age()
+			if !ok {
+				return ReasonBirthDateMissing
+			}
+			if limit, _ := strconv.Atoi(value); age > limit {
+				return ReasonTooOld
+			}
+			return ""
+		},
+	},
+	models.RuleMinLevel: {
+		normalize: func(_ *gorm.DB, _ uint, value string) (string, error) {
+			value = strings.ToLower(strings.TrimSpace(value))
+			if levelRank(value) < 0 {
+				return "", fmt.Errorf("%w: level must be one of %s", ErrInvalidEligibilityRule, strings.Join(models.MemberLevels, ", "))
+			}
+			return value, nil
+		},
+		evaluate: func(profile *eligibilityProfile, value string) string {
+			if levelRank(profile.user.Level) < levelRank(value) {
+				return ReasonLevelTooLow
+			}
+			return ""
+		},
+	},
+	models.RulePlan: {
+		normalize: normalizePlans,
+		evaluate: func(profile *eligibilityProfile, value string) string {
+			for _, id := range strings.Split(value, ",") {
+				if id == strconv.FormatUint(uint64(profile.planID), 10) {
+					return ""
+				}
+			}
+			return ReasonPlanRequired
+		},
+	},
+	models.RulePrerequisite: {
+		normalize: normalizePrerequisite,
+		evaluate: func(profile *eligibilityProfile, value string) string {
+			id, _ := strconv.ParseUint(value, 10, 64)
+			if !profile.completed[uint(id)] {
+				return ReasonPrerequisiteMissing
+			}
+			return ""
+		},
+	},
+}
+
+// age returns the member's age in full years; false when the birth date is unknown.
+func (p *eligibilityProfile) age() (int, bool) {
+	birth, err := time.Parse(sessionDateLayout, p.user.BirthDate)
+	if err != nil {
+		return 0, false
+	}
+	today, err := time.Parse(sessionDateLayout, p.today)
+	if err != nil {
+		return 0, false
+	}
+	// Both dates are YYYY-MM-DD, so comparing their MM-DD tells whether the birthday has passed.
+	age := today.Year() - birth.Year()
+	if p.today[5:] < p.user.BirthDate[5:] {
+		age--
+	}
+	return age, true
+}
+
+// levelRank returns the position of level in MemberLevels; -1 for none or an unknown level.
+func levelRank(level string) int {
+	for i, known := range models.MemberLevels {
+		if level == known {
+			return i
+		}
+	}
+	return -1
+}
+
+func normalizeAge(_ *gorm.DB, _ uint, value string) (string, error) {
+	age, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || age < 0 || age > 120 {
+		return "", fmt.Errorf("%w: age must be a number of years between 0 and 120", ErrInvalidEligibilityRule)
+	}
+	return strconv.Itoa(age), nil
+}
+
+// normalizePlans checks that the comma separated plan ids exist and sorts them.
+func normalizePlans(tx *gorm.DB, _ uint, value string) (string, error) {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil || id == 0 {
+			return "", fmt.Errorf("%w: plan must list plan ids separated by commas", ErrInvalidEligibilityRule)
+		}
+		if !seen[uint(id)] {
+			seen[uint(id)] = true
+			ids = append(ids, uint(id))
+		}
+	}
+	var found int64
+	if err := tx.Model(&models.MembershipPlan{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+		return "", err
+	}
+	if int(found) != len(ids) {
+		return "", fmt.Errorf("%w: some plan does not exist", ErrInvalidEligibilityRule)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// normalizePrerequisite checks that the prerequisite is another activity held in a season: only
+// closing a season finishes enrollments, so an activity held all year round is never completed.
+func normalizePrerequisite(tx *gorm.DB, activityID uint, value string) (string, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil || id == 0 {
+		return "", fmt.Errorf("%w: prerequisite must be an activity id", ErrInvalidEligibilityRule)
+	}
+	if uint(id) == activityID {
+		return "", fmt.Errorf("%w: an activity cannot be its own prerequisite", ErrInvalidEligibilityRule)
+	}
+	var prerequisite models.Activity
+	if err := tx.Select("id", "season_id").First(&prerequisite, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%w: prerequisite activity %d does not exist", ErrInvalidEligibilityRule, id)
+		}
+		return "", err
+	}
+	if prerequisite.SeasonID == nil {
+		return "", fmt.Errorf("%w: prerequisite activity %d has no season, so it is never completed", ErrInvalidEligibilityRule, id)
+	}
+	return strconv.FormatUint(id, 10), nil
+}
+
+// ensurePrerequisitesKeepSeason fails with ErrInvalidEligibilityRule when activityID, about to be
+// held all year round, is the prerequisite of another activity, which nobody could then meet.
+func ensurePrerequisitesKeepSeason(tx *gorm.DB, activityID uint) error {
+	var rule models.EligibilityRule
+	err := tx.Where("type = ? AND value = ?", models.RulePrerequisite, strconv.FormatUint(uint64(activityID), 10)).
+		First(&rule).Error
+	if err == nil {
+		return fmt.Errorf("%w: activity %d is a prerequisite of activity %d and must keep a season", ErrInvalidEligibilityRule, activityID, rule.ActivityID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// normalizeEligibilityRules validates the rules of activityID and returns them in canonical form.
+// A prerequisite may appear several times, for different activities; any other type only once.
+func normalizeEligibilityRules(tx *gorm.DB, activityID uint, rules []models.EligibilityRule) ([]models.EligibilityRule, error) {
+	normalized := make([]models.EligibilityRule, 0, len(rules))
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		check, ok := eligibilityChecks[rule.Type]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidEligibilityRule, rule.Type)
+		}
+		value, err := check.normalize(tx, activityID, rule.Value)
+		if err != nil {
+			return nil, err
+		}
+		key := rule.Type
+		if rule.Type == models.RulePrerequisite {
+			key += ":" + value
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidEligibilityRule, key)
+		}
+		seen[key] = true
+		normalized = append(normalized, models.EligibilityRule{ActivityID: activityID, Type: rule.Type, Value: value})
+	}
+	return normalized, nil
+}
+
+// SetEligibilityRules replaces the eligibility rules of an activity. Members already holding a
+// seat keep it; the rules apply to new enrollments.
+func (s *ActivityService) SetEligibilityRules(activityID uint, rules []models.EligibilityRule, actor Actor) ([]models.EligibilityRule, error) {
+	var saved []models.EligibilityRule
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var activity models.Activity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, activityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrActivityNotFound
+			}
+			return err
+		}
+		var previous []models.EligibilityRule
+		if err := tx.Where("activity_id = ?", activityID).Order("id ASC").Find(&previous).Error; err != nil {
+			return err
+		}
+		var err error
+		if saved, err = replaceEligibilityRules(tx, activityID, rules); err != nil {
+			return err
+		}
+
+		if err := recordAudit(tx, actor, auditChange{
+			Action:     "activity.eligibility_updated",
+			EntityType: AuditActivity,
+			EntityID:   activityID,
+			Extra:      map[string]FieldChange{"eligibility_rules": {Before: previous, After: saved}},
+		}); err != nil {
+			return err
+		}
+		evt := events.New(events.ActivityUpdated)
+		evt.ActivityID = activityID
+		return s.events.Record(tx, evt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// replaceEligibilityRules validates rules and stores them as the only rules of activityID.
+func replaceEligibilityRules(tx *gorm.DB, activityID uint, rules []models.EligibilityRule) ([]models.EligibilityRule, error) {
+	normalized, err := normalizeEligibilityRules(tx, activityID, rules)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("activity_id = ?", activityID).Delete(&models.EligibilityRule{}).Error; err != nil {
+		return nil, err
+	}
+	if len(normalized) > 0 {
+		if err := tx.Omit("Activity").Create(&normalized).Error; err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
+
+// populateEligibilityRules loads the eligibility rules of activities in a single query.
+func populateEligibilityRules(db *gorm.DB, activities ...*models.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(activities))
+	for i, activity := range activities {
+		ids[i] = activity.ID
+	}
+	var rules []models.EligibilityRule
+	if err := db.Where("activity_id IN ?", ids).Order("id ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+	byActivity := make(map[uint][]models.EligibilityRule, len(activities))
+	for _, rule := range rules {
+		byActivity[rule.ActivityID] = append(byActivity[rule.ActivityID], rule)
+	}
+	for _, activity := range activities {
+		activity.EligibilityRules = byActivity[activity.ID]
+	}
+	return nil
+}
+
+// loadEligibilityProfile loads what the rules need to know about userID on now, which must be in
+// the gym's time zone: ages are counted on its date.
+func loadEligibilityProfile(db *gorm.DB, userID uint, now time.Time) (*eligibilityProfile, error) {
+	profile := &eligibilityProfile{today: now.Format(sessionDateLayout), completed: make(map[uint]bool)}
+	if err := db.First(&profile.user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	membership, err := activeMembership(db, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if membership != nil {
+		profile.planID = membership.PlanID
+	}
+	var completed []uint
+	if err := db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND status = ?", userID, "finalizado").
+		Pluck("activity_id", &completed).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range completed {
+		profile.completed[id] = true
+	}
+	return profile, nil
+}
+
+// evaluateEligibility runs the rules against profile. Each failed rule adds its reason once.
+func evaluateEligibility(profile *eligibilityProfile, rules []models.EligibilityRule) *models.Eligibility {
+	eligibility := &models.Eligibility{Eligible: true}
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		check, ok := eligibilityChecks[rule.Type]
+		if !ok {
+			continue
+		}
+		if reason := check.evaluate(profile, rule.Value); reason != "" && !seen[reason] {
+			seen[reason] = true
+			eligibility.Eligible = false
+			eligibility.Reasons = append(eligibility.Reasons, reason)
+		}
+	}
+	return eligibility
+}
+
+// checkEligibility fails with a NotEligibleError when userID does not satisfy the eligibility
+// rules of activity.
+func checkEligibility(db *gorm.DB, userID uint, activity *models.Activity, now time.Time) error {
+	if err := populateEligibilityRules(db, activity); err != nil {
+		return err
+	}
+	if len(activity.EligibilityRules) == 0 {
+		return nil
+	}
+	profile, err := loadEligibilityProfile(db, userID, now)
+	if err != nil {
+		return err
+	}
+	if eligibility := evaluateEligibility(profile, activity.EligibilityRules); !eligibility.Eligible {
+		return &NotEligibleError{Reasons: eligibility.Reasons}
+	}
+	return nil
+}
+
+// annotateEligibility fills in, for each activity, whether userID satisfies its eligibility rules,
+// which must be loaded. Only the rules are considered: seats, schedule and plan quota are not.
+func annotateEligibility(db *gorm.DB, userID uint, now time.Time, activities ...*models.Activity) error {
+	profile, err := loadEligibilityProfile(db, userID, now)
+	if err != nil {
+		return err
+	}
+	for _, activity := range activities {
+		activity.Eligibility = evaluateEligibility(profile, activity.EligibilityRules)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+func openEligibilityDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.User{}, &models.Season{}, &models.Activity{}, &models.EligibilityRule{}, &models.Enrollment{},
+		&models.Payment{}, &models.MembershipPlan{}, &models.PlanCategoryQuota{}, &models.Membership{})
+}
+
+func newEligibilityActivity(t *testing.T, db *gorm.DB, title string, seasonID *uint) *models.Activity {
+	t.Helper()
+	activity := models.Activity{Title: title, Category: "natacion", DayOfWeek: 2, StartTime: "18:00", EndTime: "19:00",
+		Capacity: 10, Instructor: "Profe", SeasonID: seasonID}
+	activity.SetStatus(models.ActivityPublished)
+	mustCreate(t, db, &activity)
+	return &activity
+}
+
+// evaluate runs a single rule against profile and returns the reason it fails, "" when it passes.
+func evaluate(profile *eligibilityProfile, ruleType, value string) string {
+	eligibility := evaluateEligibility(profile, []models.EligibilityRule{{Type: ruleType, Value: value}})
+	if eligibility.Eligible {
+		return ""
+	}
+	return eligibility.Reasons[0]
+}
+
+func TestAgeRulesCountTheBirthday(t *testing.T) {
+	birthday := &eligibilityProfile{user: models.User{BirthDate: "2006-05-10"}, today: "2024-05-10"}
+	dayBefore := &eligibilityProfile{user: models.User{BirthDate: "2006-05-10"}, today: "2024-05-09"}
+	unknown := &eligibilityProfile{today: "2024-05-10"}
+
+	tests := []struct {
+		name    string
+		profile *eligibilityProfile
+		rule    string
+		value   string
+		want    string
+	}{
+		{"turns the minimum age on the birthday", birthday, models.RuleMinAge, "18", ""},
+		{"below the minimum the day before", dayBefore, models.RuleMinAge, "18", ReasonTooYoung},
+		{"still at the maximum the day before", dayBefore, models.RuleMaxAge, "17", ""},
+		{"over the maximum on the birthday", birthday, models.RuleMaxAge, "17", ReasonTooOld},
+		{"min_age without birth date", unknown, models.RuleMinAge, "18", ReasonBirthDateMissing},
+		{"max_age without birth date", unknown, models.RuleMaxAge, "17", ReasonBirthDateMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluate(tt.profile, tt.rule, tt.value); got != tt.want {
+				t.Fatalf("%s %s = %q, want %q", tt.rule, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinLevelRule(t *testing.T) {
+	tests := []struct {
+		level string
+		want  string
+	}{
+		{"", ReasonLevelTooLow},
+		{models.LevelBeginner, ReasonLevelTooLow},
+		{models.LevelIntermediate, ""},
+		{models.LevelAdvanced, ""},
+	}
+	for _, tt := range tests {
+		profile := &eligibilityProfile{user: models.User{Level: tt.level}}
+		if got := evaluate(profile, models.RuleMinLevel, models.LevelIntermediate); got != tt.want {
+			t.Fatalf("level %q = %q, want %q", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestPlanRule(t *testing.T) {
+	tests := []struct {
+		planID uint
+		want   string
+	}{
+		{0, ReasonPlanRequired},
+		{2, ReasonPlanRequired},
+		{12, ""},
+		{25, ""},
+		{5, ReasonPlanRequired},
+	}
+	for _, tt := range tests {
+		profile := &eligibilityProfile{planID: tt.planID}
+		if got := evaluate(profile, models.RulePlan, "12,25"); got != tt.want {
+			t.Fatalf("plan %d = %q, want %q", tt.planID, got, tt.want)
+		}
+	}
+}
+
+func TestEnrollReturnsEligibilityReasons(t *testing.T) {
+	db := openEligibilityDB(t)
+	user := models.User{Name: "Socio", Email: "socio@example.com", PasswordHash: "x", Role: "socio", Level: models.LevelBeginner}
+	mustCreate(t, db, &user)
+	activity := newEligibilityActivity(t, db, "Aguas abiertas", nil)
+	for _, rule := range []models.EligibilityRule{
+		{ActivityID: activity.ID, Type: models.RuleMinAge, Value: "18"},
+		{ActivityID: activity.ID, Type: models.RuleMaxAge, Value: "60"},
+		{ActivityID: activity.ID, Type: models.RuleMinLevel, Value: models.LevelAdvanced},
+	} {
+		mustCreate(t, db, &rule)
+	}
+
+	bus := events.NewBus()
+	memberships := NewMembershipService(db, false)
+	waitlist := NewWaitlist(db, memberships, bus, time.UTC, 15*time.Minute)
+	payments := NewPaymentService(db, nil, nil, memberships, waitlist, bus, "ARS", 15*time.Minute)
+	service := NewEnrollmentService(db, memberships, payments, waitlist, bus, time.UTC)
+
+	_, err := service.EnrollUserInActivity(user.ID, activity.ID, UserActor(user.ID))
+	var notEligible *NotEligibleError
+	if !errors.As(err, &notEligible) || !errors.Is(err, ErrNotEligible) {
+		t.Fatalf("EnrollUserInActivity = %v, want a NotEligibleError", err)
+	}
+	// Both age rules fail for the missing birth date, which is reported once.
+	want := []string{ReasonBirthDateMissing, ReasonLevelTooLow}
+	if !reflect.DeepEqual(notEligible.Reasons, want) {
+		t.Fatalf("reasons = %v, want %v", notEligible.Reasons, want)
+	}
+
+	birthDate := time.Now().AddDate(-30, 0, 0).Format(sessionDateLayout)
+	if err := db.Model(&user).Updates(map[string]interface{}{"birth_date": birthDate, "level": models.LevelAdvanced}).Error; err != nil {
+		t.Fatalf("update profile: %v", err)
+	}
+	enrollment, err := service.EnrollUserInActivity(user.ID, activity.ID, UserActor(user.ID))
+	if err != nil {
+		t.Fatalf("EnrollUserInActivity once eligible = %v", err)
+	}
+	if enrollment.Status != "inscripto" {
+		t.Fatalf("status = %s, want inscripto", enrollment.Status)
+	}
+}
+
+func TestPrerequisiteRequiresFinishedEnrollment(t *testing.T) {
+	db := openEligibilityDB(t)
+
+	user := models.User{Name: "Socio", Email: "socio@example.com", PasswordHash: "x", Role: "socio"}
+	mustCreate(t, db, &user)
+	season := models.Season{Name: "Verano", StartsOn: "2024-01-01", EndsOn: "2024-03-31"}
+	mustCreate(t, db, &season)
+	basic := newEligibilityActivity(t, db, "Natación inicial", &season.ID)
+	advanced := newEligibilityActivity(t, db, "Natación avanzada", nil)
+	mustCreate(t, db, &models.EligibilityRule{ActivityID: advanced.ID, Type: models.RulePrerequisite, Value: strconv.Itoa(int(basic.ID))})
+
+	check := func() error {
+		target := *advanced
+		return checkEligibility(db, user.ID, &target, time.Now())
+	}
+
+	var notEligible *NotEligibleError
+	if err := check(); !errors.As(err, &notEligible) || notEligible.Reasons[0] != ReasonPrerequisiteMissing {
+		t.Fatalf("without enrollments = %v, want %s", err, ReasonPrerequisiteMissing)
+	}
+
+	enrollment := models.Enrollment{UserID: user.ID, ActivityID: basic.ID, Status: "inscripto"}
+	mustCreate(t, db, &enrollment)
+	if err := check(); !errors.As(err, &notEligible) {
+		t.Fatalf("while enrolled in the prerequisite = %v, want %s", err, ReasonPrerequisiteMissing)
+	}
+
+	if err := db.Model(&enrollment).Update("status", "finalizado").Error; err != nil {
+		t.Fatalf("finish enrollment: %v", err)
+	}
+	if err := check(); err != nil {
+		t.Fatalf("after finishing the prerequisite = %v, want nil", err)
+	}
+}
+
+func TestPrerequisiteMustBeHeldInASeason(t *testing.T) {
+	db := openEligibilityDB(t)
+	season := models.Season{Name: "Verano", StartsOn: "2024-01-01", EndsOn: "2024-03-31"}
+	mustCreate(t, db, &season)
+	seasonal := newEligibilityActivity(t, db, "Natación inicial", &season.ID)
+	allYear := newEligibilityActivity(t, db, "Pileta libre", nil)
+	target := newEligibilityActivity(t, db, "Natación avanzada", nil)
+
+	rule := func(prerequisite *models.Activity) []models.EligibilityRule {
+		return []models.EligibilityRule{{Type: models.RulePrerequisite, Value: strconv.Itoa(int(prerequisite.ID))}}
+	}
+	if _, err := normalizeEligibilityRules(db, target.ID, rule(allYear)); !errors.Is(err, ErrInvalidEligibilityRule) {
+		t.Fatalf("prerequisite held all year = %v, want %v", err, ErrInvalidEligibilityRule)
+	}
+	if _, err := replaceEligibilityRules(db, target.ID, rule(seasonal)); err != nil {
+		t.Fatalf("prerequisite held in a season = %v, want nil", err)
+	}
+
+	if err := ensurePrerequisitesKeepSeason(db, seasonal.ID); !errors.Is(err, ErrInvalidEligibilityRule) {
+		t.Fatalf("dropping the season of a prerequisite = %v, want %v", err, ErrInvalidEligibilityRule)
+	}
+	if err := ensurePrerequisitesKeepSeason(db, allYear.ID); err != nil {
+		t.Fatalf("dropping the season of another activity = %v, want nil", err)
+	}
+}
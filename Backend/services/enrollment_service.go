@@ -4,91 +4,192 @@ import (
 	"errors"
 	"time"
 
+	"github.com/alesio/gestion-actividades-deportivas/events"
 	"github.com/alesio/gestion-actividades-deportivas/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	ErrActivityNotFound   = errors.New("activity not found")
 	ErrActivityInactive   = errors.New("activity is not active")
+	ErrActivityPaused     = errors.New("activity is paused")
 	ErrAlreadyEnrolled    = errors.New("user already enrolled in this activity")
 	ErrNoCapacity         = errors.New("activity has no remaining capacity")
 	ErrScheduleConflict   = errors.New("activity schedule overlaps with an existing enrollment")
 	ErrEnrollmentNotFound = errors.New("enrollment not found")
+	ErrWaitlisted         = errors.New("user is on the waitlist for this activity")
 )
 
-// EnrollmentService exposes enrollment use cases.
+// EnrollmentService exposes enrollment use cases. actor is who makes the change: the member or,
+// for changes made on their behalf, an admin. Only the latter are written to the audit log.
 type EnrollmentService interface {
-	EnrollUserInActivity(userID uint, activityID uint) (*models.Enrollment, error)
+	EnrollUserInActivity(userID uint, activityID uint, actor Actor) (*models.Enrollment, error)
 	GetUserEnrollments(userID uint) ([]models.Enrollment, error)
-	UnenrollUserFromActivity(userID uint, activityID uint) error
+	UnenrollUserFromActivity(userID uint, activityID uint, actor Actor) error
+	ListActivityEnrollments(activityID uint) ([]models.Enrollment, error)
 }
 
+// enrollmentService reads season dates and member ages in location, the gym's time zone.
 type enrollmentService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	memberships *MembershipService
+	payments    *PaymentService
+	waitlist    *Waitlist
+	events      *events.Bus
+	location    *time.Location
 }
 
-func NewEnrollmentService(db *gorm.DB) EnrollmentService {
-	return &enrollmentService{db: db}
+func NewEnrollmentService(db *gorm.DB, memberships *MembershipService, payments *PaymentService, waitlist *Waitlist, bus *events.Bus, location *time.Location) EnrollmentService {
+	return &enrollmentService{db: db, memberships: memberships, payments: payments, waitlist: waitlist, events: bus, location: location}
 }
 
-func (s *enrollmentService) EnrollUserInActivity(userID, activityID uint) (*models.Enrollment, error) {
-	var activity models.Activity
-	if err := s.db.First(&activity, activityID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrActivityNotFound
-		}
+// EnrollUserInActivity enrolls userID, holding the seat as "pendiente_pago" and opening a checkout
+// when the activity is paid. A seat the member was promoted to from the waitlist and still has to
+// pay for gets its checkout opened instead.
+func (s *enrollmentService) EnrollUserInActivity(userID, activityID uint, actor Actor) (*models.Enrollment, error) {
+	if err := s.waitlist.expireStaleHolds(); err != nil {
 		return nil, err
 	}
 
-	if !activity.IsActive {
-		return nil, ErrActivityInactive
-	}
+	var activity models.Activity
+	var enrollment models.Enrollment
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		// Locking the activity serialises the enrollments in it, and locking the member serialises
+		// their enrollments elsewhere, so the duplicate, capacity and quota checks below still hold
+		// when the seat is inserted.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, activityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrActivityNotFound
+			}
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
 
-	// Check duplicate enrollment with active status.
-	var existing models.Enrollment
-	if err := s.db.Where("user_id = ? AND activity_id = ? AND status = ?", userID, activityID, "inscripto").First(&existing).Error; err == nil {
-		return nil, ErrAlreadyEnrolled
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
-	}
+		if activity.Status == models.ActivityPaused {
+			return ErrActivityPaused
+		}
+		if activity.Status != models.ActivityPublished {
+			return ErrActivityInactive
+		}
 
-	if err := s.ensureNoScheduleConflict(userID, &activity); err != nil {
-		return nil, err
-	}
+		// Check duplicate enrollment with active status or a seat held for payment.
+		now := time.Now().In(s.location)
+		var existing models.Enrollment
+		if err := seatHolders(tx).Where("user_id = ? AND activity_id = ?", userID, activityID).First(&existing).Error; err == nil {
+			if existing.Status != "pendiente_pago" {
+				return ErrAlreadyEnrolled
+			}
+			var checkouts int64
+			if err := tx.Model(&models.Payment{}).
+				Where("enrollment_id = ? AND status = ?", existing.ID, "pendiente").
+				Count(&checkouts).Error; err != nil {
+				return err
+			}
+			if checkouts > 0 {
+				return ErrPaymentPending
+			}
+			// Promoted from the waitlist: the seat is already held, only the checkout is missing.
+			enrollment = existing
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		var waitlisted int64
+		if err := tx.Model(&models.Enrollment{}).
+			Where("user_id = ? AND activity_id = ? AND status = ?", userID, activityID, "lista_espera").
+			Count(&waitlisted).Error; err != nil {
+			return err
+		}
+		if waitlisted > 0 {
+			return ErrWaitlisted
+		}
 
-	// Validate remaining capacity.
-	var count int64
-	if err := s.db.Model(&models.Enrollment{}).
-		Where("activity_id = ? AND status = ?", activityID, "inscripto").
-		Count(&count).Error; err != nil {
+		if err := checkAdmission(tx, s.memberships, userID, &activity, now); err != nil {
+			return err
+		}
+
+		// Validate remaining capacity; seats held for pending payments count as taken.
+		var count int64
+		if err := seatHolders(tx.Model(&models.Enrollment{})).
+			Where("activity_id = ?", activityID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) >= activity.Capacity {
+			return ErrNoCapacity
+		}
+
+		eventType := events.EnrollmentConfirmed
+		enrollment = models.Enrollment{UserID: userID, ActivityID: activityID, Status: "inscripto"}
+		if activity.PriceCents > 0 {
+			// Paid activities hold the seat as "pendiente_pago" until the payment webhook confirms it.
+			holdUntil := now.Add(s.payments.HoldTTL())
+			enrollment.Status = "pendiente_pago"
+			enrollment.HoldExpiresAt = &holdUntil
+			eventType = events.EnrollmentHeld
+		}
+		if err := tx.Create(&enrollment).Error; err != nil {
+			return err
+		}
+		if err := auditEnrollment(tx, actor, "enrollment.created", nil, &enrollment); err != nil {
+			return err
+		}
+		return s.events.Record(tx, enrollmentEvent(eventType, &enrollment))
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if int(count) >= activity.Capacity {
-		return nil, ErrNoCapacity
+	if enrollment.Status == "pendiente_pago" {
+		return s.openCheckout(&enrollment, &activity)
 	}
+	return &enrollment, nil
+}
 
-	enrollment := models.Enrollment{UserID: userID, ActivityID: activityID, Status: "inscripto"}
-	if err := s.db.Create(&enrollment).Error; err != nil {
+// openCheckout opens the checkout of a seat held as "pendiente_pago", releasing the seat when the
+// gateway refuses it.
+func (s *enrollmentService) openCheckout(enrollment *models.Enrollment, activity *models.Activity) (*models.Enrollment, error) {
+	payment, err := s.payments.StartEnrollmentCheckout(enrollment, activity)
+	if err != nil {
+		releaseErr := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+			if err := tx.Model(enrollment).
+				Updates(map[string]interface{}{"status": "cancelado", "hold_expires_at": nil}).Error; err != nil {
+				return err
+			}
+			return s.events.Record(tx, enrollmentEvent(events.EnrollmentReleased, enrollment))
+		})
+		if releaseErr != nil {
+			return nil, releaseErr
+		}
 		return nil, err
 	}
-	return &enrollment, nil
+	enrollment.Payment = payment
+	return enrollment, nil
 }
 
+// GetUserEnrollments returns the user's active enrollments and the ones waiting on a waitlist.
 func (s *enrollmentService) GetUserEnrollments(userID uint) ([]models.Enrollment, error) {
 	var enrollments []models.Enrollment
 	if err := s.db.Preload("Activity").
-		Where("user_id = ? AND status = ?", userID, "inscripto").
+		Where("user_id = ? AND status IN ?", userID, []string{"inscripto", "lista_espera"}).
 		Find(&enrollments).Error; err != nil {
 		return nil, err
 	}
 	return enrollments, nil
 }
 
-func (s *enrollmentService) UnenrollUserFromActivity(userID uint, activityID uint) error {
+// UnenrollUserFromActivity cancels an active enrollment, a seat still waiting for payment or a
+// place on the waitlist, settling its payment as cancelEnrollments does. A freed seat goes to the
+// oldest waitlisted member.
+func (s *enrollmentService) UnenrollUserFromActivity(userID uint, activityID uint, actor Actor) error {
 	var enrollment models.Enrollment
-	if err := s.db.Where("user_id = ? AND activity_id = ? AND status = ?", userID, activityID, "inscripto").
+	if err := s.db.Where("user_id = ? AND activity_id = ? AND status IN ?", userID, activityID, []string{"inscripto", "pendiente_pago", "lista_espera"}).
 		First(&enrollment).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrEnrollmentNotFound
@@ -96,27 +197,111 @@ func (s *enrollmentService) UnenrollUserFromActivity(userID uint, activityID uin
 		return err
 	}
 
-	if err := s.db.Model(&enrollment).Update("status", "cancelado").Error; err != nil {
+	return s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		previous := enrollment
+		if err := cancelEnrollments(tx, []uint{enrollment.ID}); err != nil {
+			return err
+		}
+		cancelled := previous
+		cancelled.Status, cancelled.HoldExpiresAt, cancelled.WaitlistedAt, cancelled.ScheduleConflict = "cancelado", nil, nil, false
+		if err := auditEnrollment(tx, actor, "enrollment.cancelled", &previous, &cancelled); err != nil {
+			return err
+		}
+		if enrollment.ScheduleConflict {
+			if err := refreshScheduleConflicts(tx, []uint{userID}); err != nil {
+				return err
+			}
+		}
+		if err := s.events.Record(tx, enrollmentEvent(events.EnrollmentCancelled, &enrollment)); err != nil {
+			return err
+		}
+		return s.waitlist.promote(tx, activityID)
+	})
+}
+
+// ListActivityEnrollments returns the active roster of an activity, including seats held for
+// payment, with user data preloaded.
+func (s *enrollmentService) ListActivityEnrollments(activityID uint) ([]models.Enrollment, error) {
+	var count int64
+	if err := s.db.Model(&models.Activity{}).Where("id = ?", activityID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrActivityNotFound
+	}
+
+	var enrollments []models.Enrollment
+	if err := seatHolders(s.db.Preload("User")).
+		Where("activity_id = ?", activityID).
+		Order("created_at ASC").
+		Find(&enrollments).Error; err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
+
+// cancelEnrollments cancels the given enrollments, whatever seat they held or waited for, and
+// settles their payments: pending checkouts are cancelled and payments already approved are
+// flagged for refund.
+func cancelEnrollments(tx *gorm.DB, enrollmentIDs []uint) error {
+	if len(enrollmentIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Enrollment{}).
+		Where("id IN ?", enrollmentIDs).
+		Updates(map[string]interface{}{"status": "cancelado", "hold_expires_at": nil, "waitlisted_at": nil, "schedule_conflict": false}).Error; err != nil {
 		return err
 	}
-	return nil
+	if err := tx.Model(&models.Payment{}).
+		Where("enrollment_id IN ? AND status = ?", enrollmentIDs, "pendiente").
+		Update("status", "cancelado").Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Payment{}).
+		Where("enrollment_id IN ? AND status = ?", enrollmentIDs, "aprobado").
+		Update("status", "a_reembolsar").Error
+}
+
+// auditEnrollment records an enrollment change made on behalf of the member; the member's own
+// changes are left out of the audit log.
+func auditEnrollment(tx *gorm.DB, actor Actor, action string, before, after *models.Enrollment) error {
+	if actor.isUser(after.UserID) {
+		return nil
+	}
+	return recordAudit(tx, actor, auditChange{
+		Action:     action,
+		EntityType: AuditEnrollment,
+		EntityID:   after.ID,
+		Before:     before,
+		After:      after,
+	})
 }
 
-func (s *enrollmentService) ensureNoScheduleConflict(userID uint, newActivity *models.Activity) error {
+func enrollmentEvent(eventType string, enrollment *models.Enrollment) events.Event {
+	evt := events.New(eventType)
+	evt.UserID = enrollment.UserID
+	evt.ActivityID = enrollment.ActivityID
+	evt.EnrollmentID = enrollment.ID
+	return evt
+}
+
+// ensureNoScheduleConflict fails with ErrScheduleConflict when newActivity overlaps with a seat
+// userID holds, confirmed or waiting for payment, as refreshScheduleConflicts counts them.
+func ensureNoScheduleConflict(db *gorm.DB, userID uint, newActivity *models.Activity) error {
 	var enrollments []models.Enrollment
-	if err := s.db.Preload("Activity").
-		Where("user_id = ? AND status = ?", userID, "inscripto").
+	if err := seatHolders(db.Preload("Activity")).
+		Where("user_id = ?", userID).
 		Find(&enrollments).Error; err != nil {
 		return err
 	}
 
 	for _, enrollment := range enrollments {
 		existing := enrollment.Activity
-		if existing.ID == 0 || existing.DayOfWeek != newActivity.DayOfWeek {
+		if existing.ID == 0 {
 			continue
 		}
 
-		overlaps, err := schedulesOverlap(existing.StartTime, existing.EndTime, newActivity.StartTime, newActivity.EndTime)
+		overlaps, err := activitiesOverlap(&existing, newActivity)
 		if err != nil {
 			return err
 		}
@@ -127,6 +312,45 @@ func (s *enrollmentService) ensureNoScheduleConflict(userID uint, newActivity *m
 	return nil
 }
 
+// refreshScheduleConflicts recomputes the schedule_conflict flag of every active enrollment of
+// the given users, after one of their activities changed schedule.
+func refreshScheduleConflicts(tx *gorm.DB, userIDs []uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var enrollments []models.Enrollment
+	if err := tx.Preload("Activity").
+		Where("user_id IN ? AND status IN ?", userIDs, []string{"inscripto", "pendiente_pago"}).
+		Find(&enrollments).Error; err != nil {
+		return err
+	}
+
+	for i, enrollment := range enrollments {
+		conflict := false
+		for j, other := range enrollments {
+			if i == j || other.UserID != enrollment.UserID {
+				continue
+			}
+			overlaps, err := activitiesOverlap(&enrollment.Activity, &other.Activity)
+			if err != nil {
+				return err
+			}
+			if overlaps {
+				conflict = true
+				break
+			}
+		}
+		if conflict != enrollment.ScheduleConflict {
+			if err := tx.Model(&models.Enrollment{}).
+				Where("id = ?", enrollment.ID).
+				Update("schedule_conflict", conflict).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func schedulesOverlap(startA, endA, startB, endB string) (bool, error) {
 	const layout = "15:04"
 	startTimeA, err := time.Parse(layout, startA)
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/payments/paymentfake"
+	"gorm.io/gorm"
+)
+
+type enrollmentTestEnv struct {
+	db          *gorm.DB
+	bus         *events.Bus
+	memberships *MembershipService
+	payments    *PaymentService
+	waitlist    *Waitlist
+	service     EnrollmentService
+	// events holds the type of every committed event, in order.
+	events []string
+	users  int
+}
+
+func newEnrollmentTestEnv(t *testing.T) *enrollmentTestEnv {
+	t.Helper()
+	db := dbtest.Open(t, &models.User{}, &models.Season{}, &models.Activity{}, &models.EligibilityRule{}, &models.Enrollment{},
+		&models.Payment{}, &models.MembershipPlan{}, &models.PlanCategoryQuota{}, &models.Membership{}, &models.AuditLog{})
+	env := &enrollmentTestEnv{db: db, bus: events.NewBus()}
+	env.bus.AfterCommit(func(evt events.Event) { env.events = append(env.events, evt.Type) })
+	env.memberships = NewMembershipService(db, false)
+	gateway := paymentfake.New("http://payments.test", "", "secret")
+	env.waitlist = NewWaitlist(db, env.memberships, env.bus, time.UTC, 15*time.Minute)
+	env.payments = NewPaymentService(db, gateway, nil, env.memberships, env.waitlist, env.bus, "ARS", 15*time.Minute)
+	env.service = NewEnrollmentService(db, env.memberships, env.payments, env.waitlist, env.bus, time.UTC)
+	return env
+}
+
+func (env *enrollmentTestEnv) user(t *testing.T) *models.User {
+	t.Helper()
+	env.users++
+	user := models.User{Name: "Socio", Email: fmt.Sprintf("socio%d@example.com", env.users), PasswordHash: "x", Role: "socio"}
+	mustCreate(t, env.db, &user)
+	return &user
+}
+
+func (env *enrollmentTestEnv) activity(t *testing.T, title string, capacity, priceCents int) *models.Activity {
+	t.Helper()
+	activity := models.Activity{Title: title, Category: "yoga", DayOfWeek: 1, StartTime: "10:00", EndTime: "11:00",
+		Capacity: capacity, Instructor: "Profe", PriceCents: priceCents}
+	activity.SetStatus(models.ActivityPublished)
+	mustCreate(t, env.db, &activity)
+	return &activity
+}
+
+func (env *enrollmentTestEnv) enrollment(t *testing.T, user *models.User, activity *models.Activity, status string) *models.Enrollment {
+	t.Helper()
+	enrollment := models.Enrollment{UserID: user.ID, ActivityID: activity.ID, Status: status}
+	if status == "lista_espera" {
+		now := time.Now()
+		enrollment.WaitlistedAt = &now
+	}
+	mustCreate(t, env.db, &enrollment)
+	return &enrollment
+}
+
+func (env *enrollmentTestEnv) payment(t *testing.T, enrollment *models.Enrollment, status string) *models.Payment {
+	t.Helper()
+	payment := models.Payment{UserID: enrollment.UserID, Purpose: "inscripcion", EnrollmentID: &enrollment.ID,
+		Description: "Clase", AmountCents: 1000, Currency: "ARS", Provider: "fake",
+		ProviderRef: fmt.Sprintf("ref-%d", enrollment.ID), Status: status, ExpiresAt: time.Now().Add(time.Hour)}
+	mustCreate(t, env.db, &payment)
+	return &payment
+}
+
+func (env *enrollmentTestEnv) status(t *testing.T, value interface{}, id uint) string {
+	t.Helper()
+	var status string
+	if err := env.db.Model(value).Where("id = ?", id).Pluck("status", &status).Error; err != nil {
+		t.Fatalf("reload %T %d: %v", value, id, err)
+	}
+	return status
+}
+
+func TestUnenrollFlagsPaidEnrollmentForRefund(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	user := env.user(t)
+	activity := env.activity(t, "Yoga", 10, 1000)
+	enrollment := env.enrollment(t, user, activity, "inscripto")
+	payment := env.payment(t, enrollment, "aprobado")
+
+	if err := env.service.UnenrollUserFromActivity(user.ID, activity.ID, UserActor(user.ID)); err != nil {
+		t.Fatalf("UnenrollUserFromActivity: %v", err)
+	}
+	if status := env.status(t, &models.Enrollment{}, enrollment.ID); status != "cancelado" {
+		t.Fatalf("enrollment status = %s, want cancelado", status)
+	}
+	if status := env.status(t, &models.Payment{}, payment.ID); status != "a_reembolsar" {
+		t.Fatalf("payment status = %s, want a_reembolsar", status)
+	}
+}
+
+func TestEnrollRejectsOverlapWithPaymentHold(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	user := env.user(t)
+	held := env.activity(t, "Yoga", 10, 1000)
+	overlapping := env.activity(t, "Pilates", 10, 1000)
+
+	if _, err := env.service.EnrollUserInActivity(user.ID, held.ID, UserActor(user.ID)); err != nil {
+		t.Fatalf("EnrollUserInActivity: %v", err)
+	}
+	if _, err := env.service.EnrollUserInActivity(user.ID, overlapping.ID, UserActor(user.ID)); !errors.Is(err, ErrScheduleConflict) {
+		t.Fatalf("EnrollUserInActivity over a live hold = %v, want %v", err, ErrScheduleConflict)
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/images"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrImageNotFound    = errors.New("image not found")
+	ErrImageTooLarge    = errors.New("image too large")
+	ErrUnsupportedImage = errors.New("unsupported image")
+	ErrInvalidImage     = errors.New("invalid image")
+)
+
+// activityImagePrefix starts the keys of every activity image, the only blobs served publicly.
+const activityImagePrefix = "activities/"
+
+// ImageVariant is one stored size of an activity image.
+type ImageVariant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ActivityImage describes the image just stored for an activity.
+type ActivityImage struct {
+	ImageURL     string         `json:"image_url"`
+	ThumbnailURL string         `json:"thumbnail_url"`
+	Variants     []ImageVariant `json:"variants"`
+}
+
+// ImageService stores uploaded activity images in a BlobStore. Each upload is kept under a key
+// derived from its content, "activities/{id}/{hash}/{variant}.jpg", so a URL always serves the
+// same bytes and can be cached forever.
+type ImageService struct {
+	db         *gorm.DB
+	events     *events.Bus
+	store      storage.BlobStore
+	publicPath string
+	maxBytes   int64
+}
+
+// NewImageService serves the stored images under publicPath (e.g. "/api/images") and accepts
+// uploads of up to maxBytes.
+func NewImageService(db *gorm.DB, bus *events.Bus, store storage.BlobStore, publicPath string, maxBytes int64) *ImageService {
+	return &ImageService{db: db, events: bus, store: store, publicPath: strings.TrimRight(publicPath, "/"), maxBytes: maxBytes}
+}
+
+// MaxBytes is the largest upload accepted.
+func (s *ImageService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// SetActivityImage validates data, stores its variants and makes them the image of the activity.
+// The variants of the previous upload are deleted.
+func (s *ImageService) SetActivityImage(ctx context.Context, activityID uint, data []byte, actor Actor) (*ActivityImage, error) {
+	if int64(len(data)) > s.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrImageTooLarge, len(data), s.maxBytes)
+	}
+	if err := s.db.Select("id").First(&models.Activity{}, activityID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrActivityNotFound
+		}
+		return nil, err
+	}
+
+	renditions, err := images.Process(data)
+	switch {
+	case errors.Is(err, images.ErrUnsupportedType):
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	case errors.Is(err, images.ErrTooLarge):
+		return nil, fmt.Errorf("%w: %v", ErrImageTooLarge, err)
+	case errors.Is(err, images.ErrInvalidImage):
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	case err != nil:
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	prefix := fmt.Sprintf("%s%d/%s", activityImagePrefix, activityID, hex.EncodeToString(sum[:8]))
+	result := &ActivityImage{}
+	for _, rendition := range renditions {
+		key := prefix + "/" + rendition.Variant + images.Extension
+		if err := s.store.Put(ctx, key, rendition.Data, images.ContentType); err != nil {
+			s.deleteVariants(ctx, prefix)
+			return nil, err
+		}
+		result.Variants = append(result.Variants, ImageVariant{
+			Name:   rendition.Variant,
+			URL:    s.url(key),
+			Width:  rendition.Width,
+			Height: rendition.Height,
+		})
+	}
+	result.ImageURL = result.Variants[0].URL
+	result.ThumbnailURL = result.Variants[len(result.Variants)-1].URL
+
+	previous, err := s.updateActivity(activityID, actor, "activity.image_changed", prefix, result.ImageURL, result.ThumbnailURL)
+	if err != nil {
+		if previous != prefix {
+			s.deleteVariants(ctx, prefix)
+		}
+		return nil, err
+	}
+	if previous != "" && previous != prefix {
+		s.deleteVariants(ctx, previous)
+	}
+	return result, nil
+}
+
+// RemoveActivityImage clears the image of an activity, uploaded or not, and deletes the stored
+// variants.
+func (s *ImageService) RemoveActivityImage(ctx context.Context, activityID uint, actor Actor) error {
+	previous, err := s.updateActivity(activityID, actor, "activity.image_removed", "", "", "")
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		s.deleteVariants(ctx, previous)
+	}
+	return nil
+}
+
+// DiscardImage deletes the variants stored under key, the ImageKey of an activity whose uploaded
+// image was replaced by other means. Failures are only logged.
+func (s *ImageService) DiscardImage(ctx context.Context, key string) {
+	if key != "" {
+		s.deleteVariants(ctx, key)
+	}
+}
+
+// CopyActivityImage gives an activity its own copy of the uploaded image stored under key, the
+// ImageKey of another activity, so that each one can later replace or drop its image alone.
+func (s *ImageService) CopyActivityImage(ctx context.Context, key string, activityID uint, actor Actor) error {
+	if !strings.HasPrefix(key, activityImagePrefix) {
+		return ErrImageNotFound
+	}
+	prefix := fmt.Sprintf("%s%d/%s", activityImagePrefix, activityID, path.Base(key))
+	for _, variant := range images.Variants {
+		name := "/" + variant.Name + images.Extension
+		if err := s.copyBlob(ctx, key+name, prefix+name); err != nil {
+			s.deleteVariants(ctx, prefix)
+			return err
+		}
+	}
+
+	imageURL := s.url(prefix + "/" + images.Variants[0].Name + images.Extension)
+	thumbnailURL := s.url(prefix + "/" + images.Variants[len(images.Variants)-1].Name + images.Extension)
+	previous, err := s.updateActivity(activityID, actor, "activity.image_changed", prefix, imageURL, thumbnailURL)
+	if err != nil {
+		if previous != prefix {
+			s.deleteVariants(ctx, prefix)
+		}
+		return err
+	}
+	if previous != "" && previous != prefix {
+		s.deleteVariants(ctx, previous)
+	}
+	return nil
+}
+
+func (s *ImageService) copyBlob(ctx context.Context, from, to string) error {
+	reader, info, err := s.store.Get(ctx, from)
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrImageNotFound, from)
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, to, data, info.ContentType)
+}
+
+// OpenImage opens a stored variant by the key in its URL. The caller closes the reader.
+func (s *ImageService) OpenImage(ctx context.Context, key string) (io.ReadCloser, *storage.BlobInfo, error) {
+	if !strings.HasPrefix(key, activityImagePrefix) || !storage.ValidKey(key) {
+		return nil, nil, ErrImageNotFound
+	}
+	reader, info, err := s.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, info, nil
+}
+
+// updateActivity points the activity at a new image key and URLs and returns the previous key.
+func (s *ImageService) updateActivity(activityID uint, actor Actor, action, key, imageURL, thumbnailURL string) (string, error) {
+	var previous string
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var activity models.Activity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, activityID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrActivityNotFound
+			}
+			return err
+		}
+		previous = activity.ImageKey
+		before := activity
+		if err := tx.Model(&activity).Updates(map[string]interface{}{
+			"image_key":     key,
+			"image_url":     imageURL,
+			"thumbnail_url": thumbnailURL,
+		}).Error; err != nil {
+			return err
+		}
+		activity.ImageKey, activity.ImageURL, activity.ThumbnailURL = key, imageURL, thumbnailURL
+		if err := recordAudit(tx, actor, auditChange{
+			Action:     action,
+			EntityType: AuditActivity,
+			EntityID:   activityID,
+			Before:     &before,
+			After:      &activity,
+			Snapshot:   true,
+		}); err != nil {
+			return err
+		}
+		evt := events.New(events.ActivityUpdated)
+		evt.ActivityID = activityID
+		return s.events.Record(tx, evt)
+	})
+	return previous, err
+}
+
+// deleteVariants removes every variant stored under prefix. The image is no longer referenced,
+// so failures only leave garbage behind and are logged.
+func (s *ImageService) deleteVariants(ctx context.Context, prefix string) {
+	for _, variant := range images.Variants {
+		key := prefix + "/" + variant.Name + images.Extension
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("images: could not delete %s: %v", key, err)
+		}
+	}
+}
+
+func (s *ImageService) url(key string) string {
+	return s.publicPath + "/" + key
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/pdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// InvoiceService issues receipts for approved payments and renders them. Printed dates are
+// in the gym's time zone, location.
+type InvoiceService struct {
+	db         *gorm.DB
+	branch     string
+	issuerName string
+	location   *time.Location
+}
+
+func NewInvoiceService(db *gorm.DB, branch, issuerName string, location *time.Location) *InvoiceService {
+	return &InvoiceService{db: db, branch: branch, issuerName: issuerName, location: location}
+}
+
+// IssueForPayment creates the invoice of an approved payment on the caller's transaction.
+// The branch sequence row stays locked until that transaction ends, so a rollback never
+// leaves a gap and concurrent payments get consecutive numbers.
+func (s *InvoiceService) IssueForPayment(tx *gorm.DB, payment *models.Payment) (*models.Invoice, error) {
+	var user models.User
+	if err := tx.First(&user, payment.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	sequence := models.InvoiceSequence{Branch: s.branch}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sequence).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("branch = ?", s.branch).
+		First(&sequence).Error; err != nil {
+		return nil, err
+	}
+	number := sequence.LastNumber + 1
+	if err := tx.Model(&sequence).Update("last_number", number).Error; err != nil {
+		return nil, err
+	}
+
+	invoice := models.Invoice{
+		Branch:        s.branch,
+		Number:        number,
+		Code:          fmt.Sprintf("%s-%08d", s.branch, number),
+		UserID:        user.ID,
+		PaymentID:     payment.ID,
+		CustomerName:  user.Name,
+		CustomerEmail: user.Email,
+		Currency:      payment.Currency,
+		TotalCents:    payment.AmountCents,
+		IssuedAt:      time.Now(),
+		Lines: []models.InvoiceLine{{
+			Description:  payment.Description,
+			Quantity:     1,
+			UnitCents:    payment.AmountCents,
+			TotalCents:   payment.AmountCents,
+			EnrollmentID: payment.EnrollmentID,
+			MembershipID: payment.MembershipID,
+		}},
+	}
+	if err := tx.Omit("User").Create(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (s *InvoiceService) ListUserInvoices(userID uint) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	if err := s.db.Preload("Lines").
+		Where("user_id = ?", userID).
+		Order("issued_at DESC").
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// GetUserInvoice returns an invoice only if it belongs to userID.
+func (s *InvoiceService) GetUserInvoice(userID, id uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := s.db.Preload("Lines").
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// RenderJSON returns the canonical JSON document of an invoice, as offered for download.
+func (s *InvoiceService) RenderJSON(invoice *models.Invoice) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Issuer string `json:"issuer"`
+		*models.Invoice
+	}{Issuer: s.issuerName, Invoice: invoice}, "", "  ")
+}
+
+// RenderPDF lays out an invoice as a one-page A4 receipt.
+func (s *InvoiceService) RenderPDF(invoice *models.Invoice) []byte {
+	const left, right = 56.0, pdf.PageWidth - 56.0
+	doc := pdf.New()
+	y := pdf.PageHeight - 72
+
+	doc.Text(left, y, 18, true, s.issuerName)
+	doc.TextRight(right, y, 12, true, "Comprobante "+invoice.Code)
+	y -= 20
+	doc.Text(left, y, 10, false, "Sucursal "+invoice.Branch)
+	doc.TextRight(right, y, 10, false, "Fecha: "+invoice.IssuedAt.In(s.location).Format("02/01/2006 15:04"))
+
+	y -= 36
+	doc.Text(left, y, 11, true, "Cliente")
+	y -= 16
+	doc.Text(left, y, 10, false, invoice.CustomerName)
+	y -= 14
+	doc.Text(left, y, 10, false, invoice.CustomerEmail)
+
+	y -= 32
+	doc.Text(left, y, 10, true, "Descripción")
+	doc.TextRight(right-170, y, 10, true, "Cant.")
+	doc.TextRight(right-90, y, 10, true, "Unitario")
+	doc.TextRight(right, y, 10, true, "Importe")
+	y -= 6
+	doc.Line(left, y, right, y)
+	for _, line := range invoice.Lines {
+		y -= 16
+		doc.Text(left, y, 10, false, line.Description)
+		doc.TextRight(right-170, y, 10, false, fmt.Sprint(line.Quantity))
+		doc.TextRight(right-90, y, 10, false, formatCents(line.UnitCents))
+		doc.TextRight(right, y, 10, false, formatCents(line.TotalCents))
+	}
+	y -= 8
+	doc.Line(left, y, right, y)
+	y -= 18
+	doc.TextRight(right, y, 12, true, fmt.Sprintf("Total %s %s", invoice.Currency, formatCents(invoice.TotalCents)))
+
+	return doc.Bytes()
+}
+
+// formatCents renders an amount with Spanish separators, e.g. 1234567 -> "12.345,67".
+func formatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	units := fmt.Sprint(cents / 100)
+	var grouped strings.Builder
+	for i, digit := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+	return fmt.Sprintf("%s%s,%02d", sign, grouped.String(), cents%100)
+}
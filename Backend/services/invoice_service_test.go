@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+func TestFormatCents(t *testing.T) {
+	tests := []struct {
+		cents int
+		want  string
+	}{
+		{0, "0,00"},
+		{5, "0,05"},
+		{100, "1,00"},
+		{99999, "999,99"},
+		{100000, "1.000,00"},
+		{1234567, "12.345,67"},
+		{100000000, "1.000.000,00"},
+		{-1234567, "-12.345,67"},
+	}
+	for _, tt := range tests {
+		if got := formatCents(tt.cents); got != tt.want {
+			t.Fatalf("formatCents(%d) = %q, want %q", tt.cents, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceNumbersAreConsecutivePerBranch(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.Payment{}, &models.InvoiceSequence{}, &models.Invoice{}, &models.InvoiceLine{})
+	user := models.User{Name: "Socio", Email: "socio@example.com", PasswordHash: "x", Role: "socio"}
+	mustCreate(t, db, &user)
+
+	payments := 0
+	issue := func(service *InvoiceService, rollback bool) *models.Invoice {
+		t.Helper()
+		payments++
+		payment := models.Payment{UserID: user.ID, Purpose: "membresia", Description: "Plan mensual", AmountCents: 1500000,
+			Currency: "ARS", Provider: "fake", ProviderRef: fmt.Sprintf("ref-%d", payments), Status: "aprobado", ExpiresAt: time.Now()}
+		mustCreate(t, db, &payment)
+		var invoice *models.Invoice
+		errRollback := fmt.Errorf("rollback")
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if invoice, err = service.IssueForPayment(tx, &payment); err != nil {
+				return err
+			}
+			if rollback {
+				return errRollback
+			}
+			return nil
+		})
+		if rollback && err == errRollback {
+			return nil
+		}
+		if err != nil {
+			t.Fatalf("IssueForPayment: %v", err)
+		}
+		return invoice
+	}
+
+	downtown := NewInvoiceService(db, "0001", "Gimnasio", time.UTC)
+	uptown := NewInvoiceService(db, "0002", "Gimnasio", time.UTC)
+	if invoice := issue(downtown, false); invoice.Number != 1 || invoice.Code != "0001-00000001" {
+		t.Fatalf("first invoice = %d %s, want 1 0001-00000001", invoice.Number, invoice.Code)
+	}
+	issue(downtown, true)
+	if invoice := issue(uptown, false); invoice.Number != 1 || invoice.Code != "0002-00000001" {
+		t.Fatalf("first invoice of another branch = %d %s, want 1 0002-00000001", invoice.Number, invoice.Code)
+	}
+	if invoice := issue(downtown, false); invoice.Number != 2 {
+		t.Fatalf("invoice after a rolled back one = %d, want 2", invoice.Number)
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	location, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	service := NewInvoiceService(nil, "0001", "Gimnasio", location)
+	invoice := &models.Invoice{Branch: "0001", Number: 42, Code: "0001-00000042", CustomerName: "Socio",
+		CustomerEmail: "socio@example.com", Currency: "ARS", TotalCents: 1234567,
+		IssuedAt: time.Date(2024, 5, 10, 2, 30, 0, 0, time.UTC),
+		Lines:    []models.InvoiceLine{{Description: "Natación (mayo)", Quantity: 1, UnitCents: 1234567, TotalCents: 1234567}}}
+
+	doc := service.RenderPDF(invoice)
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatalf("RenderPDF did not produce a complete PDF file")
+	}
+	for _, want := range []string{"Comprobante 0001-00000042", "Total ARS 12.345,67", "Nataci\\363n \\(mayo\\)",
+		// 02:30 UTC is still the previous evening in Buenos Aires.
+		"Fecha: 09/05/2024 23:30"} {
+		if !bytes.Contains(doc, []byte(want)) {
+			t.Fatalf("PDF does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPlanNotFound       = errors.New("membership plan not found")
+	ErrPlanInactive       = errors.New("membership plan is not active")
+	ErrInvalidPlan        = errors.New("invalid membership plan")
+	ErrMembershipRequired = errors.New("an active membership is required")
+	ErrQuotaExceeded      = errors.New("membership weekly quota exceeded")
+)
+
+// PlanInput carries the editable fields of a plan.
+type PlanInput struct {
+	Name           string
+	Description    string
+	WeeklyQuota    int
+	DurationDays   int
+	PriceCents     int
+	IsActive       bool
+	CategoryQuotas map[string]int
+}
+
+// QuotaUsage reports a limit, how much of it is used and what remains. Limit 0 means unlimited.
+type QuotaUsage struct {
+	Category  string `json:"category,omitempty"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining *int   `json:"remaining"`
+	Unlimited bool   `json:"unlimited"`
+}
+
+// QuotaStatus is the member-facing summary of their current membership.
+type QuotaStatus struct {
+	Membership *models.Membership `json:"membership"`
+	Weekly     QuotaUsage         `json:"weekly"`
+	Categories []QuotaUsage       `json:"categories"`
+}
+
+// MembershipService manages plans, memberships and weekly enrollment quotas.
+// Each active enrollment takes one class per week, so quotas compare against active enrollments.
+type MembershipService struct {
+	db                *gorm.DB
+	requireMembership bool
+}
+
+func NewMembershipService(db *gorm.DB, requireMembership bool) *MembershipService {
+	return &MembershipService{db: db, requireMembership: requireMembership}
+}
+
+func (s *MembershipService) ListPlans(includeInactive bool) ([]models.MembershipPlan, error) {
+	query := s.db.Preload("CategoryQuotas").Order("name ASC")
+	if !includeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	var plans []models.MembershipPlan
+	if err := query.Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+func (s *MembershipService) CreatePlan(input PlanInput) (*models.MembershipPlan, error) {
+	if err := validatePlanInput(input); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuotaCategories(input.CategoryQuotas); err != nil {
+		return nil, err
+	}
+	plan := models.MembershipPlan{
+		Name:           input.Name,
+		Description:    input.Description,
+		WeeklyQuota:    input.WeeklyQuota,
+		DurationDays:   input.DurationDays,
+		PriceCents:     input.PriceCents,
+		IsActive:       input.IsActive,
+		CategoryQuotas: toCategoryQuotas(0, input.CategoryQuotas),
+	}
+	if err := s.db.Create(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// UpdatePlan replaces the plan fields. Existing memberships see the new quotas immediately.
+func (s *MembershipService) UpdatePlan(id uint, input PlanInput) (*models.MembershipPlan, error) {
+	if err := validatePlanInput(input); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuotaCategories(input.CategoryQuotas); err != nil {
+		return nil, err
+	}
+
+	var plan models.MembershipPlan
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&plan, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPlanNotFound
+			}
+			return err
+		}
+		plan.Name = input.Name
+		plan.Description = input.Description
+		plan.WeeklyQuota = input.WeeklyQuota
+		plan.DurationDays = input.DurationDays
+		plan.PriceCents = input.PriceCents
+		plan.IsActive = input.IsActive
+		if err := tx.Save(&plan).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.PlanCategoryQuota{}).Error; err != nil {
+			return err
+		}
+		plan.CategoryQuotas = toCategoryQuotas(plan.ID, input.CategoryQuotas)
+		if len(plan.CategoryQuotas) > 0 {
+			return tx.Create(&plan.CategoryQuotas).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// AssignPlan gives userID a membership starting at startsAt. Without endsAt the plan duration
+// applies. Memberships that would overlap the new one are closed at its start.
+func (s *MembershipService) AssignPlan(userID, planID uint, startsAt time.Time, endsAt *time.Time, actor Actor) (*models.Membership, error) {
+	var membership *models.Membership
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		membership, err = assignPlan(tx, userID, planID, startsAt, endsAt)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditChange{
+			Action:     "user.membership_assigned",
+			EntityType: AuditUser,
+			EntityID:   userID,
+			Extra: map[string]FieldChange{"membership": {After: map[string]interface{}{
+				"id":        membership.ID,
+				"plan_id":   membership.PlanID,
+				"starts_at": membership.StartsAt,
+				"ends_at":   membership.EndsAt,
+			}}},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// GetPlan returns a plan with its category quotas.
+func (s *MembershipService) GetPlan(id uint) (*models.MembershipPlan, error) {
+	var plan models.MembershipPlan
+	if err := s.db.Preload("CategoryQuotas").First(&plan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (s *MembershipService) ListUserMemberships(userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	if err := s.db.Preload("Plan.CategoryQuotas").
+		Where("user_id = ?", userID).
+		Order("starts_at DESC").
+		Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// ActiveMembership returns the membership in force at the given time, or nil.
+func (s *MembershipService) ActiveMembership(userID uint, at time.Time) (*models.Membership, error) {
+	return activeMembership(s.db, userID, at)
+}
+
+// QuotaStatus summarises the caller's current plan and remaining weekly classes. The usage is that
+// of the busiest week, as checked when enrolling in an activity held all year round, so a class
+// left in the status is left for any activity.
+func (s *MembershipService) QuotaStatus(userID uint) (*QuotaStatus, error) {
+	membership, err := activeMembership(s.db, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	status := &QuotaStatus{Membership: membership, Categories: []QuotaUsage{}}
+	if membership == nil {
+		return status, nil
+	}
+
+	total, byCategory, err := countActiveEnrollments(s.db, userID, nil, time.Now(), 0)
+	if err != nil {
+		return nil, err
+	}
+	status.Weekly = newQuotaUsage("", membership.Plan.WeeklyQuota, total)
+	for _, quota := range membership.Plan.CategoryQuotas {
+		status.Categories = append(status.Categories, newQuotaUsage(quota.Category, quota.WeeklyQuota, byCategory[quota.Category]))
+	}
+	return status, nil
+}
+
+// CheckQuota verifies that enrolling userID in activity keeps them within their plan.
+// It runs on the caller's transaction so the count is consistent with the insert.
+func (s *MembershipService) CheckQuota(tx *gorm.DB, userID uint, activity *models.Activity) error {
+	return s.checkQuota(tx, userID, activity, time.Now(), 0)
+}
+
+// checkQuota is CheckQuota evaluated at now, leaving exceptID out of the count so a held seat can
+// be checked again before it is confirmed.
+func (s *MembershipService) checkQuota(tx *gorm.DB, userID uint, activity *models.Activity, now time.Time, exceptID uint) error {
+	membership, err := activeMembership(tx, userID, now)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		if s.requireMembership {
+			return ErrMembershipRequired
+		}
+		return nil
+	}
+
+	total, byCategory, err := countActiveEnrollments(tx, userID, activity.SeasonID, now, exceptID)
+	if err != nil {
+		return err
+	}
+	if limit := membership.Plan.WeeklyQuota; limit > 0 && total >= limit {
+		return ErrQuotaExceeded
+	}
+	for _, quota := range membership.Plan.CategoryQuotas {
+		if strings.EqualFold(quota.Category, activity.Category) && byCategory[quota.Category] >= quota.WeeklyQuota {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// assignPlan runs AssignPlan on the caller's transaction so payments can activate plans atomically.
+func assignPlan(tx *gorm.DB, userID, planID uint, startsAt time.Time, endsAt *time.Time) (*models.Membership, error) {
+	var plan models.MembershipPlan
+	if err := tx.Preload("CategoryQuotas").First(&plan, planID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	if !plan.IsActive {
+		return nil, ErrPlanInactive
+	}
+
+	var users int64
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Count(&users).Error; err != nil {
+		return nil, err
+	}
+	if users == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	end := startsAt.AddDate(0, 0, plan.DurationDays)
+	if endsAt != nil {
+		end = *endsAt
+	}
+	if !end.After(startsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPlan)
+	}
+
+	if err := tx.Model(&models.Membership{}).
+		Where("user_id = ? AND status = ? AND ends_at > ?", userID, "activa", startsAt).
+		Update("ends_at", startsAt).Error; err != nil {
+		return nil, err
+	}
+
+	membership := models.Membership{
+		UserID:   userID,
+		PlanID:   plan.ID,
+		StartsAt: startsAt,
+		EndsAt:   end,
+		Status:   "activa",
+		Plan:     plan,
+	}
+	if err := tx.Omit("Plan").Create(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func activeMembership(db *gorm.DB, userID uint, at time.Time) (*models.Membership, error) {
+	var membership models.Membership
+	err := db.Preload("Plan.CategoryQuotas").
+		Where("user_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?", userID, "activa", at, at).
+		Order("starts_at DESC").
+		First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// countActiveEnrollments counts the seats userID holds at now in a week, in total and per
+// category: confirmed enrollments and payment holds that have not expired, so a pending payment
+// cannot be used to go over the quota. The week of a season holds its activities and those held
+// all year round; the enrollments of another season take no place in it. With seasonID nil, as
+// for an activity held all year round, which takes a place in the week of every season, the
+// busiest week counts. exceptID, when set, is left out of the count.
+func countActiveEnrollments(db *gorm.DB, userID uint, seasonID *uint, now time.Time, exceptID uint) (int, map[string]int, error) {
+	type row struct {
+		SeasonID *uint
+		Category string
+		Count    int
+	}
+	query := seatHoldersAt(db.Model(&models.Enrollment{}), now).
+		Select("activities.season_id AS season_id, activities.category AS category, COUNT(*) AS count").
+		Joins("JOIN activities ON activities.id = enrollments.activity_id").
+		Where("enrollments.user_id = ?", userID)
+	if exceptID != 0 {
+		query = query.Where("enrollments.id <> ?", exceptID)
+	}
+	if seasonID != nil {
+		query = query.Where("activities.season_id IS NULL OR activities.season_id = ?", *seasonID)
+	}
+	var rows []row
+	if err := query.Group("activities.season_id, activities.category").Scan(&rows).Error; err != nil {
+		return 0, nil, err
+	}
+
+	// Seats held all year round take a place in every week; each season only in its own.
+	total := 0
+	byCategory := make(map[string]int, len(rows))
+	seasonTotals := make(map[uint]int)
+	seasonCategories := make(map[uint]map[string]int)
+	for _, r := range rows {
+		category := strings.ToLower(r.Category)
+		if r.SeasonID == nil {
+			total += r.Count
+			byCategory[category] += r.Count
+			continue
+		}
+		seasonTotals[*r.SeasonID] += r.Count
+		if seasonCategories[*r.SeasonID] == nil {
+			seasonCategories[*r.SeasonID] = make(map[string]int)
+		}
+		seasonCategories[*r.SeasonID][category] += r.Count
+	}
+	busiest := 0
+	for _, count := range seasonTotals {
+		if count > busiest {
+			busiest = count
+		}
+	}
+	total += busiest
+	busiestByCategory := make(map[string]int)
+	for _, categories := range seasonCategories {
+		for category, count := range categories {
+			if count > busiestByCategory[category] {
+				busiestByCategory[category] = count
+			}
+		}
+	}
+	for category, count := range busiestByCategory {
+		byCategory[category] += count
+	}
+	return total, byCategory, nil
+}
+
+func newQuotaUsage(category string, limit, used int) QuotaUsage {
+	usage := QuotaUsage{Category: category, Limit: limit, Used: used, Unlimited: limit == 0}
+	if limit > 0 {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.Remaining = &remaining
+	}
+	return usage
+}
+
+func validatePlanInput(input PlanInput) error {
+	if strings.TrimSpace(input.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPlan)
+	}
+	if input.WeeklyQuota < 0 {
+		return fmt.Errorf("%w: weekly_quota cannot be negative", ErrInvalidPlan)
+	}
+	if input.PriceCents < 0 {
+		return fmt.Errorf("%w: price_cents cannot be negative", ErrInvalidPlan)
+	}
+	if input.DurationDays <= 0 {
+		return fmt.Errorf("%w: duration_days must be positive", ErrInvalidPlan)
+	}
+	for category, quota := range input.CategoryQuotas {
+		if strings.TrimSpace(category) == "" || quota <= 0 {
+			return fmt.Errorf("%w: category quotas need a category and a positive quota", ErrInvalidPlan)
+		}
+	}
+	return nil
+}
+
+// checkQuotaCategories makes sure every quota names a category of the catalogue by its slug.
+func (s *MembershipService) checkQuotaCategories(quotas map[string]int) error {
+	for category := range quotas {
+		var count int64
+		if err := s.db.Model(&models.Category{}).Where("slug = ?", strings.ToLower(strings.TrimSpace(category))).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: unknown category %q", ErrInvalidPlan, category)
+		}
+	}
+	return nil
+}
+
+func toCategoryQuotas(planID uint, quotas map[string]int) []models.PlanCategoryQuota {
+	result := make([]models.PlanCategoryQuota, 0, len(quotas))
+	for category, quota := range quotas {
+		result = append(result, models.PlanCategoryQuota{
+			PlanID:      planID,
+			Category:    strings.ToLower(strings.TrimSpace(category)),
+			WeeklyQuota: quota,
+		})
+	}
+	return result
+}
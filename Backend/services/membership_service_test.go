@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+type quotaTestEnv struct {
+	db          *gorm.DB
+	memberships *MembershipService
+	payments    *PaymentService
+	user        models.User
+}
+
+// newQuotaTestEnv seeds a member holding an active plan of weeklyQuota classes.
+func newQuotaTestEnv(t *testing.T, weeklyQuota int) *quotaTestEnv {
+	t.Helper()
+	db := dbtest.Open(t, &models.User{}, &models.Activity{}, &models.Enrollment{},
+		&models.MembershipPlan{}, &models.PlanCategoryQuota{}, &models.Membership{})
+	env := &quotaTestEnv{db: db, memberships: NewMembershipService(db, true)}
+	bus := events.NewBus()
+	env.payments = NewPaymentService(db, nil, nil, env.memberships, NewWaitlist(db, env.memberships, bus, time.UTC, 15*time.Minute), bus, "ARS", 15*time.Minute)
+
+	env.user = models.User{Name: "Socio", Email: "socio@example.com", PasswordHash: "x", Role: "socio"}
+	mustCreate(t, db, &env.user)
+	plan := models.MembershipPlan{Name: "Plan", WeeklyQuota: weeklyQuota, DurationDays: 30, IsActive: true}
+	mustCreate(t, db, &plan)
+	now := time.Now()
+	mustCreate(t, db, &models.Membership{UserID: env.user.ID, PlanID: plan.ID, StartsAt: now.Add(-time.Hour), EndsAt: now.AddDate(0, 0, 30), Status: "activa"})
+	return env
+}
+
+func (env *quotaTestEnv) activity(t *testing.T, title string) *models.Activity {
+	t.Helper()
+	activity := models.Activity{Title: title, Category: "yoga", DayOfWeek: 1, StartTime: "10:00", EndTime: "11:00",
+		Capacity: 10, Instructor: "Profe", PriceCents: 1000}
+	activity.SetStatus(models.ActivityPublished)
+	mustCreate(t, env.db, &activity)
+	return &activity
+}
+
+func (env *quotaTestEnv) enrollment(t *testing.T, activity *models.Activity, status string, holdUntil *time.Time) *models.Enrollment {
+	t.Helper()
+	enrollment := models.Enrollment{UserID: env.user.ID, ActivityID: activity.ID, Status: status, HoldExpiresAt: holdUntil}
+	mustCreate(t, env.db, &enrollment)
+	return &enrollment
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
+
+func TestCheckQuotaCountsPaymentHolds(t *testing.T) {
+	env := newQuotaTestEnv(t, 1)
+	held := env.activity(t, "Yoga lunes")
+	next := env.activity(t, "Yoga martes")
+
+	holdUntil := time.Now().Add(10 * time.Minute)
+	hold := env.enrollment(t, held, "pendiente_pago", &holdUntil)
+	if err := env.memberships.CheckQuota(env.db, env.user.ID, next); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("CheckQuota with a live hold = %v, want %v", err, ErrQuotaExceeded)
+	}
+
+	lapsed := time.Now().Add(-time.Minute)
+	if err := env.db.Model(hold).Update("hold_expires_at", lapsed).Error; err != nil {
+		t.Fatalf("lapse hold: %v", err)
+	}
+	if err := env.memberships.CheckQuota(env.db, env.user.ID, next); err != nil {
+		t.Fatalf("CheckQuota with a lapsed hold = %v, want nil", err)
+	}
+}
+
+func TestConfirmEnrollmentChecksQuotaAgain(t *testing.T) {
+	env := newQuotaTestEnv(t, 1)
+	held := env.activity(t, "Yoga lunes")
+
+	holdUntil := time.Now().Add(10 * time.Minute)
+	hold := env.enrollment(t, held, "pendiente_pago", &holdUntil)
+	confirmed := confirmInTx(t, env, hold.ID)
+	if !confirmed {
+		t.Fatalf("a live hold within the quota should be confirmed")
+	}
+
+	// A hold that lapsed while the member took another class no longer fits in the plan.
+	lapsedActivity := env.activity(t, "Yoga martes")
+	lapsed := env.enrollment(t, lapsedActivity, "expirado", nil)
+	if confirmInTx(t, env, lapsed.ID) {
+		t.Fatalf("a lapsed hold over the quota should not be confirmed")
+	}
+	var reloaded models.Enrollment
+	if err := env.db.First(&reloaded, lapsed.ID).Error; err != nil {
+		t.Fatalf("reload enrollment: %v", err)
+	}
+	if reloaded.Status != "expirado" {
+		t.Fatalf("status = %s, want expirado", reloaded.Status)
+	}
+}
+
+func confirmInTx(t *testing.T, env *quotaTestEnv, enrollmentID uint) bool {
+	t.Helper()
+	var confirmed bool
+	err := env.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		_, confirmed, err = env.payments.confirmEnrollment(tx, enrollmentID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("confirmEnrollment: %v", err)
+	}
+	return confirmed
+}
+
+func TestQuotaStatusMatchesEnrollment(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	user := env.user(t)
+	plan := models.MembershipPlan{Name: "Una clase", WeeklyQuota: 1, DurationDays: 30, IsActive: true}
+	mustCreate(t, env.db, &plan)
+	now := time.Now()
+	mustCreate(t, env.db, &models.Membership{UserID: user.ID, PlanID: plan.ID, StartsAt: now.Add(-time.Hour), EndsAt: now.AddDate(0, 0, 30), Status: "activa"})
+
+	newSeason := func(name, startsOn, endsOn string) *models.Season {
+		season := models.Season{Name: name, StartsOn: startsOn, EndsOn: endsOn}
+		mustCreate(t, env.db, &season)
+		return &season
+	}
+	today := now.Format(sessionDateLayout)
+	current := newSeason("Actual", now.AddDate(0, -1, 0).Format(sessionDateLayout), now.AddDate(0, 2, 0).Format(sessionDateLayout))
+	next := newSeason("Siguiente", now.AddDate(0, 3, 0).Format(sessionDateLayout), now.AddDate(0, 6, 0).Format(sessionDateLayout))
+	activity := func(title string, day int, season *models.Season) *models.Activity {
+		activity := env.activity(t, title, 10, 0)
+		updates := map[string]interface{}{"day_of_week": day}
+		if season != nil {
+			updates["season_id"] = season.ID
+			activity.SeasonID = &season.ID
+		}
+		if err := env.db.Model(activity).Updates(updates).Error; err != nil {
+			t.Fatalf("update activity: %v", err)
+		}
+		return activity
+	}
+	enroll := func(activity *models.Activity) error {
+		_, err := env.service.EnrollUserInActivity(user.ID, activity.ID, UserActor(user.ID))
+		return err
+	}
+	remaining := func() int {
+		status, err := env.memberships.QuotaStatus(user.ID)
+		if err != nil {
+			t.Fatalf("QuotaStatus: %v", err)
+		}
+		return *status.Weekly.Remaining
+	}
+
+	if err := enroll(activity("Yoga", 1, current)); err != nil {
+		t.Fatalf("first class of the season on %s: %v", today, err)
+	}
+	if left := remaining(); left != 0 {
+		t.Fatalf("remaining after the only class of the plan = %d, want 0", left)
+	}
+	if err := enroll(activity("Pilates", 2, current)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second class of the same season = %v, want %v", err, ErrQuotaExceeded)
+	}
+	if err := enroll(activity("Natación", 3, nil)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("class held all year round = %v, want %v", err, ErrQuotaExceeded)
+	}
+	// The next season has a week of its own.
+	if err := enroll(activity("Spinning", 4, next)); err != nil {
+		t.Fatalf("first class of the next season: %v", err)
+	}
+	if left := remaining(); left != 0 {
+		t.Fatalf("remaining with a class in each season = %d, want 0", left)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxReminderLeadHours bounds reminder lead times to one week, the span of a weekly schedule.
+const maxReminderLeadHours = 168
+
+var ErrInvalidPreference = errors.New("invalid notification preference")
+
+// PreferenceView is a user's preferences with the server default they fall back to.
+type PreferenceView struct {
+	models.NotificationPreference
+	DefaultReminderLeadHours int `json:"default_reminder_lead_hours"`
+}
+
+// NotificationPreferenceService reads and stores per-user notification settings.
+type NotificationPreferenceService struct {
+	db               *gorm.DB
+	defaultLeadHours int
+}
+
+func NewNotificationPreferenceService(db *gorm.DB, defaultLeadHours int) *NotificationPreferenceService {
+	return &NotificationPreferenceService{db: db, defaultLeadHours: defaultLeadHours}
+}
+
+// GetPreferences returns the stored preferences or the defaults when the user has none.
+func (s *NotificationPreferenceService) GetPreferences(userID uint) (*PreferenceView, error) {
+	pref := models.NotificationPreference{UserID: userID, EmailEnabled: true, RemindersEnabled: true}
+	if err := s.db.Where("user_id = ?", userID).First(&pref).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &PreferenceView{NotificationPreference: pref, DefaultReminderLeadHours: s.defaultLeadHours}, nil
+}
+
+// UpdatePreferences replaces the user's preferences. A nil lead time restores the default.
+func (s *NotificationPreferenceService) UpdatePreferences(userID uint, emailEnabled, remindersEnabled bool, leadHours *int) (*PreferenceView, error) {
+	if leadHours != nil && (*leadHours < 1 || *leadHours > maxReminderLeadHours) {
+		return nil, fmt.Errorf("%w: reminder_lead_hours must be between 1 and %d", ErrInvalidPreference, maxReminderLeadHours)
+	}
+
+	pref := models.NotificationPreference{
+		UserID:            userID,
+		EmailEnabled:      emailEnabled,
+		RemindersEnabled:  remindersEnabled,
+		ReminderLeadHours: leadHours,
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email_enabled", "reminders_enabled", "reminder_lead_hours", "updated_at"}),
+	}).Create(&pref).Error; err != nil {
+		return nil, err
+	}
+	return s.GetPreferences(userID)
+}
@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/config"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCDisabled         = errors.New("oidc login is not configured")
+	ErrOIDCInvalidState     = errors.New("oidc state is invalid or expired")
+	ErrOIDCExchangeFailed   = errors.New("oidc code exchange failed")
+	ErrOIDCInvalidIDToken   = errors.New("oidc id token is invalid")
+	ErrOIDCEmailNotVerified = errors.New("oidc email is not verified")
+
+	oidcStateTTL = 10 * time.Minute
+)
+
+// OIDCService implements the authorization-code flow with PKCE against a generic provider.
+type OIDCService struct {
+	db         *gorm.DB
+	cfg        *config.Config
+	events     *events.Bus
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	jwks      map[string]interface{}
+	pending   map[string]oidcPendingLogin
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcPendingLogin struct {
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+type oidcIDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func NewOIDCService(db *gorm.DB, cfg *config.Config, bus *events.Bus) *OIDCService {
+	return &OIDCService{
+		db:         db,
+		cfg:        cfg,
+		events:     bus,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		jwks:       map[string]interface{}{},
+		pending:    map[string]oidcPendingLogin{},
+	}
+}
+
+// Enabled reports whether an issuer has been configured.
+func (s *OIDCService) Enabled() bool {
+	return s.cfg.OIDCIssuer != ""
+}
+
+// BeginLogin stores a fresh state/nonce/verifier triple and returns the provider URL to redirect to.
+func (s *OIDCService) BeginLogin(ctx context.Context) (string, error) {
+	if !s.Enabled() {
+		return "", ErrOIDCDisabled
+	}
+	discovery, err := s.loadDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomURLToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomURLToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomURLToken(48)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	for key, login := range s.pending {
+		if now.After(login.ExpiresAt) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[state] = oidcPendingLogin{Nonce: nonce, CodeVerifier: verifier, ExpiresAt: now.Add(oidcStateTTL)}
+	s.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.cfg.OIDCClientID},
+		"redirect_uri":          {s.cfg.OIDCRedirectURL},
+		"scope":                 {s.cfg.OIDCScopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// CompleteLogin redeems the authorization code, verifies the ID token and returns the linked user.
+func (s *OIDCService) CompleteLogin(ctx context.Context, state, code string) (*models.User, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.ExpiresAt) {
+		return nil, ErrOIDCInvalidState
+	}
+
+	discovery, err := s.loadDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, err := s.exchangeCode(ctx, discovery, code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.verifyIDToken(ctx, discovery, rawIDToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return s.linkUser(discovery.Issuer, claims)
+}
+
+func (s *OIDCService) exchangeCode(ctx context.Context, discovery *oidcDiscovery, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {s.cfg.OIDCRedirectURL},
+		"client_id":     {s.cfg.OIDCClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.OIDCClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.OIDCClientID), url.QueryEscape(s.cfg.OIDCClientSecret))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCExchangeFailed, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCExchangeFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status %d: %s", ErrOIDCExchangeFailed, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCExchangeFailed, err)
+	}
+	if tokenResponse.IDToken == "" {
+		return "", fmt.Errorf("%w: response without id_token", ErrOIDCExchangeFailed)
+	}
+	return tokenResponse.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, raw, nonce string) (*oidcIDTokenClaims, error) {
+	claims := &oidcIDTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.providerKey(ctx, discovery, kid)
+	},
+		jwt.WithValidMethods([]string{security.AlgorithmRS256, security.AlgorithmEdDSA}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(s.cfg.OIDCClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCInvalidIDToken, err)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrOIDCInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// linkUser resolves the local account: an existing identity first, then a user with the
+// same verified email, otherwise a new socio is created.
+func (s *OIDCService) linkUser(issuer string, claims *oidcIDTokenClaims) (*models.User, error) {
+	var user models.User
+	err := s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+		if err == nil {
+			return tx.First(&user, identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		email := strings.ToLower(strings.TrimSpace(claims.Email))
+		if email == "" || !claims.EmailVerified {
+			return ErrOIDCEmailNotVerified
+		}
+
+		err = tx.Where("email = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			name := claims.Name
+			if name == "" {
+				name = email
+			}
+			// The account gets an unusable random password: it can only log in through the provider.
+			secret, err := randomURLToken(32)
+			if err != nil {
+				return err
+			}
+			hash, err := security.HashPassword(secret)
+			if err != nil {
+				return err
+			}
+			user = models.User{Name: name, Email: email, PasswordHash: hash, Role: "socio"}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			evt := events.New(events.UserRegistered)
+			evt.UserID = user.ID
+			evt.Data = map[string]interface{}{"provider": issuer}
+			if err := s.events.Record(tx, evt); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: issuer,
+			Subject:  claims.Subject,
+			Email:    email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *OIDCService) loadDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	s.mu.Lock()
+	cached := s.discovery
+	s.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	var discovery oidcDiscovery
+	endpoint := strings.TrimSuffix(s.cfg.OIDCIssuer, "/") + "/.well-known/openid-configuration"
+	if err := s.getJSON(ctx, endpoint, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if discovery.Issuer != s.cfg.OIDCIssuer {
+		return nil, fmt.Errorf("oidc discovery issuer mismatch: %s", discovery.Issuer)
+	}
+
+	s.mu.Lock()
+	s.discovery = &discovery
+	s.mu.Unlock()
+	return &discovery, nil
+}
+
+// providerKey returns the verification key for kid, refreshing the JWKS once on a miss
+// so provider-side rotations are picked up.
+func (s *OIDCService) providerKey(ctx context.Context, discovery *oidcDiscovery, kid string) (interface{}, error) {
+	s.mu.Lock()
+	key, ok := s.jwks[kid]
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var document security.JWKS
+	if err := s.getJSON(ctx, discovery.JWKSURI, &document); err != nil {
+		return nil, fmt.Errorf("oidc jwks fetch failed: %w", err)
+	}
+	keys := make(map[string]interface{}, len(document.Keys))
+	for _, jwk := range document.Keys {
+		parsed, err := security.PublicKeyFromJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = parsed
+	}
+
+	s.mu.Lock()
+	s.jwks = keys
+	s.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", security.ErrUnknownKeyID, kid)
+	}
+	return key, nil
+}
+
+func (s *OIDCService) getJSON(ctx context.Context, endpoint string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
+
+func randomURLToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
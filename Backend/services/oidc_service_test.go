@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alesio/gestion-actividades-deportivas/config"
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security/oidcfake"
+	"gorm.io/gorm"
+)
+
+const oidcTestRedirectURL = "http://app.test/api/auth/oidc/callback"
+
+type oidcTestEnv struct {
+	db       *gorm.DB
+	provider *oidcfake.Provider
+	service  *OIDCService
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+	env := &oidcTestEnv{db: dbtest.Open(t, &models.User{}, &models.UserIdentity{})}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env.provider.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := oidcfake.New(server.URL, "gym-app", "gym-secret")
+	if err != nil {
+		t.Fatalf("oidcfake.New: %v", err)
+	}
+	env.provider = provider
+
+	env.service = NewOIDCService(env.db, &config.Config{
+		OIDCIssuer:       server.URL,
+		OIDCClientID:     "gym-app",
+		OIDCClientSecret: "gym-secret",
+		OIDCRedirectURL:  oidcTestRedirectURL,
+		OIDCScopes:       "openid email profile",
+	}, events.NewBus())
+	return env
+}
+
+// authorize follows the provider authorize URL, letting tamper edit its query first, and returns
+// the state and code sent back to the redirect URL.
+func (env *oidcTestEnv) authorize(t *testing.T, authURL string, tamper func(url.Values)) (string, string) {
+	t.Helper()
+	target, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorize url: %v", err)
+	}
+	if tamper != nil {
+		query := target.Query()
+		tamper(query)
+		target.RawQuery = query.Encode()
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(target.String())
+	if err != nil {
+		t.Fatalf("authorize request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse callback url: %v", err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != oidcTestRedirectURL {
+		t.Fatalf("callback url = %s, want %s", got, oidcTestRedirectURL)
+	}
+	return callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+// login runs BeginLogin, the provider round trip and CompleteLogin.
+func (env *oidcTestEnv) login(t *testing.T, tamper func(url.Values)) (*models.User, error) {
+	t.Helper()
+	authURL, err := env.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	state, code := env.authorize(t, authURL, tamper)
+	return env.service.CompleteLogin(context.Background(), state, code)
+}
+
+func TestOIDCLoginCreatesAndReusesAccount(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.provider.AddUser(oidcfake.User{Subject: "sub-1", Email: "Ana@Example.com", EmailVerified: true, Name: "Ana"})
+
+	user, err := env.login(t, nil)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.Email != "ana@example.com" || user.Name != "Ana" || user.Role != "socio" {
+		t.Fatalf("created user = %+v", user)
+	}
+
+	again, err := env.login(t, nil)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Fatalf("second login user id = %d, want %d", again.ID, user.ID)
+	}
+
+	var users, identities int64
+	env.db.Model(&models.User{}).Count(&users)
+	env.db.Model(&models.UserIdentity{}).Count(&identities)
+	if users != 1 || identities != 1 {
+		t.Fatalf("users = %d, identities = %d, want 1 and 1", users, identities)
+	}
+}
+
+func TestOIDCLoginLinksExistingAccountByEmail(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	existing := models.User{Name: "Bruno", Email: "bruno@example.com", PasswordHash: "x", Role: "admin"}
+	if err := env.db.Create(&existing).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	env.provider.AddUser(oidcfake.User{Subject: "sub-2", Email: "bruno@example.com", EmailVerified: true, Name: "Bruno G."})
+
+	user, err := env.login(t, nil)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.ID != existing.ID || user.Role != "admin" {
+		t.Fatalf("linked user = %+v, want the existing account %d", user, existing.ID)
+	}
+
+	var identity models.UserIdentity
+	if err := env.db.Where("subject = ?", "sub-2").First(&identity).Error; err != nil {
+		t.Fatalf("load identity: %v", err)
+	}
+	if identity.UserID != existing.ID || identity.Provider != env.provider.Issuer {
+		t.Fatalf("identity = %+v", identity)
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedEmail(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.provider.AddUser(oidcfake.User{Subject: "sub-3", Email: "carla@example.com", Name: "Carla"})
+
+	if _, err := env.login(t, nil); !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Fatalf("login error = %v, want %v", err, ErrOIDCEmailNotVerified)
+	}
+}
+
+func TestOIDCCompleteLoginRejectsBadState(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.provider.AddUser(oidcfake.User{Subject: "sub-4", Email: "dario@example.com", EmailVerified: true})
+
+	authURL, err := env.service.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	state, code := env.authorize(t, authURL, nil)
+
+	if _, err := env.service.CompleteLogin(context.Background(), "forged-state", code); !errors.Is(err, ErrOIDCInvalidState) {
+		t.Fatalf("forged state error = %v, want %v", err, ErrOIDCInvalidState)
+	}
+	if _, err := env.service.CompleteLogin(context.Background(), state, code); err != nil {
+		t.Fatalf("login with the issued state: %v", err)
+	}
+	if _, err := env.service.CompleteLogin(context.Background(), state, code); !errors.Is(err, ErrOIDCInvalidState) {
+		t.Fatalf("replayed state error = %v, want %v", err, ErrOIDCInvalidState)
+	}
+}
+
+func TestOIDCCompleteLoginRejectsBadNonce(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.provider.AddUser(oidcfake.User{Subject: "sub-5", Email: "elena@example.com", EmailVerified: true})
+
+	_, err := env.login(t, func(query url.Values) { query.Set("nonce", "forged-nonce") })
+	if !errors.Is(err, ErrOIDCInvalidIDToken) {
+		t.Fatalf("login error = %v, want %v", err, ErrOIDCInvalidIDToken)
+	}
+
+	var identities int64
+	env.db.Model(&models.UserIdentity{}).Count(&identities)
+	if identities != 0 {
+		t.Fatalf("identities = %d, want 0", identities)
+	}
+}
+
+func TestOIDCCompleteLoginRejectsTamperedVerifier(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.provider.AddUser(oidcfake.User{Subject: "sub-6", Email: "fede@example.com", EmailVerified: true})
+
+	// A challenge that does not match the stored verifier makes the token exchange fail.
+	_, err := env.login(t, func(query url.Values) { query.Set("code_challenge", "forged-challenge") })
+	if !errors.Is(err, ErrOIDCExchangeFailed) {
+		t.Fatalf("login error = %v, want %v", err, ErrOIDCExchangeFailed)
+	}
+}
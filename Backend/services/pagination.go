@@ -0,0 +1,133 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// SortField is one key of a listing order; Desc reverses it.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// PageRequest selects a page of a listing. With Cursor set the page continues right after the row
+// the cursor was issued for (keyset pagination); otherwise Offset rows are skipped.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   []SortField
+}
+
+// PageInfo describes the page returned and how to request the next one.
+type PageInfo struct {
+	// Total counts every row matching the filters, across all pages.
+	Total int64 `json:"total"`
+	Limit int   `json:"limit"`
+	// Offset is only set for offset pagination.
+	Offset  *int     `json:"offset,omitempty"`
+	Sort    []string `json:"sort"`
+	HasMore bool     `json:"has_more"`
+	// NextCursor continues the listing after this page; it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Facets count the matches per category and day, on activity listings.
+	Facets *ActivityFacets `json:"facets,omitempty"`
+}
+
+// ParseSort reads a comma separated list of fields, each optionally prefixed with "-" for
+// descending order, e.g. "day,start_time,-availability". allowed lists the valid field names.
+func ParseSort(value string, allowed []string) ([]SortField, error) {
+	var fields []SortField
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !containsString(allowed, field.Field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, field.Field)
+		}
+		if seen[field.Field] {
+			return nil, fmt.Errorf("%w: %q is repeated", ErrInvalidSort, field.Field)
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// formatSort is the inverse of ParseSort.
+func formatSort(fields []SortField) []string {
+	formatted := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field.Desc {
+			formatted = append(formatted, "-"+field.Field)
+		} else {
+			formatted = append(formatted, field.Field)
+		}
+	}
+	return formatted
+}
+
+// pageCursor is the position after the last row of a page: its sort values and id. It also
+// records the order it belongs to, so it cannot be replayed against a different sort.
+type pageCursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+	ID     uint              `json:"id"`
+}
+
+func encodeCursor(sort []SortField, values []interface{}, id uint) (string, error) {
+	cursor := pageCursor{Sort: strings.Join(formatSort(sort), ","), ID: id}
+	for _, value := range values {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		cursor.Values = append(cursor.Values, raw)
+	}
+	body, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+func decodeCursor(value string, sort []SortField) (*pageCursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(body, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != strings.Join(formatSort(sort), ",") || len(cursor.Values) != len(sort) || cursor.ID == 0 {
+		return nil, fmt.Errorf("%w: it was issued for another sort", ErrInvalidCursor)
+	}
+	return &cursor, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
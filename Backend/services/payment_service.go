@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPaymentNotFound     = errors.New("payment not found")
+	ErrPaymentPending      = errors.New("a payment is already pending for this enrollment")
+	ErrPaymentsUnavailable = errors.New("no payment provider configured")
+	ErrUnknownProvider     = errors.New("unknown payment provider")
+	ErrNothingToPay        = errors.New("nothing to pay")
+)
+
+// PaymentService creates checkouts at the configured gateway and applies their outcome.
+// Paid enrollments hold their seat as "pendiente_pago" until the webhook confirms the payment
+// or the hold expires; the waitlist lets expired holds go.
+type PaymentService struct {
+	db          *gorm.DB
+	gateway     payments.PaymentGateway
+	invoices    *InvoiceService
+	memberships *MembershipService
+	waitlist    *Waitlist
+	events      *events.Bus
+	currency    string
+	holdTTL     time.Duration
+}
+
+// NewPaymentService builds the service. gateway may be nil, which disables paid checkouts.
+func NewPaymentService(db *gorm.DB, gateway payments.PaymentGateway, invoices *InvoiceService, memberships *MembershipService, waitlist *Waitlist, bus *events.Bus, currency string, holdTTL time.Duration) *PaymentService {
+	return &PaymentService{db: db, gateway: gateway, invoices: invoices, memberships: memberships, waitlist: waitlist, events: bus, currency: currency, holdTTL: holdTTL}
+}
+
+// HoldTTL is how long a pending payment keeps its seat or checkout open.
+func (s *PaymentService) HoldTTL() time.Duration {
+	return s.holdTTL
+}
+
+// StartEnrollmentCheckout opens a checkout for a "pendiente_pago" enrollment.
+func (s *PaymentService) StartEnrollmentCheckout(enrollment *models.Enrollment, activity *models.Activity) (*models.Payment, error) {
+	payment := models.Payment{
+		UserID:       enrollment.UserID,
+		Purpose:      "inscripcion",
+		EnrollmentID: &enrollment.ID,
+		Description:  fmt.Sprintf("Clase %s", activity.Title),
+		AmountCents:  activity.PriceCents,
+	}
+	if enrollment.HoldExpiresAt != nil {
+		payment.ExpiresAt = *enrollment.HoldExpiresAt
+	}
+	if err := s.startCheckout(&payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// StartMembershipCheckout opens a checkout for a plan; the membership starts once it is paid.
+func (s *PaymentService) StartMembershipCheckout(userID, planID uint) (*models.Payment, error) {
+	var plan models.MembershipPlan
+	if err := s.db.First(&plan, planID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlanNotFound
+		}
+		return nil, err
+	}
+	if !plan.IsActive {
+		return nil, ErrPlanInactive
+	}
+	if plan.PriceCents <= 0 {
+		return nil, ErrNothingToPay
+	}
+
+	payment := models.Payment{
+		UserID:      userID,
+		Purpose:     "membresia",
+		PlanID:      &plan.ID,
+		Description: fmt.Sprintf("Membresía %s", plan.Name),
+		AmountCents: plan.PriceCents,
+	}
+	if err := s.startCheckout(&payment); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (s *PaymentService) ListUserPayments(userID uint) ([]models.Payment, error) {
+	if err := s.waitlist.expireStaleHolds(); err != nil {
+		return nil, err
+	}
+	var result []models.Payment
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// HandleWebhook verifies and applies a provider notification. Repeated deliveries are no-ops.
+func (s *PaymentService) HandleWebhook(provider string, header http.Header, body []byte) error {
+	if s.gateway == nil || provider != s.gateway.Name() {
+		return ErrUnknownProvider
+	}
+	event, err := s.gateway.ParseWebhook(header, body)
+	if err != nil {
+		return err
+	}
+
+	return s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_ref = ?", provider, event.ProviderRef).
+			First(&payment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPaymentNotFound
+			}
+			return err
+		}
+		switch payment.Status {
+		case "aprobado", "rechazado", "a_reembolsar":
+			return nil
+		}
+
+		if event.Status == payments.EventRejected {
+			return s.rejectPayment(tx, &payment)
+		}
+		return s.approvePayment(tx, &payment)
+	})
+}
+
+func (s *PaymentService) startCheckout(payment *models.Payment) error {
+	if s.gateway == nil {
+		return ErrPaymentsUnavailable
+	}
+
+	var user models.User
+	if err := s.db.First(&user, payment.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	reference, err := randomURLToken(12)
+	if err != nil {
+		return err
+	}
+	checkout, err := s.gateway.CreateCheckout(context.Background(), payments.CheckoutRequest{
+		Reference:   reference,
+		Description: payment.Description,
+		AmountCents: payment.AmountCents,
+		Currency:    s.currency,
+		PayerEmail:  user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	payment.Currency = s.currency
+	payment.Provider = s.gateway.Name()
+	payment.ProviderRef = checkout.ProviderRef
+	payment.CheckoutURL = checkout.CheckoutURL
+	payment.Status = "pendiente"
+	if payment.ExpiresAt.IsZero() {
+		payment.ExpiresAt = time.Now().Add(s.holdTTL)
+	}
+	return s.db.Create(payment).Error
+}
+
+func (s *PaymentService) approvePayment(tx *gorm.DB, payment *models.Payment) error {
+	now := time.Now()
+	status := "aprobado"
+
+	switch payment.Purpose {
+	case "inscripcion":
+		enrollment, confirmed, err := s.confirmEnrollment(tx, *payment.EnrollmentID)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			status = "a_reembolsar"
+			break
+		}
+		if err := s.events.Record(tx, enrollmentEvent(events.EnrollmentConfirmed, enrollment)); err != nil {
+			return err
+		}
+	case "membresia":
+		membership, err := assignPlan(tx, payment.UserID, *payment.PlanID, now, nil)
+		switch {
+		case err == nil:
+			payment.MembershipID = &membership.ID
+		case errors.Is(err, ErrPlanInactive), errors.Is(err, ErrPlanNotFound):
+			status = "a_reembolsar"
+		default:
+			return err
+		}
+	}
+
+	if err := tx.Model(payment).Updates(map[string]interface{}{
+		"status":        status,
+		"paid_at":       now,
+		"membership_id": payment.MembershipID,
+	}).Error; err != nil {
+		return err
+	}
+	if status != "aprobado" {
+		return nil
+	}
+	// The invoice is issued in the same transaction, so an approved payment always has one.
+	_, err := s.invoices.IssueForPayment(tx, payment)
+	return err
+}
+
+func (s *PaymentService) rejectPayment(tx *gorm.DB, payment *models.Payment) error {
+	if payment.EnrollmentID != nil {
+		var enrollment models.Enrollment
+		err := tx.Where("id = ? AND status = ?", *payment.EnrollmentID, "pendiente_pago").First(&enrollment).Error
+		switch {
+		case err == nil:
+			if err := tx.Model(&enrollment).
+				Updates(map[string]interface{}{"status": "cancelado", "hold_expires_at": nil}).Error; err != nil {
+				return err
+			}
+			if err := s.events.Record(tx, enrollmentEvent(events.EnrollmentReleased, &enrollment)); err != nil {
+				return err
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+	}
+	return tx.Model(payment).Update("status", "rechazado").Error
+}
+
+// confirmEnrollment turns a held enrollment into "inscripto". A hold that already lapsed is
+// only revived if the seat is still free, and the member must still be within their plan quota;
+// otherwise the payment must be refunded.
+func (s *PaymentService) confirmEnrollment(tx *gorm.DB, enrollmentID uint) (*models.Enrollment, bool, error) {
+	var enrollment models.Enrollment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&enrollment, enrollmentID).Error; err != nil {
+		return nil, false, err
+	}
+	if enrollment.Status == "inscripto" {
+		return &enrollment, true, nil
+	}
+	if enrollment.Status != "pendiente_pago" && enrollment.Status != "expirado" {
+		return nil, false, nil
+	}
+
+	var activity models.Activity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, enrollment.ActivityID).Error; err != nil {
+		return nil, false, err
+	}
+	// Same lock order as enrolling: the activity, then the member.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, enrollment.UserID).Error; err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	held := enrollment.Status == "pendiente_pago" && enrollment.HoldExpiresAt != nil && enrollment.HoldExpiresAt.After(now)
+	if !held {
+		var taken int64
+		if err := seatHoldersAt(tx.Model(&models.Enrollment{}), now).
+			Where("activity_id = ?", enrollment.ActivityID).
+			Count(&taken).Error; err != nil {
+			return nil, false, err
+		}
+		var duplicates int64
+		if err := seatHoldersAt(tx.Model(&models.Enrollment{}), now).
+			Where("activity_id = ? AND user_id = ? AND id <> ?", enrollment.ActivityID, enrollment.UserID, enrollment.ID).
+			Count(&duplicates).Error; err != nil {
+			return nil, false, err
+		}
+		if !activity.IsActive || int(taken) >= activity.Capacity || duplicates > 0 {
+			return nil, false, nil
+		}
+	}
+
+	// The plan may have changed, or a lapsed hold let other seats take its place in the quota.
+	if err := s.memberships.checkQuota(tx, enrollment.UserID, &activity, now, enrollment.ID); err != nil {
+		if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrMembershipRequired) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	if err := tx.Model(&enrollment).Updates(map[string]interface{}{
+		"status":          "inscripto",
+		"hold_expires_at": nil,
+	}).Error; err != nil {
+		return nil, false, err
+	}
+	return &enrollment, true, nil
+}
+
+// seatHolders restricts an enrollment query to rows that take a seat: confirmed enrollments
+// and payment holds that have not expired yet.
+func seatHolders(query *gorm.DB) *gorm.DB {
+	return seatHoldersAt(query, time.Now())
+}
+
+// seatHoldersAt is seatHolders with the payment holds evaluated at now.
+func seatHoldersAt(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("(enrollments.status = ? OR (enrollments.status = ? AND enrollments.hold_expires_at > ?))",
+		"inscripto", "pendiente_pago", now)
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/security"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrRoleExists       = errors.New("role already exists")
+	ErrRoleInUse        = errors.New("role is assigned to users")
+	ErrSystemRoleLocked = errors.New("system role cannot be modified")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrInvalidPerm      = errors.New("invalid permission")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrLastAdmin        = errors.New("cannot remove the last admin")
+
+	roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,19}$`)
+
+	// policyTTL bounds how long another instance's role edits take to be seen.
+	policyTTL = 30 * time.Second
+)
+
+// RoleView is a role with its permission names flattened.
+type RoleView struct {
+	models.Role
+	Permissions []string `json:"permissions"`
+}
+
+// RBACService resolves role permissions and manages roles.
+type RBACService struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	policy   security.Policy
+	loadedAt time.Time
+}
+
+func NewRBACService(db *gorm.DB) *RBACService {
+	return &RBACService{db: db}
+}
+
+// Can reports whether role holds permission, using a cached policy snapshot.
+func (s *RBACService) Can(role, permission string) (bool, error) {
+	policy, err := s.currentPolicy()
+	if err != nil {
+		return false, err
+	}
+	return policy.Allows(role, permission), nil
+}
+
+func (s *RBACService) ListRoles() ([]RoleView, error) {
+	var roles []models.Role
+	if err := s.db.Preload("Permissions").Order("name ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	views := make([]RoleView, 0, len(roles))
+	for _, role := range roles {
+		views = append(views, toRoleView(role))
+	}
+	return views, nil
+}
+
+func (s *RBACService) CreateRole(name, description string, permissions []string) (*RoleView, error) {
+	if !roleNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRole, name)
+	}
+	perms, err := normalizePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrRoleExists
+	}
+
+	role := models.Role{Name: name, Description: description}
+	for _, perm := range perms {
+		role.Permissions = append(role.Permissions, models.RolePermission{Permission: perm})
+	}
+	if err := s.db.Create(&role).Error; err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	view := toRoleView(role)
+	return &view, nil
+}
+
+// UpdateRole replaces the description and permission set. The admin role is locked.
+func (s *RBACService) UpdateRole(name, description string, permissions []string) (*RoleView, error) {
+	if name == security.RoleAdmin {
+		return nil, ErrSystemRoleLocked
+	}
+	perms, err := normalizePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	var role models.Role
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+		if err := tx.Model(&role).Update("description", description).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		role.Permissions = nil
+		for _, perm := range perms {
+			role.Permissions = append(role.Permissions, models.RolePermission{RoleID: role.ID, Permission: perm})
+		}
+		if len(role.Permissions) > 0 {
+			return tx.Create(&role.Permissions).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate()
+
+	view := toRoleView(role)
+	return &view, nil
+}
+
+// DeleteRole removes a custom role that no user holds.
+func (s *RBACService) DeleteRole(name string) error {
+	var role models.Role
+	if err := s.db.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+	if role.IsSystem {
+		return ErrSystemRoleLocked
+	}
+
+	var users int64
+	if err := s.db.Model(&models.User{}).Where("role = ?", name).Count(&users).Error; err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := s.db.Select("Permissions").Delete(&role).Error; err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// AssignUserRole changes a user's role, refusing to demote the last admin.
+func (s *RBACService) AssignUserRole(userID uint, roleName string, actor Actor) (*models.User, error) {
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var roles int64
+		if err := tx.Model(&models.Role{}).Where("name = ?", roleName).Count(&roles).Error; err != nil {
+			return err
+		}
+		if roles == 0 {
+			return ErrRoleNotFound
+		}
+
+		if err := tx.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if user.Role == security.RoleAdmin && roleName != security.RoleAdmin {
+			var admins int64
+			if err := tx.Model(&models.User{}).Where("role = ?", security.RoleAdmin).Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+
+		previous := user.Role
+		user.Role = roleName
+		if err := tx.Model(&user).Update("role", roleName).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditChange{
+			Action:     "user.role_changed",
+			EntityType: AuditUser,
+			EntityID:   userID,
+			Extra:      map[string]FieldChange{"role": {Before: previous, After: roleName}},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *RBACService) currentPolicy() (security.Policy, error) {
+	s.mu.RLock()
+	policy, loadedAt := s.policy, s.loadedAt
+	s.mu.RUnlock()
+	if policy != nil && time.Since(loadedAt) < policyTTL {
+		return policy, nil
+	}
+
+	var roles []models.Role
+	if err := s.db.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	table := make(map[string][]string, len(roles))
+	for _, role := range roles {
+		table[role.Name] = toRoleView(role).Permissions
+	}
+	policy = security.NewPolicy(table)
+
+	s.mu.Lock()
+	s.policy, s.loadedAt = policy, time.Now()
+	s.mu.Unlock()
+	return policy, nil
+}
+
+func (s *RBACService) invalidate() {
+	s.mu.Lock()
+	s.policy = nil
+	s.mu.Unlock()
+}
+
+func normalizePermissions(permissions []string) ([]string, error) {
+	seen := make(map[string]bool, len(permissions))
+	normalized := make([]string, 0, len(permissions))
+	for _, perm := range permissions {
+		if !security.IsPermission(perm) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPerm, perm)
+		}
+		if !seen[perm] {
+			seen[perm] = true
+			normalized = append(normalized, perm)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func toRoleView(role models.Role) RoleView {
+	perms := make([]string, 0, len(role.Permissions))
+	for _, perm := range role.Permissions {
+		perms = append(perms, perm.Permission)
+	}
+	sort.Strings(perms)
+	if role.Name == security.RoleAdmin {
+		perms = security.AllPermissions()
+	}
+	return RoleView{Role: role, Permissions: perms}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+// TimeBand is a part of the day the weekly timetable is split into. An activity belongs to the
+// band its start time falls in.
+type TimeBand struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	// From and To bound the start times of the band as "HH:MM"; To is exclusive.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TimeBands lists the bands of the timetable in order. Together they cover the whole day.
+var TimeBands = []TimeBand{
+	{Key: "manana", Label: "Mañana", From: "00:00", To: "12:00"},
+	{Key: "tarde", Label: "Tarde", From: "12:00", To: "18:00"},
+	{Key: "noche", Label: "Noche", From: "18:00", To: "24:00"},
+}
+
+var dayNames = []string{"Domingo", "Lunes", "Martes", "Miércoles", "Jueves", "Viernes", "Sábado"}
+
+// ScheduleEntry is an activity in the timetable. EnrollmentStatus, ConflictsWith and the
+// activity's Eligibility are only filled in for an authenticated member.
+type ScheduleEntry struct {
+	models.Activity
+	// EnrollmentStatus is the member's seat in the activity: "inscripto", "pendiente_pago" or
+	// "lista_espera"; empty when not enrolled.
+	EnrollmentStatus string `json:"enrollment_status,omitempty"`
+	IsEnrolled       bool   `json:"is_enrolled"`
+	// HasConflict reports that enrolling would fail with a schedule conflict; ConflictsWith lists
+	// the member's enrolled activities that overlap with this one.
+	HasConflict   bool   `json:"has_conflict"`
+	ConflictsWith []uint `json:"conflicts_with,omitempty"`
+}
+
+// ScheduleBand groups the activities of a day starting within a time band.
+type ScheduleBand struct {
+	TimeBand
+	Activities []ScheduleEntry `json:"activities"`
+}
+
+// ScheduleDay is one column of the weekly timetable.
+type ScheduleDay struct {
+	DayOfWeek int            `json:"day_of_week"`
+	Name      string         `json:"name"`
+	Bands     []ScheduleBand `json:"bands"`
+}
+
+// GetSchedule returns the active activities matching filter arranged as a weekly timetable: every
+// day of the week (0 = Sunday) with every time band, sorted by start time. With userID set, each
+// entry says whether the member is enrolled, would hit a schedule conflict or is eligible.
+func (s *ActivityService) GetSchedule(filter ActivityFilter, userID *uint) ([]ScheduleDay, error) {
+	active := true
+	query, err := s.searchActivities(s.listedActivities(filter), filter, &active)
+	if err != nil {
+		return nil, err
+	}
+	var activities []models.Activity
+	if err := query.scoped(true, true).Find(&activities).Error; err != nil {
+		return nil, err
+	}
+	if err := s.populateAvailabilityAt(query.now, slicePointers(activities)...); err != nil {
+		return nil, err
+	}
+	if err := populateEligibilityRules(s.db, slicePointers(activities)...); err != nil {
+		return nil, err
+	}
+
+	var enrollments []models.Enrollment
+	if userID != nil {
+		if err := s.db.Preload("Activity").
+			Where("user_id = ? AND (status IN ? OR (status = ? AND hold_expires_at > ?))",
+				*userID, []string{"inscripto", "lista_espera"}, "pendiente_pago", time.Now()).
+			Find(&enrollments).Error; err != nil {
+			return nil, err
+		}
+		if err := annotateEligibility(s.db, *userID, query.now, slicePointers(activities)...); err != nil {
+			return nil, err
+		}
+	}
+
+	days := make([]ScheduleDay, len(dayNames))
+	for day := range days {
+		days[day] = ScheduleDay{DayOfWeek: day, Name: dayNames[day], Bands: make([]ScheduleBand, len(TimeBands))}
+		for i, band := range TimeBands {
+			days[day].Bands[i] = ScheduleBand{TimeBand: band, Activities: []ScheduleEntry{}}
+		}
+	}
+
+	for _, activity := range activities {
+		if activity.DayOfWeek < 0 || activity.DayOfWeek >= len(days) {
+			continue
+		}
+		entry := ScheduleEntry{Activity: activity}
+		if userID != nil {
+			if err := annotateScheduleEntry(&entry, enrollments); err != nil {
+				return nil, err
+			}
+		}
+		band := timeBandIndex(activity.StartTime)
+		days[activity.DayOfWeek].Bands[band].Activities = append(days[activity.DayOfWeek].Bands[band].Activities, entry)
+	}
+
+	for day := range days {
+		for band := range days[day].Bands {
+			sortScheduleEntries(days[day].Bands[band].Activities)
+		}
+	}
+	return days, nil
+}
+
+// annotateScheduleEntry fills in the member's seat in the activity or, when there is none, the
+// enrolled activities it overlaps with. Only confirmed enrollments block a new one, as in
+// EnrollUserInActivity.
+func annotateScheduleEntry(entry *ScheduleEntry, enrollments []models.Enrollment) error {
+	for _, enrollment := range enrollments {
+		if enrollment.ActivityID == entry.ID {
+			entry.EnrollmentStatus = enrollment.Status
+			entry.IsEnrolled = enrollment.Status == "inscripto"
+			return nil
+		}
+	}
+	for _, enrollment := range enrollments {
+		if enrollment.Status != "inscripto" {
+			continue
+		}
+		overlaps, err := activitiesOverlap(&entry.Activity, &enrollment.Activity)
+		if err != nil {
+			return err
+		}
+		if overlaps {
+			entry.HasConflict = true
+			entry.ConflictsWith = append(entry.ConflictsWith, enrollment.ActivityID)
+		}
+	}
+	return nil
+}
+
+// timeBandIndex returns the band a start time "HH:MM" falls in; unparsable times go to the first.
+func timeBandIndex(start string) int {
+	parsed, err := time.Parse("15:04", start)
+	if err != nil {
+		return 0
+	}
+	clock := parsed.Format("15:04")
+	for i := len(TimeBands) - 1; i > 0; i-- {
+		if clock >= TimeBands[i].From {
+			return i
+		}
+	}
+	return 0
+}
+
+func sortScheduleEntries(entries []ScheduleEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].StartTime != entries[j].StartTime {
+			return entries[i].StartTime < entries[j].StartTime
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
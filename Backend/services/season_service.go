@@ -0,0 +1,528 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSeasonNotFound    = errors.New("season not found")
+	ErrSeasonExists      = errors.New("a season with that name already exists")
+	ErrSeasonOverlap     = errors.New("season overlaps another season")
+	ErrSeasonInUse       = errors.New("season is in use")
+	ErrInvalidSeason     = errors.New("invalid season")
+	ErrSeasonEnded       = errors.New("season has ended")
+	ErrEnrollmentNotOpen = errors.New("enrollment for the season is not open")
+	ErrNothingToReenroll = errors.New("no activity continues the member's activities in the season")
+)
+
+// SeasonInput carries the editable fields of a season. Dates are YYYY-MM-DD; the enrollment
+// dates are optional.
+type SeasonInput struct {
+	Name              string
+	StartsOn          string
+	EndsOn            string
+	EnrollmentOpensOn string
+	PriorityOpensOn   string
+}
+
+// SeasonView is a season with the number of listed activities held in it. Current is set for the
+// season in progress.
+type SeasonView struct {
+	models.Season
+	Activities int  `json:"activities"`
+	Current    bool `json:"current"`
+}
+
+// ReenrollResult is the outcome of re-enrolling in one activity of a season: the enrollment, or
+// the error that prevented it.
+type ReenrollResult struct {
+	ActivityID       uint               `json:"activity_id"`
+	SourceActivityID uint               `json:"source_activity_id"`
+	Title            string             `json:"title"`
+	Enrollment       *models.Enrollment `json:"enrollment,omitempty"`
+	Err              error              `json:"-"`
+}
+
+// SeasonService manages the seasons of the timetable and the enrollments that follow them.
+// Season dates are days in location, the gym's time zone.
+type SeasonService struct {
+	db          *gorm.DB
+	events      *events.Bus
+	enrollments EnrollmentService
+	location    *time.Location
+}
+
+func NewSeasonService(db *gorm.DB, bus *events.Bus, enrollments EnrollmentService, location *time.Location) *SeasonService {
+	return &SeasonService{db: db, events: bus, enrollments: enrollments, location: location}
+}
+
+// ListSeasons returns every season in date order, with its listed activity count.
+func (s *SeasonService) ListSeasons() ([]SeasonView, error) {
+	var views []SeasonView
+	err := s.db.Model(&models.Season{}).
+		Select("seasons.*, COUNT(activities.id) AS activities").
+		Joins("LEFT JOIN activities ON activities.season_id = seasons.id AND activities.is_active = ?", true).
+		Group("seasons.id").
+		Order("seasons.starts_on ASC").
+		Scan(&views).Error
+	if err != nil {
+		return nil, err
+	}
+	today := gymDate(time.Now(), s.location)
+	for i := range views {
+		views[i].Current = views[i].StartsOn <= today && today <= views[i].EndsOn
+	}
+	return views, nil
+}
+
+func (s *SeasonService) CreateSeason(input SeasonInput, actor Actor) (*models.Season, error) {
+	season, err := parseSeasonInput(input)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkSeasonConflicts(tx, season); err != nil {
+			return err
+		}
+		if err := tx.Create(season).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditChange{
+			Action:     "season.created",
+			EntityType: AuditSeason,
+			EntityID:   season.ID,
+			After:      season,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return season, nil
+}
+
+// UpdateSeason changes the name or dates of a season that was not closed yet.
+func (s *SeasonService) UpdateSeason(id uint, input SeasonInput, actor Actor) (*models.Season, error) {
+	updated, err := parseSeasonInput(input)
+	if err != nil {
+		return nil, err
+	}
+	var season models.Season
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&season, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSeasonNotFound
+			}
+			return err
+		}
+		if season.ClosedAt != nil {
+			return fmt.Errorf("%w: closed seasons cannot change", ErrSeasonEnded)
+		}
+		previous := season
+		updated.ID = id
+		if err := checkSeasonConflicts(tx, updated); err != nil {
+			return err
+		}
+
+		season.Name = updated.Name
+		season.StartsOn, season.EndsOn = updated.StartsOn, updated.EndsOn
+		season.EnrollmentOpensOn, season.PriorityOpensOn = updated.EnrollmentOpensOn, updated.PriorityOpensOn
+		if err := tx.Save(&season).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditChange{
+			Action:     "season.updated",
+			EntityType: AuditSeason,
+			EntityID:   id,
+			Before:     &previous,
+			After:      &season,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &season, nil
+}
+
+// DeleteSeason removes a season no activity is held in.
+func (s *SeasonService) DeleteSeason(id uint, actor Actor) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var season models.Season
+		if err := tx.First(&season, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSeasonNotFound
+			}
+			return err
+		}
+		var activities int64
+		if err := tx.Model(&models.Activity{}).Where("season_id = ?", id).Count(&activities).Error; err != nil {
+			return err
+		}
+		if activities > 0 {
+			return fmt.Errorf("%w: %d activities are held in it", ErrSeasonInUse, activities)
+		}
+		if err := recordAudit(tx, actor, auditChange{
+			Action:     "season.deleted",
+			EntityType: AuditSeason,
+			EntityID:   id,
+			Before:     &season,
+		}); err != nil {
+			return err
+		}
+		return tx.Delete(&season).Error
+	})
+}
+
+// parseSeasonInput validates input and returns the season it describes, with its dates
+// normalized.
+func parseSeasonInput(input SeasonInput) (*models.Season, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(name) > 100 {
+		return nil, fmt.Errorf("%w: name is required and up to 100 characters", ErrInvalidSeason)
+	}
+	season := &models.Season{Name: name}
+	dates := []struct {
+		field    string
+		value    string
+		required bool
+		into     *string
+	}{
+		{"starts_on", input.StartsOn, true, &season.StartsOn},
+		{"ends_on", input.EndsOn, true, &season.EndsOn},
+		{"enrollment_opens_on", input.EnrollmentOpensOn, false, &season.EnrollmentOpensOn},
+		{"priority_opens_on", input.PriorityOpensOn, false, &season.PriorityOpensOn},
+	}
+	for _, date := range dates {
+		value := strings.TrimSpace(date.value)
+		if value == "" {
+			if date.required {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidSeason, date.field)
+			}
+			continue
+		}
+		day, err := time.Parse(sessionDateLayout, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a YYYY-MM-DD date", ErrInvalidSeason, date.field)
+		}
+		*date.into = day.Format(sessionDateLayout)
+	}
+
+	switch {
+	case season.EndsOn < season.StartsOn:
+		return nil, fmt.Errorf("%w: ends_on cannot be before starts_on", ErrInvalidSeason)
+	case season.EnrollmentOpensOn > season.EndsOn:
+		return nil, fmt.Errorf("%w: enrollment_opens_on cannot be after ends_on", ErrInvalidSeason)
+	case season.PriorityOpensOn != "" && season.EnrollmentOpensOn == "":
+		return nil, fmt.Errorf("%w: priority_opens_on needs enrollment_opens_on", ErrInvalidSeason)
+	case season.PriorityOpensOn != "" && season.PriorityOpensOn >= season.EnrollmentOpensOn:
+		return nil, fmt.Errorf("%w: priority_opens_on must be before enrollment_opens_on", ErrInvalidSeason)
+	}
+	return season, nil
+}
+
+// checkSeasonConflicts fails when another season has the name of season or shares any of its
+// days.
+func checkSeasonConflicts(tx *gorm.DB, season *models.Season) error {
+	var named int64
+	if err := tx.Model(&models.Season{}).Where("name = ? AND id <> ?", season.Name, season.ID).Count(&named).Error; err != nil {
+		return err
+	}
+	if named > 0 {
+		return ErrSeasonExists
+	}
+	var overlapping models.Season
+	err := tx.Where("starts_on <= ? AND ends_on >= ? AND id <> ?", season.EndsOn, season.StartsOn, season.ID).First(&overlapping).Error
+	if err == nil {
+		return fmt.Errorf("%w: %s runs from %s to %s", ErrSeasonOverlap, overlapping.Name, overlapping.StartsOn, overlapping.EndsOn)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// assignSeason checks that the season of activity exists. An activity can only be moved into a
+// season that has not ended by today; previous is the season it was held in before.
+func assignSeason(db *gorm.DB, activity *models.Activity, previous *uint, today string) error {
+	if activity.SeasonID == nil {
+		return nil
+	}
+	var season models.Season
+	if err := db.First(&season, *activity.SeasonID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSeasonNotFound
+		}
+		return err
+	}
+	if seasonEnded(&season, today) && !sameSeason(previous, activity.SeasonID) {
+		return fmt.Errorf("%w: %s ended on %s", ErrSeasonEnded, season.Name, season.EndsOn)
+	}
+	return nil
+}
+
+// gymDate is the day of now in the gym's time zone, in sessionDateLayout. Season and session
+// dates are compared against it, never against the server's own zone.
+func gymDate(now time.Time, location *time.Location) string {
+	return now.In(location).Format(sessionDateLayout)
+}
+
+// seasonEnded reports whether season was closed or ended before today, a gymDate.
+func seasonEnded(season *models.Season, today string) bool {
+	return season.ClosedAt != nil || season.EndsOn < today
+}
+
+func sameSeason(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// inSeasonInEffect restricts an activity query to the activities held all year round and those
+// of the season in progress today, a gymDate, so the timetable switches on its own when a season
+// starts.
+func inSeasonInEffect(query *gorm.DB, today string) *gorm.DB {
+	return query.Where("activities.season_id IS NULL OR activities.season_id IN (?)",
+		query.Session(&gorm.Session{NewDB: true}).Model(&models.Season{}).
+			Select("id").
+			Where("starts_on <= ? AND ends_on >= ?", today, today))
+}
+
+// checkEnrollmentWindow enforces the enrollment dates of the season of activity: nobody enrolls
+// once it ended, and before EnrollmentOpensOn only the members with priority do, from
+// PriorityOpensOn on.
+func checkEnrollmentWindow(db *gorm.DB, userID uint, activity *models.Activity, today string) error {
+	if activity.SeasonID == nil {
+		return nil
+	}
+	var season models.Season
+	if err := db.First(&season, *activity.SeasonID).Error; err != nil {
+		return err
+	}
+	if seasonEnded(&season, today) {
+		return ErrSeasonEnded
+	}
+	if season.EnrollmentOpensOn == "" || today >= season.EnrollmentOpensOn {
+		return nil
+	}
+	if season.PriorityOpensOn != "" && today >= season.PriorityOpensOn {
+		priority, err := hasSeasonPriority(db, userID, activity)
+		if err != nil {
+			return err
+		}
+		if priority {
+			return nil
+		}
+	}
+	return ErrEnrollmentNotOpen
+}
+
+// hasSeasonPriority reports whether userID is, or was until its season ended, a member of the
+// activity that activity continues.
+func hasSeasonPriority(db *gorm.DB, userID uint, activity *models.Activity) (bool, error) {
+	if activity.SourceActivityID == nil {
+		return false, nil
+	}
+	var members int64
+	if err := db.Model(&models.Enrollment{}).
+		Where("user_id = ? AND activity_id = ? AND status IN ?", userID, *activity.SourceActivityID, []string{"inscripto", "finalizado"}).
+		Count(&members).Error; err != nil {
+		return false, err
+	}
+	return members > 0, nil
+}
+
+// Reenroll enrolls userID in every published activity of the season that continues one of their
+// activities, that is whose source activity they are or were enrolled in. Each enrollment follows
+// the usual rules, so some may fail while others succeed; the result reports them one by one. It
+// fails with ErrNothingToReenroll when no activity of the season continues theirs.
+func (s *SeasonService) Reenroll(userID, seasonID uint, actor Actor) ([]ReenrollResult, error) {
+	var season models.Season
+	if err := s.db.First(&season, seasonID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSeasonNotFound
+		}
+		return nil, err
+	}
+	if seasonEnded(&season, gymDate(time.Now(), s.location)) {
+		return nil, ErrSeasonEnded
+	}
+
+	var targets []models.Activity
+	if err := s.db.Where("season_id = ? AND status = ? AND source_activity_id IN (?)", seasonID, models.ActivityPublished,
+		s.db.Model(&models.Enrollment{}).
+			Select("activity_id").
+			Where("user_id = ? AND status IN ?", userID, []string{"inscripto", "finalizado"})).
+		Order("id ASC").
+		Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, ErrNothingToReenroll
+	}
+
+	results := make([]ReenrollResult, 0, len(targets))
+	for _, activity := range targets {
+		result := ReenrollResult{ActivityID: activity.ID, SourceActivityID: *activity.SourceActivityID, Title: activity.Title}
+		result.Enrollment, result.Err = s.enrollments.EnrollUserInActivity(userID, activity.ID, actor)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// CloseEndedSeasons closes every season that ended before the day of now at the gym and returns
+// how many it closed.
+func (s *SeasonService) CloseEndedSeasons(now time.Time) (int, error) {
+	var ended []uint
+	if err := s.db.Model(&models.Season{}).
+		Where("ends_on < ? AND closed_at IS NULL", gymDate(now, s.location)).
+		Order("ends_on ASC").
+		Pluck("id", &ended).Error; err != nil {
+		return 0, err
+	}
+	for i, id := range ended {
+		if err := s.closeSeason(id, now); err != nil {
+			return i, err
+		}
+	}
+	return len(ended), nil
+}
+
+// RunCloser closes the ended seasons every interval until ctx is cancelled.
+func (s *SeasonService) RunCloser(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.CloseEndedSeasons(time.Now()); err != nil {
+			log.Printf("seasons: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeSeason lets the enrollments of an ended season go: members with a seat finish
+// ("finalizado") and seats held for payment or waited for are cancelled, settling their payments
+// as cancelEnrollments does. Each member is told, and whether the activity continues in a later
+// season.
+func (s *SeasonService) closeSeason(id uint, now time.Time) error {
+	return s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		var season models.Season
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&season, id).Error; err != nil {
+			return err
+		}
+		if season.ClosedAt != nil {
+			return nil
+		}
+
+		var activityIDs []uint
+		if err := tx.Model(&models.Activity{}).Where("season_id = ?", id).Pluck("id", &activityIDs).Error; err != nil {
+			return err
+		}
+		var enrollments []models.Enrollment
+		if len(activityIDs) > 0 {
+			if err := tx.Where("activity_id IN ? AND status IN ?", activityIDs, []string{"inscripto", "pendiente_pago", "lista_espera"}).
+				Order("id ASC").
+				Find(&enrollments).Error; err != nil {
+				return err
+			}
+		}
+
+		var continuations []models.Activity
+		if len(enrollments) > 0 {
+			if err := tx.Select("activities.id, activities.source_activity_id").
+				Joins("JOIN seasons ON seasons.id = activities.season_id").
+				Where("activities.source_activity_id IN ? AND seasons.starts_on > ?", activityIDs, season.EndsOn).
+				Find(&continuations).Error; err != nil {
+				return err
+			}
+		}
+		next := make(map[uint]uint, len(continuations))
+		for _, activity := range continuations {
+			next[*activity.SourceActivityID] = activity.ID
+		}
+
+		var finished, cancelled []uint
+		for _, enrollment := range enrollments {
+			if enrollment.Status == "inscripto" {
+				finished = append(finished, enrollment.ID)
+			} else {
+				cancelled = append(cancelled, enrollment.ID)
+			}
+		}
+		if len(finished) > 0 {
+			if err := tx.Model(&models.Enrollment{}).
+				Where("id IN ?", finished).
+				Updates(map[string]interface{}{"status": "finalizado", "schedule_conflict": false}).Error; err != nil {
+				return err
+			}
+		}
+		if err := cancelEnrollments(tx, cancelled); err != nil {
+			return err
+		}
+
+		for _, enrollment := range enrollments {
+			evt := enrollmentEvent(events.EnrollmentFinished, &enrollment)
+			evt.Data = map[string]interface{}{"season_id": season.ID, "season": season.Name, "status": "cancelado"}
+			if enrollment.Status == "inscripto" {
+				evt.Data["status"] = "finalizado"
+			}
+			if activityID, ok := next[enrollment.ActivityID]; ok {
+				evt.Data["next_activity_id"] = activityID
+			}
+			if err := s.events.Record(tx, evt); err != nil {
+				return err
+			}
+		}
+
+		previous := season
+		season.ClosedAt = &now
+		if err := tx.Model(&season).Update("closed_at", now).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, Actor{}, auditChange{
+			Action:     "season.closed",
+			EntityType: AuditSeason,
+			EntityID:   id,
+			Before:     &previous,
+			After:      &season,
+			Extra: map[string]FieldChange{
+				"enrollments_finished":  {After: len(finished)},
+				"enrollments_cancelled": {After: len(cancelled)},
+			},
+		})
+	})
+}
+
+// activitySeasons returns the season of each of the given activities that is held in one.
+func activitySeasons(db *gorm.DB, activityIDs []uint) (map[uint]*models.Season, error) {
+	seasons := map[uint]*models.Season{}
+	if len(activityIDs) == 0 {
+		return seasons, nil
+	}
+	var activities []models.Activity
+	if err := db.Preload("SeasonRef").
+		Select("id", "season_id").
+		Where("id IN ? AND season_id IS NOT NULL", activityIDs).
+		Find(&activities).Error; err != nil {
+		return nil, err
+	}
+	for _, activity := range activities {
+		if activity.SeasonRef != nil {
+			seasons[activity.ID] = activity.SeasonRef
+		}
+	}
+	return seasons, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/alesio/gestion-actividades-deportivas/database/dbtest"
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+)
+
+func TestCloseEndedSeasonsUsesGymDate(t *testing.T) {
+	gym, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	db := dbtest.Open(t, &models.User{}, &models.Season{}, &models.Activity{}, &models.Enrollment{}, &models.AuditLog{})
+	season := models.Season{Name: "Otoño", StartsOn: "2024-03-01", EndsOn: "2024-06-30"}
+	mustCreate(t, db, &season)
+	service := NewSeasonService(db, events.NewBus(), nil, gym)
+
+	// Already July 1st in UTC, but still June 30th at the gym.
+	closed, err := service.CloseEndedSeasons(time.Date(2024, 7, 1, 1, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("CloseEndedSeasons: %v", err)
+	}
+	if closed != 0 {
+		t.Fatalf("closed %d seasons on their last day at the gym, want 0", closed)
+	}
+
+	closed, err = service.CloseEndedSeasons(time.Date(2024, 7, 1, 4, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("CloseEndedSeasons: %v", err)
+	}
+	if closed != 1 {
+		t.Fatalf("closed %d seasons after their last day at the gym, want 1", closed)
+	}
+	if err := db.First(&season, season.ID).Error; err != nil {
+		t.Fatalf("reload season: %v", err)
+	}
+	if season.ClosedAt == nil {
+		t.Fatalf("season was not closed")
+	}
+}
+
+func TestInSeasonInEffectUsesGymDate(t *testing.T) {
+	gym, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	db := dbtest.Open(t, &models.Season{}, &models.Activity{})
+	season := models.Season{Name: "Invierno", StartsOn: "2024-07-01", EndsOn: "2024-09-30"}
+	mustCreate(t, db, &season)
+	activity := models.Activity{Title: "Natación", Category: "agua", DayOfWeek: 1, StartTime: "10:00", EndTime: "11:00",
+		Capacity: 10, Instructor: "Profe", SeasonID: &season.ID}
+	activity.SetStatus(models.ActivityPublished)
+	mustCreate(t, db, &activity)
+
+	count := func(now time.Time) int64 {
+		var n int64
+		if err := inSeasonInEffect(db.Model(&models.Activity{}), gymDate(now, gym)).Count(&n).Error; err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n
+	}
+	if n := count(time.Date(2024, 7, 1, 1, 0, 0, 0, time.UTC)); n != 0 {
+		t.Fatalf("season listed on June 30th at the gym: %d activities", n)
+	}
+	if n := count(time.Date(2024, 7, 1, 4, 0, 0, 0, time.UTC)); n != 1 {
+		t.Fatalf("season not listed on July 1st at the gym: %d activities", n)
+	}
+}
+
+func TestCloseSeasonRefundsPaidWaitlistedMembers(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	season := models.Season{Name: "Otoño", StartsOn: "2024-03-01", EndsOn: "2024-06-30"}
+	mustCreate(t, env.db, &season)
+	activity := env.activity(t, "Yoga", 1, 1000)
+	if err := env.db.Model(activity).Update("season_id", season.ID).Error; err != nil {
+		t.Fatalf("assign season: %v", err)
+	}
+	seated := env.enrollment(t, env.user(t), activity, "inscripto")
+	seatedPayment := env.payment(t, seated, "aprobado")
+	// Moved to the waitlist after paying, when the capacity of the activity went down.
+	waitlisted := env.enrollment(t, env.user(t), activity, "lista_espera")
+	waitlistedPayment := env.payment(t, waitlisted, "aprobado")
+
+	service := NewSeasonService(env.db, env.bus, env.service, time.UTC)
+	if err := service.closeSeason(season.ID, time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("closeSeason: %v", err)
+	}
+
+	for _, tt := range []struct {
+		value  interface{}
+		id     uint
+		status string
+	}{
+		{&models.Enrollment{}, seated.ID, "finalizado"},
+		{&models.Payment{}, seatedPayment.ID, "aprobado"},
+		{&models.Enrollment{}, waitlisted.ID, "cancelado"},
+		{&models.Payment{}, waitlistedPayment.ID, "a_reembolsar"},
+	} {
+		if status := env.status(t, tt.value, tt.id); status != tt.status {
+			t.Fatalf("%T %d status = %s, want %s", tt.value, tt.id, status, tt.status)
+		}
+	}
+}
+
+func TestRestoreVersionBringsBackTheSeason(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	if err := env.db.AutoMigrate(&models.Category{}); err != nil {
+		t.Fatalf("migrate categories: %v", err)
+	}
+	mustCreate(t, env.db, &models.Category{Slug: "yoga", Name: "Yoga"})
+	season := models.Season{Name: "Verano", StartsOn: "2024-01-01", EndsOn: "2999-12-31"}
+	mustCreate(t, env.db, &season)
+	service := NewActivityService(env.db, env.bus, nil, env.waitlist, time.UTC)
+
+	activity := models.Activity{Title: "Yoga", Category: "yoga", DayOfWeek: 1, StartTime: "10:00", EndTime: "11:00",
+		Capacity: 10, Instructor: "Profe", SeasonID: &season.ID}
+	if err := service.CreateActivity(&activity, UserActor(0)); err != nil {
+		t.Fatalf("CreateActivity: %v", err)
+	}
+	allYear := func() {
+		t.Helper()
+		activity.SeasonID = nil
+		if _, err := service.UpdateActivity(&activity, "", UserActor(0)); err != nil {
+			t.Fatalf("UpdateActivity: %v", err)
+		}
+	}
+
+	allYear()
+	restored, _, err := service.RestoreActivityVersion(activity.ID, 1, "", UserActor(0))
+	if err != nil {
+		t.Fatalf("RestoreActivityVersion: %v", err)
+	}
+	if restored.SeasonID == nil || *restored.SeasonID != season.ID {
+		t.Fatalf("restored season = %v, want %d", restored.SeasonID, season.ID)
+	}
+
+	allYear()
+	if err := env.db.Model(&season).Update("ends_on", "2024-03-31").Error; err != nil {
+		t.Fatalf("end season: %v", err)
+	}
+	if _, _, err := service.RestoreActivityVersion(activity.ID, 1, "", UserActor(0)); !errors.Is(err, ErrSeasonEnded) {
+		t.Fatalf("restoring into an ended season = %v, want %v", err, ErrSeasonEnded)
+	}
+}
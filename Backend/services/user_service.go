@@ -2,7 +2,10 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/alesio/gestion-actividades-deportivas/events"
 	"github.com/alesio/gestion-actividades-deportivas/models"
 	"github.com/alesio/gestion-actividades-deportivas/security"
 	"gorm.io/gorm"
@@ -10,13 +13,17 @@ import (
 
 // UserService manages CRUD logic for users.
 type UserService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	events *events.Bus
 }
 
-var ErrEmailAlreadyExists = errors.New("email already exists")
+var (
+	ErrEmailAlreadyExists = errors.New("email already exists")
+	ErrInvalidProfile     = errors.New("invalid user profile")
+)
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(db *gorm.DB, bus *events.Bus) *UserService {
+	return &UserService{db: db, events: bus}
 }
 
 func (s *UserService) GetByID(id uint) (*models.User, error) {
@@ -56,7 +63,67 @@ func (s *UserService) CreateUser(name, email, password, role string) (*models.Us
 		Role:         role,
 	}
 
-	if err := s.db.Create(&user).Error; err != nil {
+	err = s.events.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		evt := events.New(events.UserRegistered)
+		evt.UserID = user.ID
+		return s.events.Record(tx, evt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateProfile sets the birth date (YYYY-MM-DD) and level of a user, which eligibility rules
+// check; empty values clear them. The changed fields are written to the audit log.
+func (s *UserService) UpdateProfile(userID uint, birthDate, level string, actor Actor) (*models.User, error) {
+	if birthDate != "" {
+		parsed, err := time.Parse(sessionDateLayout, birthDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: birth_date must be YYYY-MM-DD", ErrInvalidProfile)
+		}
+		if parsed.After(time.Now()) {
+			return nil, fmt.Errorf("%w: birth_date is in the future", ErrInvalidProfile)
+		}
+	}
+	if level != "" && levelRank(level) < 0 {
+		return nil, fmt.Errorf("%w: level must be one of %v", ErrInvalidProfile, models.MemberLevels)
+	}
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		changes := make(map[string]FieldChange)
+		if user.BirthDate != birthDate {
+			changes["birth_date"] = FieldChange{Before: user.BirthDate, After: birthDate}
+		}
+		if user.Level != level {
+			changes["level"] = FieldChange{Before: user.Level, After: level}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		user.BirthDate, user.Level = birthDate, level
+		if err := tx.Model(&user).Updates(map[string]interface{}{"birth_date": birthDate, "level": level}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, auditChange{
+			Action:     "user.profile_updated",
+			EntityType: AuditUser,
+			EntityID:   userID,
+			Extra:      changes,
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &user, nil
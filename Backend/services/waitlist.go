@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Waitlist hands the seats freed in an activity to its waitlisted members. A waitlisted member
+// is admitted like a new enrollment: the season window, the eligibility rules, the schedule and
+// the plan quota are checked in the gym's time zone, location. Members of a paid activity without
+// an approved payment get the seat held for holdTTL while they pay, as when enrolling.
+type Waitlist struct {
+	db          *gorm.DB
+	memberships *MembershipService
+	events      *events.Bus
+	location    *time.Location
+	holdTTL     time.Duration
+}
+
+func NewWaitlist(db *gorm.DB, memberships *MembershipService, bus *events.Bus, location *time.Location, holdTTL time.Duration) *Waitlist {
+	return &Waitlist{db: db, memberships: memberships, events: bus, location: location, holdTTL: holdTTL}
+}
+
+// RunHoldExpiry lets the lapsed payment holds go every interval until ctx is cancelled, so their
+// seats reach the waitlist even when nothing else touches the activity.
+func (w *Waitlist) RunHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.expireStaleHolds(); err != nil {
+			log.Printf("waitlist: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireStaleHolds releases seats and checkouts whose payment window has passed. Each released
+// seat is announced and offered to the waitlist of its activity.
+func (w *Waitlist) expireStaleHolds() error {
+	now := time.Now()
+	return w.events.Transaction(w.db, func(tx *gorm.DB) error {
+		var expired []models.Enrollment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND hold_expires_at <= ?", "pendiente_pago", now).
+			Order("id ASC").
+			Find(&expired).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Payment{}).
+			Where("status = ? AND expires_at <= ?", "pendiente", now).
+			Update("status", "expirado").Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(expired))
+		for i, enrollment := range expired {
+			ids[i] = enrollment.ID
+		}
+		if err := tx.Model(&models.Enrollment{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{"status": "expirado", "hold_expires_at": nil}).Error; err != nil {
+			return err
+		}
+		var activityIDs []uint
+		seen := make(map[uint]bool)
+		for _, enrollment := range expired {
+			evt := enrollmentEvent(events.EnrollmentReleased, &enrollment)
+			evt.Data = map[string]interface{}{"status": "expirado"}
+			if err := w.events.Record(tx, evt); err != nil {
+				return err
+			}
+			if !seen[enrollment.ActivityID] {
+				seen[enrollment.ActivityID] = true
+				activityIDs = append(activityIDs, enrollment.ActivityID)
+			}
+		}
+		for _, activityID := range activityIDs {
+			if err := w.promote(tx, activityID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// promote fills the free seats of an active activity with its waitlisted members, oldest first,
+// and notifies each promoted member. Members who would not be admitted stay on the waitlist.
+func (w *Waitlist) promote(tx *gorm.DB, activityID uint) error {
+	var activity models.Activity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&activity, activityID).Error; err != nil {
+		return err
+	}
+	if !activity.IsActive {
+		return nil
+	}
+
+	now := time.Now().In(w.location)
+	var taken int64
+	if err := seatHoldersAt(tx.Model(&models.Enrollment{}), now).
+		Where("activity_id = ?", activityID).
+		Count(&taken).Error; err != nil {
+		return err
+	}
+	free := activity.Capacity - int(taken)
+	if free <= 0 {
+		return nil
+	}
+
+	var waitlist []models.Enrollment
+	if err := tx.Where("activity_id = ? AND status = ?", activityID, "lista_espera").
+		Order("waitlisted_at ASC, id ASC").
+		Find(&waitlist).Error; err != nil {
+		return err
+	}
+
+	var userIDs []uint
+	for _, enrollment := range waitlist {
+		if free == 0 {
+			break
+		}
+		// Same lock order as enrolling: the activity, then the member.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, enrollment.UserID).Error; err != nil {
+			return err
+		}
+		if err := checkAdmission(tx, w.memberships, enrollment.UserID, &activity, now); err != nil {
+			if isAdmissionRefusal(err) {
+				continue
+			}
+			return err
+		}
+
+		updates := map[string]interface{}{"status": "inscripto", "waitlisted_at": nil}
+		if activity.PriceCents > 0 {
+			var paid int64
+			if err := tx.Model(&models.Payment{}).
+				Where("enrollment_id = ? AND status = ?", enrollment.ID, "aprobado").
+				Count(&paid).Error; err != nil {
+				return err
+			}
+			if paid == 0 {
+				holdUntil := now.Add(w.holdTTL)
+				updates = map[string]interface{}{"status": "pendiente_pago", "waitlisted_at": nil, "hold_expires_at": holdUntil}
+			}
+		}
+		if err := tx.Model(&enrollment).Updates(updates).Error; err != nil {
+			return err
+		}
+		evt := enrollmentEvent(events.EnrollmentPromoted, &enrollment)
+		evt.Data = map[string]interface{}{"status": updates["status"]}
+		if updates["status"] == "pendiente_pago" {
+			evt.Data["hold_minutes"] = int(w.holdTTL / time.Minute)
+		}
+		if err := w.events.Record(tx, evt); err != nil {
+			return err
+		}
+		userIDs = append(userIDs, enrollment.UserID)
+		free--
+	}
+	return refreshScheduleConflicts(tx, userIDs)
+}
+
+// checkAdmission runs the checks a member must pass to take a seat in activity on now, in the
+// gym's time zone: the season window, the eligibility rules, the schedule and the plan quota.
+func checkAdmission(tx *gorm.DB, memberships *MembershipService, userID uint, activity *models.Activity, now time.Time) error {
+	if err := checkEnrollmentWindow(tx, userID, activity, now.Format(sessionDateLayout)); err != nil {
+		return err
+	}
+	if err := checkEligibility(tx, userID, activity, now); err != nil {
+		return err
+	}
+	if err := ensureNoScheduleConflict(tx, userID, activity); err != nil {
+		return err
+	}
+	return memberships.checkQuota(tx, userID, activity, now, 0)
+}
+
+// isAdmissionRefusal reports whether err is checkAdmission turning the member down, rather than
+// a failure to check.
+func isAdmissionRefusal(err error) bool {
+	for _, refusal := range []error{ErrSeasonEnded, ErrEnrollmentNotOpen, ErrNotEligible, ErrScheduleConflict, ErrQuotaExceeded, ErrMembershipRequired} {
+		if errors.Is(err, refusal) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+func TestPromoteAdmitsWaitlistLikeEnrolling(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	activity := env.activity(t, "Yoga", 2, 1000)
+	sameTime := env.activity(t, "Pilates", 10, 0)
+	otherDay := env.activity(t, "Spinning", 10, 0)
+	if err := env.db.Model(otherDay).Update("day_of_week", 3).Error; err != nil {
+		t.Fatalf("move activity: %v", err)
+	}
+
+	// Oldest first: over the plan quota, with a schedule conflict, paid, unpaid and one too many.
+	overQuota := env.user(t)
+	plan := models.MembershipPlan{Name: "Una clase", WeeklyQuota: 1, DurationDays: 30, IsActive: true}
+	mustCreate(t, env.db, &plan)
+	now := time.Now()
+	mustCreate(t, env.db, &models.Membership{UserID: overQuota.ID, PlanID: plan.ID, StartsAt: now.Add(-time.Hour), EndsAt: now.AddDate(0, 0, 30), Status: "activa"})
+	env.enrollment(t, overQuota, otherDay, "inscripto")
+	overQuotaWait := env.enrollment(t, overQuota, activity, "lista_espera")
+
+	busy := env.user(t)
+	env.enrollment(t, busy, sameTime, "inscripto")
+	busyWait := env.enrollment(t, busy, activity, "lista_espera")
+
+	paidWait := env.enrollment(t, env.user(t), activity, "lista_espera")
+	env.payment(t, paidWait, "aprobado")
+	unpaid := env.user(t)
+	unpaidWait := env.enrollment(t, unpaid, activity, "lista_espera")
+	lastWait := env.enrollment(t, env.user(t), activity, "lista_espera")
+
+	err := env.bus.Transaction(env.db, func(tx *gorm.DB) error {
+		return env.waitlist.promote(tx, activity.ID)
+	})
+	if err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		enrollment *models.Enrollment
+		status     string
+	}{
+		{"over the plan quota", overQuotaWait, "lista_espera"},
+		{"with a schedule conflict", busyWait, "lista_espera"},
+		{"already paid", paidWait, "inscripto"},
+		{"not paid yet", unpaidWait, "pendiente_pago"},
+		{"without a free seat", lastWait, "lista_espera"},
+	} {
+		if status := env.status(t, &models.Enrollment{}, tt.enrollment.ID); status != tt.status {
+			t.Fatalf("member %s: status = %s, want %s", tt.name, status, tt.status)
+		}
+	}
+	if promoted := countEvents(env.events, events.EnrollmentPromoted); promoted != 2 {
+		t.Fatalf("%d promotions notified, want 2", promoted)
+	}
+
+	// Enrolling again opens the checkout of the seat held for the unpaid member.
+	enrollment, err := env.service.EnrollUserInActivity(unpaid.ID, activity.ID, UserActor(unpaid.ID))
+	if err != nil {
+		t.Fatalf("EnrollUserInActivity for a promoted seat: %v", err)
+	}
+	if enrollment.ID != unpaidWait.ID || enrollment.Payment == nil || enrollment.Payment.CheckoutURL == "" {
+		t.Fatalf("enrollment %d with payment %+v, want the held seat %d with a checkout", enrollment.ID, enrollment.Payment, unpaidWait.ID)
+	}
+}
+
+func countEvents(types []string, eventType string) int {
+	n := 0
+	for _, t := range types {
+		if t == eventType {
+			n++
+		}
+	}
+	return n
+}
+
+func TestExpireStaleHoldsPromotesWaitlist(t *testing.T) {
+	env := newEnrollmentTestEnv(t)
+	activity := env.activity(t, "Yoga", 1, 0)
+	lapsed := time.Now().Add(-time.Minute)
+	hold := models.Enrollment{UserID: env.user(t).ID, ActivityID: activity.ID, Status: "pendiente_pago", HoldExpiresAt: &lapsed}
+	mustCreate(t, env.db, &hold)
+	waiting := env.enrollment(t, env.user(t), activity, "lista_espera")
+
+	if err := env.waitlist.expireStaleHolds(); err != nil {
+		t.Fatalf("expireStaleHolds: %v", err)
+	}
+	if status := env.status(t, &models.Enrollment{}, hold.ID); status != "expirado" {
+		t.Fatalf("lapsed hold status = %s, want expirado", status)
+	}
+	if status := env.status(t, &models.Enrollment{}, waiting.ID); status != "inscripto" {
+		t.Fatalf("waitlisted member status = %s, want inscripto", status)
+	}
+	want := []string{events.EnrollmentReleased, events.EnrollmentPromoted}
+	if len(env.events) != len(want) || env.events[0] != want[0] || env.events[1] != want[1] {
+		t.Fatalf("events = %v, want %v", env.events, want)
+	}
+
+	// Nothing left to expire: no new events.
+	if err := env.waitlist.expireStaleHolds(); err != nil {
+		t.Fatalf("expireStaleHolds: %v", err)
+	}
+	if len(env.events) != len(want) {
+		t.Fatalf("events after a second run = %v, want %v", env.events, want)
+	}
+}
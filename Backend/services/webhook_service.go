@@ -0,0 +1,247 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"github.com/alesio/gestion-actividades-deportivas/webhooks"
+	"gorm.io/gorm"
+)
+
+const webhookSecretPrefix = "whsec_"
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL       = errors.New("invalid webhook url")
+	ErrInvalidEventType        = errors.New("invalid event type")
+)
+
+// WebhookInput carries the editable fields of a subscription.
+type WebhookInput struct {
+	Name       string
+	URL        string
+	EventTypes []string
+	IsActive   bool
+}
+
+// WebhookView is the public representation of a subscription, including its event types.
+type WebhookView struct {
+	models.WebhookSubscription
+	EventTypes []string `json:"event_types"`
+}
+
+// WebhookService manages the outgoing webhook subscriptions and their delivery log.
+type WebhookService struct {
+	db *gorm.DB
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+// CreateSubscription stores a subscription and returns it together with its signing secret,
+// which is never retrievable again.
+func (s *WebhookService) CreateSubscription(input WebhookInput, createdByID uint) (*WebhookView, string, error) {
+	eventTypes, err := normalizeWebhookInput(&input)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	subscription := models.WebhookSubscription{
+		Name:        input.Name,
+		URL:         input.URL,
+		Secret:      secret,
+		EventTypes:  strings.Join(eventTypes, " "),
+		IsActive:    input.IsActive,
+		CreatedByID: createdByID,
+	}
+	if err := s.db.Create(&subscription).Error; err != nil {
+		return nil, "", err
+	}
+	// is_active has a database default, so GORM skips a false value on create.
+	if !input.IsActive {
+		if err := s.db.Model(&subscription).Update("is_active", false).Error; err != nil {
+			return nil, "", err
+		}
+		subscription.IsActive = false
+	}
+	return toWebhookView(subscription), secret, nil
+}
+
+func (s *WebhookService) ListSubscriptions() ([]WebhookView, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	views := make([]WebhookView, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		views = append(views, *toWebhookView(subscription))
+	}
+	return views, nil
+}
+
+// UpdateSubscription replaces the editable fields; the secret is kept.
+func (s *WebhookService) UpdateSubscription(id uint, input WebhookInput) (*WebhookView, error) {
+	eventTypes, err := normalizeWebhookInput(&input)
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := s.getSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(subscription).Updates(map[string]interface{}{
+		"name":        input.Name,
+		"url":         input.URL,
+		"event_types": strings.Join(eventTypes, " "),
+		"is_active":   input.IsActive,
+	}).Error; err != nil {
+		return nil, err
+	}
+	subscription.Name = input.Name
+	subscription.URL = input.URL
+	subscription.EventTypes = strings.Join(eventTypes, " ")
+	subscription.IsActive = input.IsActive
+	return toWebhookView(*subscription), nil
+}
+
+// RotateSecret replaces the signing secret and returns the new one. Pending retries are signed
+// with it as well.
+func (s *WebhookService) RotateSecret(id uint) (string, error) {
+	subscription, err := s.getSubscription(id)
+	if err != nil {
+		return "", err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.db.Model(subscription).Update("secret", secret).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// DeleteSubscription removes a subscription together with its delivery log.
+func (s *WebhookService) DeleteSubscription(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+		return nil
+	})
+}
+
+// ListDeliveries returns the latest deliveries of a subscription, optionally filtered by status.
+func (s *WebhookService) ListDeliveries(subscriptionID uint, status string, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.getSubscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	query := s.db.Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery queues a copy of a past delivery with the same payload. The original entry is
+// kept untouched in the log.
+func (s *WebhookService) ReplayDelivery(subscriptionID, deliveryID uint) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := s.db.Where("id = ? AND subscription_id = ?", deliveryID, subscriptionID).First(&original).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	replay := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		ReplayOfID:     &original.ID,
+		Status:         "pendiente",
+		NextAttemptAt:  time.Now(),
+	}
+	if err := s.db.Create(&replay).Error; err != nil {
+		return nil, err
+	}
+	return &replay, nil
+}
+
+func (s *WebhookService) getSubscription(id uint) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := s.db.First(&subscription, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// normalizeWebhookInput validates the endpoint URL and returns the sorted, de-duplicated event types.
+func normalizeWebhookInput(input *WebhookInput) ([]string, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.URL = strings.TrimSpace(input.URL)
+	parsed, err := url.Parse(input.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if len(input.EventTypes) == 0 {
+		return nil, fmt.Errorf("%w: at least one is required", ErrInvalidEventType)
+	}
+
+	seen := map[string]bool{}
+	var eventTypes []string
+	for _, eventType := range input.EventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !webhooks.IsEventType(eventType) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEventType, eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	sort.Strings(eventTypes)
+	return eventTypes, nil
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(buf), nil
+}
+
+func toWebhookView(subscription models.WebhookSubscription) *WebhookView {
+	return &WebhookView{
+		WebhookSubscription: subscription,
+		EventTypes:          strings.Fields(subscription.EventTypes),
+	}
+}
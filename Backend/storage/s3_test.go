@@ -0,0 +1,90 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alesio/gestion-actividades-deportivas/storage"
+	"github.com/alesio/gestion-actividades-deportivas/storage/s3fake"
+)
+
+func newS3Store(t *testing.T, secret string) *storage.S3Store {
+	t.Helper()
+	server := httptest.NewServer(s3fake.New("/dev/s3", "sa-east-1", "AKIDTEST", "s3cret"))
+	t.Cleanup(server.Close)
+
+	store, err := storage.NewS3Store(storage.S3Config{
+		Endpoint:        server.URL + "/dev/s3",
+		Region:          "sa-east-1",
+		Bucket:          "uploads",
+		AccessKeyID:     "AKIDTEST",
+		SecretAccessKey: secret,
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return store
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	store := newS3Store(t, "s3cret")
+	ctx := context.Background()
+	key := "activities/12/ab12cd/thumb.jpg"
+
+	if err := store.Put(ctx, key, []byte("jpeg bytes"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reader, info, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if string(body) != "jpeg bytes" {
+		t.Fatalf("body = %q", body)
+	}
+	if info.ContentType != "image/jpeg" || info.Size != int64(len(body)) || info.ETag == "" || info.ModTime.IsZero() {
+		t.Fatalf("info = %+v", info)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := store.Get(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want %v", err, storage.ErrNotFound)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestS3StoreGetMissing(t *testing.T) {
+	store := newS3Store(t, "s3cret")
+	if _, _, err := store.Get(context.Background(), "activities/1/missing.jpg"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get = %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	store := newS3Store(t, "wrong-secret")
+	err := store.Put(context.Background(), "activities/1/a.jpg", []byte("x"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Put with a wrong secret = %v, want SignatureDoesNotMatch", err)
+	}
+}
+
+func TestS3StoreInvalidKey(t *testing.T) {
+	store := newS3Store(t, "s3cret")
+	if err := store.Put(context.Background(), "../escape", []byte("x"), ""); !errors.Is(err, storage.ErrInvalidKey) {
+		t.Fatalf("Put = %v, want %v", err, storage.ErrInvalidKey)
+	}
+}
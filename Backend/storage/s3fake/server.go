@@ -0,0 +1,162 @@
+// Package s3fake is an in-memory S3-compatible object store for local development and tests. It
+// verifies AWS Signature Version 4 like the real service, so storage.S3Store can be exercised end
+// to end against it with path-style addressing. Buckets are created on first write.
+package s3fake
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/storage"
+)
+
+// maxClockSkew is how far X-Amz-Date may be from the server clock, as in S3.
+const maxClockSkew = 15 * time.Minute
+
+// maxObjectSize bounds a single PUT.
+const maxObjectSize = 64 << 20
+
+type object struct {
+	body        []byte
+	contentType string
+	etag        string
+	modTime     time.Time
+}
+
+// Server serves the S3 object API (PUT, GET, HEAD and DELETE of objects) below BasePath.
+type Server struct {
+	// BasePath is the path the server is mounted at, e.g. "/dev/s3". Requests are signed over the
+	// full path, so the server must see it unstripped.
+	BasePath string
+
+	signer storage.SigV4
+
+	mu      sync.RWMutex
+	objects map[string]object
+}
+
+func New(basePath, region, accessKeyID, secretAccessKey string) *Server {
+	return &Server{
+		BasePath: strings.TrimRight(basePath, "/"),
+		signer:   storage.SigV4{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, Region: region, Service: "s3"},
+		objects:  map[string]object{},
+	}
+}
+
+type errorDocument struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorDocument{Code: code, Message: message})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, s.BasePath+"/") {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, s.BasePath+"/"), "/")
+	if bucket == "" || !storage.ValidKey(key) {
+		writeError(w, http.StatusBadRequest, "InvalidURI", "Expected /{bucket}/{key}")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxObjectSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if len(body) > maxObjectSize {
+		writeError(w, http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed size")
+		return
+	}
+	if code, message := s.verify(r, body); code != "" {
+		writeError(w, http.StatusForbidden, code, message)
+		return
+	}
+
+	id := bucket + "/" + key
+	switch r.Method {
+	case http.MethodPut:
+		sum := md5.Sum(body)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		s.mu.Lock()
+		s.objects[id] = object{
+			body:        body,
+			contentType: r.Header.Get("Content-Type"),
+			etag:        etag,
+			modTime:     time.Now().UTC().Truncate(time.Second),
+		}
+		s.mu.Unlock()
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		s.mu.RLock()
+		obj, ok := s.objects[id]
+		s.mu.RUnlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		contentType := obj.contentType
+		if contentType == "" {
+			contentType = "binary/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.body)))
+		w.Header().Set("ETag", obj.etag)
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(obj.body)
+		}
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, id)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+// verify checks the request signature the way S3 does and returns the error code on failure.
+func (s *Server) verify(r *http.Request, body []byte) (string, string) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return "AccessDenied", "Anonymous access is not allowed"
+	}
+	if !strings.Contains(authorization, "Credential="+s.signer.AccessKeyID+"/") {
+		return "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records."
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(storage.AmzDateLayout, amzDate)
+	if err != nil {
+		return "AccessDenied", "X-Amz-Date is missing or invalid"
+	}
+	if skew := time.Since(signedAt); skew > maxClockSkew || skew < -maxClockSkew {
+		return "RequestTimeTooSkewed", "The difference between the request time and the current time is too large."
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != storage.HashHex(body) {
+		return "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed."
+	}
+	expected := s.signer.Authorization(r, payloadHash, amzDate)
+	if !hmac.Equal([]byte(expected), []byte(authorization)) {
+		return "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided."
+	}
+	return "", ""
+}
@@ -0,0 +1,57 @@
+// Package storage keeps binary objects, such as activity images, behind the BlobStore interface.
+// LocalStore writes them to a directory; S3Store talks to any S3-compatible service, and
+// storage/s3fake provides one in process for development and tests.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobInfo describes a stored object.
+type BlobInfo struct {
+	ContentType string
+	Size        int64
+	// ETag identifies the content; it changes whenever the object is rewritten.
+	ETag    string
+	ModTime time.Time
+}
+
+// BlobStore stores objects under slash separated keys such as "activities/12/ab12cd/thumb.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// Get opens an object; the caller closes the reader. It fails with ErrNotFound when missing.
+	Get(ctx context.Context, key string) (io.ReadCloser, *BlobInfo, error)
+	// Delete removes an object; deleting a missing one is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is made of non-empty segments of letters, digits, ".", "-" and
+// "_", none of them "." or "..". Keys are used as file paths and URL paths, so nothing else is
+// allowed.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+// Package webhooks delivers domain events to the external endpoints admins subscribe, such as a
+// CRM or the turnstile vendor. Deliveries are written on the transaction of the change that
+// produced the event and POSTed by a background worker, signed with the subscription secret.
+package webhooks
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/events"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// subscribable lists the event types an endpoint may subscribe to. Reminders are member-only.
+var subscribable = map[string]bool{
+	events.ActivityCreated:          true,
+	events.ActivityUpdated:          true,
+	events.ActivityDeactivated:      true,
+	events.ActivityRescheduled:      true,
+	events.ActivityPaused:           true,
+	events.ActivityPublished:        true,
+	events.ActivityPurged:           true,
+	events.ActivitySessionCancelled: true,
+	events.ActivitySessionRestored:  true,
+	events.EnrollmentConfirmed:      true,
+	events.EnrollmentCancelled:      true,
+	events.EnrollmentWaitlisted:     true,
+	events.EnrollmentPromoted:       true,
+	events.EnrollmentFinished:       true,
+	events.UserRegistered:           true,
+}
+
+// EventTypes lists the subscribable event types sorted by name.
+func EventTypes() []string {
+	types := make([]string, 0, len(subscribable))
+	for eventType := range subscribable {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// IsEventType reports whether eventType can be subscribed to.
+func IsEventType(eventType string) bool {
+	return subscribable[eventType]
+}
+
+// Payload is the JSON body POSTed to subscribers.
+type Payload struct {
+	Type         string                 `json:"type"`
+	OccurredAt   time.Time              `json:"occurred_at"`
+	EnrollmentID uint                   `json:"enrollment_id,omitempty"`
+	Activity     *models.Activity       `json:"activity,omitempty"`
+	User         *PayloadUser           `json:"user,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+}
+
+// PayloadUser is the member an event concerns.
+type PayloadUser struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Dispatcher is an events.Recorder that queues one delivery per matching active subscription.
+type Dispatcher struct{}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+func (d *Dispatcher) Record(tx *gorm.DB, evt events.Event) error {
+	if !subscribable[evt.Type] {
+		return nil
+	}
+	var subscriptions []models.WebhookSubscription
+	if err := tx.Where("is_active = ?", true).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+	var targets []uint
+	for _, subscription := range subscriptions {
+		if subscribes(subscription, evt.Type) {
+			targets = append(targets, subscription.ID)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	body, err := buildPayload(tx, evt)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(targets))
+	for _, subscriptionID := range targets {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: subscriptionID,
+			EventType:      evt.Type,
+			Payload:        string(body),
+			Status:         "pendiente",
+			NextAttemptAt:  now,
+		})
+	}
+	return tx.Create(&deliveries).Error
+}
+
+func subscribes(subscription models.WebhookSubscription, eventType string) bool {
+	for _, wanted := range strings.Fields(subscription.EventTypes) {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// buildPayload snapshots the activity and member of the event as they are in tx, so the
+// subscriber sees the state right after the change even if the delivery is retried later.
+func buildPayload(tx *gorm.DB, evt events.Event) ([]byte, error) {
+	payload := Payload{
+		Type:         evt.Type,
+		OccurredAt:   evt.OccurredAt,
+		EnrollmentID: evt.EnrollmentID,
+		Data:         evt.Data,
+	}
+	if evt.ActivityID != 0 {
+		var activity models.Activity
+		if err := tx.First(&activity, evt.ActivityID).Error; err != nil {
+			return nil, err
+		}
+		payload.Activity = &activity
+	}
+	if evt.UserID != 0 {
+		var user models.User
+		if err := tx.First(&user, evt.UserID).Error; err != nil {
+			return nil, err
+		}
+		payload.User = &PayloadUser{ID: user.ID, Name: user.Name, Email: user.Email}
+	}
+	return json.Marshal(payload)
+}
@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesio/gestion-actividades-deportivas/delivery"
+	"github.com/alesio/gestion-actividades-deportivas/models"
+	"gorm.io/gorm"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// maxBackoff caps the wait between retries of a delivery.
+const maxBackoff = 6 * time.Hour
+
+// Sign returns the SignatureHeader value for body sent at timestamp: "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>" with the subscription secret. Including the
+// timestamp lets receivers reject replayed requests.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Worker polls pending deliveries and POSTs them, retrying failures with exponential backoff
+// until maxAttempts. Several instances can run at once, see delivery.Claim.
+type Worker struct {
+	db          *gorm.DB
+	client      *http.Client
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+}
+
+func NewWorker(db *gorm.DB, interval time.Duration, maxAttempts int) *Worker {
+	return &Worker{
+		db:          db,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		interval:    interval,
+		batchSize:   50,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run delivers webhooks until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	delivery.Run(ctx, "webhooks", w.interval, w.DeliverPending)
+}
+
+// DeliverPending sends one batch of due deliveries.
+func (w *Worker) DeliverPending(ctx context.Context) error {
+	deliveries, err := delivery.Claim[models.WebhookDelivery](w.db, w.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, pending := range deliveries {
+		w.deliver(ctx, pending)
+	}
+	return nil
+}
+
+// errSubscriptionDisabled fails a delivery right away: its endpoint no longer wants events.
+var errSubscriptionDisabled = errors.New("subscription is disabled")
+
+func (w *Worker) deliver(ctx context.Context, pending models.WebhookDelivery) {
+	status, sendErr := w.send(ctx, pending)
+
+	updates := delivery.Outcome(pending.Attempts+1, w.maxAttempts, maxBackoff, sendErr,
+		errors.Is(sendErr, errSubscriptionDisabled), "delivered_at")
+	updates["response_status"] = status
+	if err := w.db.Model(&models.WebhookDelivery{}).Where("id = ?", pending.ID).Updates(updates).Error; err != nil {
+		log.Printf("webhooks: could not update delivery %d: %v", pending.ID, err)
+	}
+}
+
+// send POSTs the payload with the current secret of the subscription, so rotating it applies
+// to retries too. It returns the HTTP status received, or 0 when there was no response.
+func (w *Worker) send(ctx context.Context, pending models.WebhookDelivery) (int, error) {
+	var subscription models.WebhookSubscription
+	if err := w.db.First(&subscription, pending.SubscriptionID).Error; err != nil {
+		return 0, err
+	}
+	if !subscription.IsActive {
+		return 0, errSubscriptionDisabled
+	}
+
+	body := []byte(pending.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, strings.NewReader(pending.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, pending.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(pending.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -1,4 +1,5 @@
-import { useState } from 'react'
+import { useEffect, useState } from 'react'
+import { listCategories } from '../services/activitiesService.js'
 
 const DAY_OPTIONS = [
   { value: 0, label: 'Domingo' },
@@ -19,15 +20,27 @@ const defaultValues = {
   endTime: '09:00',
   capacity: 10,
   instructor: '',
+  location: '',
   imageUrl: '',
-  isActive: true,
+  isDraft: false,
 }
 
-const ActivityForm = ({ initialValues = {}, onSubmit, submitLabel = 'Guardar' }) => {
+// onUploadImage(file) sube una imagen y devuelve { imageUrl }; sin él solo se acepta una URL.
+const ActivityForm = ({ initialValues = {}, onSubmit, onUploadImage, submitLabel = 'Guardar' }) => {
   const [formValues, setFormValues] = useState({
     ...defaultValues,
     ...initialValues,
   })
+  const [categories, setCategories] = useState([])
+  const [imageStatus, setImageStatus] = useState('')
+  // El estado se elige al crear; una actividad existente lo cambia desde su detalle.
+  const isNew = !initialValues.id
+
+  useEffect(() => {
+    listCategories()
+      .then(setCategories)
+      .catch(() => setCategories([]))
+  }, [])
 
   const handleChange = (event) => {
     const { name, value, type, checked } = event.target
@@ -44,20 +57,38 @@ const ActivityForm = ({ initialValues = {}, onSubmit, submitLabel = 'Guardar' })
     }))
   }
 
+  const handleImageFile = async (event) => {
+    const file = event.target.files?.[0]
+    event.target.value = ''
+    if (!file || !onUploadImage) return
+    setImageStatus('Subiendo imagen…')
+    try {
+      const image = await onUploadImage(file)
+      setFormValues((prev) => ({ ...prev, imageUrl: image.imageUrl }))
+      setImageStatus('Imagen subida.')
+    } catch (error) {
+      setImageStatus(error.message ?? 'No se pudo subir la imagen.')
+    }
+  }
+
   const handleSubmit = (event) => {
     event.preventDefault()
 
     const payload = {
       title: formValues.title.trim(),
       description: formValues.description.trim(),
-      category: formValues.category.trim(),
+      category: formValues.category,
+      categoryId: categories.find((category) => category.slug === formValues.category)?.id ?? null,
       dayOfWeek: Number(formValues.dayOfWeek),
       startTime: formValues.startTime,
       endTime: formValues.endTime,
       capacity: Number(formValues.capacity),
       instructor: formValues.instructor.trim(),
+      location: formValues.location.trim(),
       imageUrl: formValues.imageUrl.trim(),
-      isActive: Boolean(formValues.isActive),
+    }
+    if (isNew) {
+      payload.status = formValues.isDraft ? 'borrador' : 'publicada'
     }
 
     onSubmit(payload)
@@ -79,7 +110,16 @@ const ActivityForm = ({ initialValues = {}, onSubmit, submitLabel = 'Guardar' })
         />
       </div>
       <div className="login-field">
-        <input name="category" value={formValues.category} onChange={handleChange} placeholder="Categoría" required />
+        <select name="category" value={formValues.category} onChange={handleChange} aria-label="Categoría" required>
+          <option value="" disabled>
+            Categoría
+          </option>
+          {categories.map((category) => (
+            <option key={category.id} value={category.slug}>
+              {category.name}
+            </option>
+          ))}
+        </select>
       </div>
       <div className="login-field">
         <select
@@ -134,6 +174,14 @@ const ActivityForm = ({ initialValues = {}, onSubmit, submitLabel = 'Guardar' })
           required
         />
       </div>
+      <div className="login-field">
+        <input
+          name="location"
+          value={formValues.location}
+          onChange={handleChange}
+          placeholder="Sala o lugar (opcional)"
+        />
+      </div>
       <div className="login-field">
         <input
           name="imageUrl"
@@ -142,12 +190,25 @@ const ActivityForm = ({ initialValues = {}, onSubmit, submitLabel = 'Guardar' })
           placeholder="URL de imagen (opcional)"
         />
       </div>
-      <div className="login-field checkbox-field">
-        <label>
-          <input type="checkbox" name="isActive" checked={Boolean(formValues.isActive)} onChange={handleChange} />
-          <span>Actividad visible y disponible</span>
-        </label>
-      </div>
+      {onUploadImage && (
+        <div className="login-field">
+          <input
+            type="file"
+            accept="image/jpeg,image/png,image/gif,image/webp"
+            onChange={handleImageFile}
+            aria-label="Subir imagen"
+          />
+          {imageStatus && <p className="activity-label">{imageStatus}</p>}
+        </div>
+      )}
+      {isNew && (
+        <div className="login-field checkbox-field">
+          <label>
+            <input type="checkbox" name="isDraft" checked={Boolean(formValues.isDraft)} onChange={handleChange} />
+            <span>Guardar como borrador (no visible para socios hasta publicarla)</span>
+          </label>
+        </div>
+      )}
 
       <div className="login-actions">
         <button type="submit" className="btn-primary">
@@ -1,5 +1,6 @@
 import { useEffect, useMemo, useState } from 'react'
 import placeholderImage from '../img/activity-placeholder.svg'
+import { getApiBaseUrl } from '../services/apiClient.js'
 
 const sanitizeImageUrl = (rawUrl) => {
   if (!rawUrl) return ''
@@ -13,6 +14,11 @@ const sanitizeImageUrl = (rawUrl) => {
     return `https:${trimmed}`
   }
 
+  // Las imágenes subidas vienen como /api/images/...: se sirven desde el origen del backend.
+  if (trimmed.startsWith('/')) {
+    return new URL(trimmed, getApiBaseUrl()).href
+  }
+
   if (trimmed.toLowerCase().startsWith('http://') && typeof window !== 'undefined' && window.location.protocol === 'https:') {
     return trimmed.replace(/^http:\/\//i, 'https://')
   }
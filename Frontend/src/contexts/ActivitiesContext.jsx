@@ -2,13 +2,15 @@ import { createContext, useCallback, useContext, useEffect, useMemo, useState }
 import { useAuth } from './AuthContext.jsx'
 import {
   createActivity as createActivityRequest,
-  deleteActivity as deleteActivityRequest,
+  changeActivityStatus as changeActivityStatusRequest,
   enrollInActivity as enrollInActivityRequest,
   getActivity as getActivityRequest,
   getMyActivities as getMyActivitiesRequest,
   listActivities,
+  subscribeAvailability,
   unenrollFromActivity as unenrollFromActivityRequest,
   updateActivity as updateActivityRequest,
+  uploadActivityImage as uploadActivityImageRequest,
 } from '../services/activitiesService.js'
 
 const ActivitiesContext = createContext(null)
@@ -56,6 +58,17 @@ export const ActivitiesProvider = ({ children }) => {
     fetchActivities().catch(() => {})
   }, [fetchActivities])
 
+  // Los cupos dinámicos llegan por SSE en lugar de volver a pedir el listado.
+  useEffect(
+    () =>
+      subscribeAvailability(null, (update) => {
+        setActivities((prev) =>
+          prev.map((activity) => (activity.id === update.id ? { ...activity, ...update } : activity)),
+        )
+      }),
+    [],
+  )
+
   useEffect(() => {
     // Esperar a que AuthContext haya finalizado la inicialización
     if (!authReady) return
@@ -80,9 +93,22 @@ export const ActivitiesProvider = ({ children }) => {
     return updated
   }, [])
 
-  const deleteActivity = useCallback(async (id) => {
-    await deleteActivityRequest(id)
-    setActivities((prev) => prev.filter((activity) => activity.id !== Number(id)))
+  const uploadActivityImage = useCallback(async (id, file) => {
+    const image = await uploadActivityImageRequest(id, file)
+    setActivities((prev) => prev.map((activity) => (activity.id === Number(id) ? { ...activity, ...image } : activity)))
+    return image
+  }, [])
+
+  // Las actividades archivadas o en borrador salen del listado; las demás se actualizan.
+  const changeActivityStatus = useCallback(async (id, action, options) => {
+    const result = await changeActivityStatusRequest(id, action, options)
+    const { activity: updated } = result
+    setActivities((prev) =>
+      updated.isActive
+        ? prev.map((activity) => (activity.id === updated.id ? updated : activity))
+        : prev.filter((activity) => activity.id !== updated.id),
+    )
+    return result
   }, [])
 
   const loadActivityById = useCallback(
@@ -136,7 +162,8 @@ export const ActivitiesProvider = ({ children }) => {
       refreshActivities: fetchActivities,
       createActivity,
       updateActivity,
-      deleteActivity,
+      uploadActivityImage,
+      changeActivityStatus,
       enrollInActivity,
       unenrollFromActivity,
       loadActivityById,
@@ -151,7 +178,8 @@ export const ActivitiesProvider = ({ children }) => {
       fetchActivities,
       createActivity,
       updateActivity,
-      deleteActivity,
+      uploadActivityImage,
+      changeActivityStatus,
       enrollInActivity,
       unenrollFromActivity,
       loadActivityById,
@@ -61,7 +61,7 @@ const ActivitiesPage = () => {
                 <Link to={`/activities/${activity.id}`} className="activity-card" key={activity.id}>
                   <div className="activity-card-media">
                     <ActivityImage
-                      src={activity.imageUrl}
+                      src={activity.thumbnailUrl || activity.imageUrl}
                       alt={`Foto de ${activity.title}`}
                       className="activity-card-image"
                     />
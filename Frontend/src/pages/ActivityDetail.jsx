@@ -5,14 +5,16 @@ import Notification from '../components/Notification.jsx'
 import { useActivities } from '../contexts/ActivitiesContext.jsx'
 import { useAuth } from '../contexts/AuthContext.jsx'
 import ActivityImage from '../components/ActivityImage.jsx'
+import { cloneActivity } from '../services/activitiesService.js'
 
 const DAY_LABELS = ['Domingo', 'Lunes', 'Martes', 'Miércoles', 'Jueves', 'Viernes', 'Sábado']
+const STATUS_LABELS = { borrador: 'Borrador', publicada: 'Publicada', pausada: 'En pausa', archivada: 'Archivada' }
 
 const ActivityDetailPage = () => {
   const { activityId } = useParams()
   const navigate = useNavigate()
   const location = useLocation()
-  const { loadActivityById, enrollInActivity, deleteActivity, unenrollFromActivity, myActivities } = useActivities()
+  const { loadActivityById, enrollInActivity, changeActivityStatus, unenrollFromActivity, myActivities } = useActivities()
   const { isAdmin, isAuthenticated } = useAuth()
   const [feedback, setFeedback] = useState({ type: null, message: '' })
   const [activity, setActivity] = useState(null)
@@ -42,6 +44,8 @@ const ActivityDetailPage = () => {
   }, [activity])
 
   const isFull = activity ? availableSlots <= 0 : false
+  const isPublished = activity?.status === 'publicada'
+  const isPaused = activity?.status === 'pausada'
 
   const handleEnroll = async () => {
     if (!activity) return
@@ -94,17 +98,40 @@ const ActivityDetailPage = () => {
     }
   }
 
-  const handleDelete = async () => {
+  const handleStatusChange = async (action) => {
     if (!activity) return
-    const confirmed = window.confirm('¿Eliminar la actividad? Esta acción no se puede deshacer.')
-    if (!confirmed) return
+    if (action === 'archive') {
+      const confirmed = window.confirm(
+        '¿Archivar la actividad? Deja de mostrarse y se cancelan las inscripciones (los pagos aprobados quedan para reintegro).',
+      )
+      if (!confirmed) return
+    }
+
+    try {
+      const { activity: updated, cancelledEnrollments } = await changeActivityStatus(activity.id, action, {
+        cancelEnrollments: action === 'archive',
+      })
+      if (action === 'archive') {
+        const detail = cancelledEnrollments ? ` Se cancelaron ${cancelledEnrollments} inscripciones.` : ''
+        setFeedback({ type: 'success', message: `Actividad archivada.${detail} Redirigiendo…` })
+        setTimeout(() => navigate('/activities', { replace: true }), 900)
+        return
+      }
+      setActivity(updated)
+      setFeedback({ type: 'success', message: `Estado actualizado: ${STATUS_LABELS[updated.status] ?? updated.status}.` })
+    } catch (error) {
+      setFeedback({ type: 'error', message: error.message ?? 'No se pudo cambiar el estado de la actividad.' })
+    }
+  }
 
+  // La copia queda en borrador: se abre para editarla antes de publicarla.
+  const handleClone = async () => {
+    if (!activity) return
     try {
-      await deleteActivity(activity.id)
-      setFeedback({ type: 'success', message: 'Actividad eliminada. Redirigiendo…' })
-      setTimeout(() => navigate('/activities', { replace: true }), 900)
+      const copy = await cloneActivity(activity.id)
+      navigate(`/admin/activities/${copy.id}/edit`)
     } catch (error) {
-      setFeedback({ type: 'error', message: error.message ?? 'No se pudo eliminar la actividad.' })
+      setFeedback({ type: 'error', message: error.message ?? 'No se pudo duplicar la actividad.' })
     }
   }
 
@@ -116,9 +143,23 @@ const ActivityDetailPage = () => {
         <Link to={`/admin/activities/${activity.id}/edit`} className="btn-secondary">
           Editar actividad
         </Link>
-        <button type="button" className="btn-primary danger" onClick={handleDelete}>
-          Eliminar
+        <button type="button" className="btn-secondary" onClick={handleClone}>
+          Duplicar
         </button>
+        {isPublished ? (
+          <button type="button" className="btn-secondary" onClick={() => handleStatusChange('pause')}>
+            Pausar
+          </button>
+        ) : (
+          <button type="button" className="btn-secondary" onClick={() => handleStatusChange('publish')}>
+            Publicar
+          </button>
+        )}
+        {activity.status !== 'archivada' ? (
+          <button type="button" className="btn-primary danger" onClick={() => handleStatusChange('archive')}>
+            Archivar
+          </button>
+        ) : null}
       </div>
     )
   }
@@ -176,6 +217,11 @@ const ActivityDetailPage = () => {
               <li>
                 <span>Instructor/a:</span> {activity.instructor}
               </li>
+              {activity.location ? (
+                <li>
+                  <span>Lugar:</span> {activity.location}
+                </li>
+              ) : null}
               <li>
                 <span>Día:</span> {DAY_LABELS[activity.dayOfWeek]}
               </li>
@@ -185,9 +231,9 @@ const ActivityDetailPage = () => {
               <li>
                 <span>Cupos:</span> {availableSlots}/{activity.capacity}
               </li>
-              {!activity.isActive ? (
+              {!isPublished ? (
                 <li>
-                  <span>Estado:</span> Inactiva
+                  <span>Estado:</span> {STATUS_LABELS[activity.status] ?? 'Inactiva'}
                 </li>
               ) : null}
             </ul>
@@ -197,9 +243,15 @@ const ActivityDetailPage = () => {
                 type="button"
                 className="btn-primary"
                 onClick={handleEnroll}
-                disabled={!activity.isActive || alreadyEnrolled || isFull}
+                disabled={!isPublished || alreadyEnrolled || isFull}
               >
-                {alreadyEnrolled ? 'Ya estás inscripto' : !activity.isActive ? 'Inactiva' : isFull ? 'Sin cupos' : 'Inscribirme'}
+                {alreadyEnrolled
+                  ? 'Ya estás inscripto'
+                  : !isPublished
+                    ? STATUS_LABELS[activity.status] ?? 'Inactiva'
+                    : isFull
+                      ? 'Sin cupos'
+                      : 'Inscribirme'}
               </button>
               {alreadyEnrolled ? (
                 <button type="button" className="btn-primary danger" onClick={handleUnenroll}>
@@ -212,11 +264,13 @@ const ActivityDetailPage = () => {
             </div>
 
             <p className="detail-quota-message">
-              {activity.isActive
+              {isPublished
                 ? isFull
                   ? 'No quedan cupos disponibles.'
                   : `Quedan ${availableSlots} cupos disponibles.`
-                : 'Esta actividad está inactiva.'}
+                : isPaused
+                  ? 'Esta actividad está en pausa: los inscriptos conservan su lugar hasta que vuelva a dictarse.'
+                  : 'Esta actividad no está disponible.'}
             </p>
 
             {renderAdminActions()}
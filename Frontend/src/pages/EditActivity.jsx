@@ -8,7 +8,7 @@ import { useActivities } from '../contexts/ActivitiesContext.jsx'
 const EditActivityPage = () => {
   const { activityId } = useParams()
   const navigate = useNavigate()
-  const { loadActivityById, updateActivity } = useActivities()
+  const { loadActivityById, updateActivity, uploadActivityImage } = useActivities()
   const [feedback, setFeedback] = useState({ type: null, message: '' })
   const [redirectId, setRedirectId] = useState(null)
   const [activity, setActivity] = useState(null)
@@ -81,7 +81,12 @@ const EditActivityPage = () => {
         <section className="login-layout single-column">
           <div className="login-panel">
             <h1 className="login-title">Editar {activity.title}</h1>
-            <ActivityForm initialValues={activity} onSubmit={handleSubmit} submitLabel="Guardar cambios" />
+            <ActivityForm
+              initialValues={activity}
+              onSubmit={handleSubmit}
+              onUploadImage={(file) => uploadActivityImage(activity.id, file)}
+              submitLabel="Guardar cambios"
+            />
           </div>
         </section>
 
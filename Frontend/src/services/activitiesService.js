@@ -1,32 +1,45 @@
-import apiClient from './apiClient.js'
+import apiClient, { getApiBaseUrl } from './apiClient.js'
 
 const toActivity = (payload) => ({
   id: payload.id,
   title: payload.title,
   description: payload.description,
   category: payload.category,
+  categoryId: payload.category_id ?? null,
   dayOfWeek: payload.day_of_week,
   startTime: payload.start_time,
   endTime: payload.end_time,
   capacity: payload.capacity,
   instructor: payload.instructor,
+  location: payload.location || '',
   imageUrl: payload.image_url,
+  thumbnailUrl: payload.thumbnail_url || '',
   isActive: payload.is_active,
+  status: payload.status || (payload.is_active ? 'publicada' : 'archivada'),
   availableSlots: typeof payload.available_slots === 'number' ? payload.available_slots : null,
   enrolledCount: typeof payload.enrolled_count === 'number' ? payload.enrolled_count : null,
+  seasonId: payload.season_id ?? null,
+  eligibilityRules: payload.eligibility_rules || [],
+  // Solo con sesión iniciada: { eligible, reasons } según los requisitos de la actividad.
+  eligibility: payload.eligibility || null,
 })
 
 const toAdminPayload = (payload) => ({
   title: payload.title,
   description: payload.description,
   category: payload.category,
+  category_id: payload.categoryId ?? null,
   day_of_week: Number(payload.dayOfWeek),
   start_time: payload.startTime,
   end_time: payload.endTime,
   capacity: Number(payload.capacity),
   instructor: payload.instructor,
+  location: payload.location || '',
   image_url: payload.imageUrl || '',
-  is_active: payload.isActive ?? true,
+  // Sin temporada la actividad se dicta todo el año.
+  season_id: payload.seasonId ?? null,
+  // Solo al crear: después el estado cambia con changeActivityStatus.
+  status: payload.status,
 })
 
 const toMemberActivity = (payload) => ({
@@ -40,15 +53,151 @@ const toMemberActivity = (payload) => ({
   instructor: payload.instructor,
 })
 
-export const listActivities = async (filters = {}) => {
+// filters.category y filters.day aceptan un valor o un arreglo de valores.
+const toFilterQuery = (filters) => {
   const params = new URLSearchParams()
+  const list = (value) => [].concat(value).filter((item) => item !== undefined && item !== null && item !== '')
   if (filters.query) params.set('q', filters.query)
-  if (filters.category) params.set('category', filters.category)
-  if (typeof filters.day === 'number') params.set('day', filters.day)
+  if (list(filters.category).length) params.set('category', list(filters.category).join(','))
+  if (list(filters.day).length) params.set('day', list(filters.day).join(','))
+  if (filters.instructor) params.set('instructor', filters.instructor)
+  if (filters.startAfter) params.set('start_after', filters.startAfter)
+  if (filters.startBefore) params.set('start_before', filters.startBefore)
+  if (filters.minDuration) params.set('min_duration', filters.minDuration)
+  if (filters.maxDuration) params.set('max_duration', filters.maxDuration)
+  if (filters.onlyAvailable) params.set('available', 'true')
+  // Sin temporada se muestra la que está en curso.
+  if (filters.seasonId) params.set('season', filters.seasonId)
+  return params.toString()
+}
+
+// Una página del listado. page: { limit, offset | cursor, sort } con sort como "day,-availability".
+export const listActivitiesPage = async (filters = {}, page = {}) => {
+  const params = new URLSearchParams(toFilterQuery(filters))
+  if (page.limit) params.set('limit', page.limit)
+  if (page.cursor) params.set('cursor', page.cursor)
+  else if (typeof page.offset === 'number') params.set('offset', page.offset)
+  if (page.sort) params.set('sort', page.sort)
 
   const query = params.toString()
-  const data = await apiClient.get(query ? `/activities?${query}` : '/activities')
-  return Array.isArray(data) ? data.map(toActivity) : []
+  const response = await apiClient.get(query ? `/activities?${query}` : '/activities', { envelope: true })
+  return {
+    items: Array.isArray(response?.data) ? response.data.map(toActivity) : [],
+    total: response?.meta?.total ?? 0,
+    hasMore: Boolean(response?.meta?.has_more),
+    nextCursor: response?.meta?.next_cursor || null,
+    facets: response?.meta?.facets || null,
+  }
+}
+
+// Recorre todas las páginas: el catálogo se filtra en memoria en el frontend.
+export const listActivities = async (filters = {}) => {
+  const activities = []
+  let cursor = null
+  do {
+    const page = await listActivitiesPage(filters, { limit: 100, cursor })
+    activities.push(...page.items)
+    cursor = page.hasMore ? page.nextCursor : null
+  } while (cursor)
+  return activities
+}
+
+const toScheduleEntry = (payload) => ({
+  ...toActivity(payload),
+  enrollmentStatus: payload.enrollment_status || null,
+  isEnrolled: Boolean(payload.is_enrolled),
+  hasConflict: Boolean(payload.has_conflict),
+  conflictsWith: payload.conflicts_with || [],
+})
+
+// Grilla semanal: siete días (0 = domingo) con sus franjas mañana/tarde/noche.
+// Con sesión iniciada cada clase indica si el socio está inscripto o si se superpone.
+export const getSchedule = async (filters = {}) => {
+  const query = toFilterQuery(filters)
+  const data = await apiClient.get(query ? `/schedule?${query}` : '/schedule')
+  return {
+    personalized: Boolean(data?.personalized),
+    days: (data?.days || []).map((day) => ({
+      dayOfWeek: day.day_of_week,
+      name: day.name,
+      bands: day.bands.map((band) => ({
+        key: band.key,
+        label: band.label,
+        from: band.from,
+        to: band.to,
+        activities: band.activities.map(toScheduleEntry),
+      })),
+    })),
+  }
+}
+
+// Abre el stream SSE de cupos. Sin ids recibe todas las actividades activas.
+// Devuelve una función para cerrar la conexión; EventSource reconecta solo si se corta.
+export const subscribeAvailability = (ids, onUpdate) => {
+  if (typeof EventSource === 'undefined') return () => {}
+
+  const query = ids && ids.length ? `?ids=${ids.join(',')}` : ''
+  const source = new EventSource(`${getApiBaseUrl()}/activities/stream${query}`)
+  source.addEventListener('availability', (event) => {
+    const payload = JSON.parse(event.data)
+    onUpdate({
+      id: payload.activity_id,
+      isActive: payload.is_active,
+      status: payload.status,
+      capacity: payload.capacity,
+      enrolledCount: payload.enrolled_count,
+      availableSlots: payload.available_slots,
+    })
+  })
+  return () => source.close()
+}
+
+const toCategory = (payload) => ({
+  id: payload.id,
+  slug: payload.slug,
+  name: payload.name,
+  color: payload.color || '',
+  icon: payload.icon || '',
+  activeActivities: payload.active_activities ?? 0,
+})
+
+// Catálogo de categorías con la cantidad de actividades activas de cada una.
+export const listCategories = async () => {
+  const data = await apiClient.get('/categories')
+  return Array.isArray(data) ? data.map(toCategory) : []
+}
+
+const toSeason = (payload) => ({
+  id: payload.id,
+  name: payload.name,
+  startsOn: payload.starts_on,
+  endsOn: payload.ends_on,
+  enrollmentOpensOn: payload.enrollment_opens_on || '',
+  priorityOpensOn: payload.priority_opens_on || '',
+  activities: payload.activities ?? 0,
+  current: Boolean(payload.current),
+})
+
+// Temporadas de la grilla, en orden de fechas; current marca la que está en curso.
+export const listSeasons = async () => {
+  const data = await apiClient.get('/seasons')
+  return Array.isArray(data) ? data.map(toSeason) : []
+}
+
+// Reinscribe al socio en las actividades de la temporada que continúan las suyas. Devuelve el
+// resultado de cada una: enrolled, o code y error cuando no se pudo.
+export const reenrollInSeason = async (seasonId) => {
+  const data = await apiClient.post(`/seasons/${seasonId}/reenroll`)
+  return Array.isArray(data)
+    ? data.map((item) => ({
+        activityId: item.activity_id,
+        sourceActivityId: item.source_activity_id,
+        title: item.title,
+        enrolled: Boolean(item.enrollment),
+        code: item.code || '',
+        error: item.error || '',
+      }))
+    : []
 }
 
 export const getActivity = async (id) => {
@@ -74,4 +223,41 @@ export const updateActivity = async (id, payload) => {
   return toActivity(data)
 }
 
-export const deleteActivity = async (id) => apiClient.delete(`/admin/activities/${id}`)
+// action es publish, pause, archive o draft. Al archivar, cancelEnrollments da de baja a los
+// inscriptos; sin él el backend rechaza archivar una actividad con inscripciones.
+export const changeActivityStatus = async (id, action, { cancelEnrollments = false } = {}) => {
+  const query = action === 'archive' && cancelEnrollments ? '?cancel_enrollments=true' : ''
+  const data = await apiClient.post(`/admin/activities/${id}/${action}${query}`)
+  return { activity: toActivity(data.activity), cancelledEnrollments: data.cancelled_enrollments ?? 0 }
+}
+
+// Duplica la actividad como borrador; overrides cambia campos de la copia (mismo formato que el
+// formulario, solo los que se quieran cambiar).
+export const cloneActivity = async (id, overrides = {}) => {
+  const body = {}
+  if (overrides.title !== undefined) body.title = overrides.title
+  if (overrides.dayOfWeek !== undefined) body.day_of_week = Number(overrides.dayOfWeek)
+  if (overrides.startTime !== undefined) body.start_time = overrides.startTime
+  if (overrides.endTime !== undefined) body.end_time = overrides.endTime
+  if (overrides.instructor !== undefined) body.instructor = overrides.instructor
+  if (overrides.seasonId !== undefined) body.season_id = overrides.seasonId
+  const data = await apiClient.post(`/admin/activities/${id}/clone`, body)
+  return toActivity(data)
+}
+
+// Sube la imagen (JPEG, PNG, GIF o WebP); el backend la redimensiona y devuelve sus URLs.
+export const uploadActivityImage = async (id, file) => {
+  const form = new FormData()
+  form.append('image', file)
+  const data = await apiClient.post(`/admin/activities/${id}/image`, form)
+  return { imageUrl: data.image_url, thumbnailUrl: data.thumbnail_url }
+}
+
+export const removeActivityImage = async (id) => apiClient.delete(`/admin/activities/${id}/image`)
+
+// Reemplaza los requisitos de la actividad: [{ type: 'min_age', value: '18' }, ...]. Una lista
+// vacía los quita.
+export const setEligibilityRules = async (id, rules) => {
+  const data = await apiClient.put(`/admin/activities/${id}/eligibility`, { rules })
+  return Array.isArray(data) ? data : []
+}
@@ -23,7 +23,8 @@ const parseJSON = async (response) => {
 }
 
 const request = async (path, options = {}) => {
-  const { method = 'GET', body, headers = {}, ...rest } = options
+  // envelope: true devuelve la respuesta completa (data, meta, links) en lugar de solo data.
+  const { method = 'GET', body, headers = {}, envelope = false, ...rest } = options
   const init = {
     method,
     headers: {
@@ -78,7 +79,7 @@ const request = async (path, options = {}) => {
     return null
   }
 
-  return payload?.data ?? payload
+  return envelope ? payload : payload?.data ?? payload
 }
 
 const apiClient = {